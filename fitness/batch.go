@@ -0,0 +1,167 @@
+package fitness
+
+import (
+	"math"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+// BatchFunction is a fitness function that can evaluate many positions in one
+// call, which is much cheaper than one Query per position for some objectives
+// (vectorized math, or a simulator that accepts many designs at once).
+type BatchFunction interface {
+	Function
+
+	// QueryBatch returns the value at every position, in order. It must agree
+	// with Query.
+	QueryBatch(pos []vec.Vec) []float64
+}
+
+// BatchQueryFunc evaluates a whole batch of positions at once.
+type BatchQueryFunc func(fit *Fitness, pos []vec.Vec) []float64
+
+// BatchFitness is a Fitness with a batch query.
+type BatchFitness struct {
+	*Fitness
+
+	bq BatchQueryFunc
+}
+
+// NewBatchFitness creates a function that is defined by its batch query.
+// Single queries are batches of one.
+func NewBatchFitness(dims int, minCorner, maxCorner vec.Vec, offsetBy float64, bq BatchQueryFunc) *BatchFitness {
+	return &BatchFitness{
+		Fitness: NewFitness(dims, minCorner, maxCorner, offsetBy, func(f *Fitness, pos vec.Vec) float64 {
+			return bq(f, []vec.Vec{pos})[0]
+		}),
+		bq: bq,
+	}
+}
+
+// Batched makes a batch function out of f, all on the calling goroutine. The
+// built-in benchmarks (NewParabola, NewRastrigin and so on) have batch queries
+// of their own, which work through the batch one dimension at a time; any
+// other function has every position queried in turn.
+func Batched(f *Fitness) *BatchFitness {
+	bq := f.bq
+	if bq == nil {
+		bq = func(f *Fitness, pos []vec.Vec) []float64 {
+			vals := make([]float64, len(pos))
+			for i, p := range pos {
+				vals[i] = f.Query(p)
+			}
+			return vals
+		}
+	}
+	return &BatchFitness{Fitness: f, bq: bq}
+}
+
+// QueryBatch evaluates every position.
+func (f *BatchFitness) QueryBatch(pos []vec.Vec) []float64 {
+	return f.bq(f.Fitness, pos)
+}
+
+// The batch queries of the built-in benchmarks below keep a running sum for
+// every position in the batch, and add one dimension to all of them at a time.
+// Each sum is accumulated in the same order as in Query, so that the results
+// are exactly the same.
+
+func parabolaBatch(f *Fitness, pos []vec.Vec) []float64 {
+	vals := make([]float64, len(pos))
+	for d, c := range f.Center {
+		for i, x := range pos {
+			p := x[d] - c
+			vals[i] += p * p
+		}
+	}
+	return vals
+}
+
+func rastriginBatch(f *Fitness, pos []vec.Vec) []float64 {
+	vals := make([]float64, len(pos))
+	for i := range vals {
+		vals[i] = 10.0 * float64(f.dims)
+	}
+	for d, c := range f.Center {
+		for i, x := range pos {
+			p := x[d] - c
+			vals[i] += p*p - 10.0*math.Cos(2*math.Pi*p)
+		}
+	}
+	return vals
+}
+
+func rosenbrockBatch(f *Fitness, pos []vec.Vec) []float64 {
+	vals := make([]float64, len(pos))
+	for d := 0; d < f.dims-1; d++ {
+		for i, x := range pos {
+			p := x[d] - f.Center[d]
+			p1 := x[d+1] - f.Center[d+1]
+			pinv := (1 - p)
+			corr := (p1 - p*p)
+			vals[i] += pinv*pinv + 100*corr*corr
+		}
+	}
+	return vals
+}
+
+func ackleyBatch(f *Fitness, pos []vec.Vec) []float64 {
+	s1, s2 := make([]float64, len(pos)), make([]float64, len(pos))
+	for d, c := range f.Center {
+		for i, x := range pos {
+			p := x[d] - c
+			s1[i] += p * p
+			s2[i] += math.Cos(p * (2.0 * math.Pi))
+		}
+	}
+	D := float64(f.dims)
+	vals := make([]float64, len(pos))
+	for i := range vals {
+		vals[i] = -20.0*math.Exp(-0.2*math.Sqrt(s1[i]/D)) - math.Exp(s2[i]/D) + 20.0 + math.E
+	}
+	return vals
+}
+
+func deJongF4Batch(f *Fitness, pos []vec.Vec) []float64 {
+	vals := make([]float64, len(pos))
+	for d, c := range f.Center {
+		for i, x := range pos {
+			vals[i] += float64(d+1) * math.Pow(x[d]-c, 4)
+		}
+	}
+	return vals
+}
+
+func easomBatch(f *Fitness, pos []vec.Vec) []float64 {
+	sums, prods := make([]float64, len(pos)), make([]float64, len(pos))
+	for i := range prods {
+		prods[i] = 1.0
+	}
+	for d, c := range f.Center {
+		for i, x := range pos {
+			p := x[d] - c
+			sums[i] += p * p
+			prods[i] *= math.Cos(math.Pi + p)
+		}
+	}
+	vals := make([]float64, len(pos))
+	for i := range vals {
+		vals[i] = 1.0 + math.Exp(-sums[i])*prods[i]
+	}
+	return vals
+}
+
+func schwefelBatch(f *Fitness, pos []vec.Vec) []float64 {
+	sums := make([]float64, len(pos))
+	for d, c := range f.Center {
+		for i, x := range pos {
+			p := x[d] - c
+			sums[i] += p * math.Sin(math.Sqrt(math.Abs(p)))
+		}
+	}
+	vals := make([]float64, len(pos))
+	for i, sum := range sums {
+		vals[i] = 418.9829*float64(f.dims) + sum
+	}
+	return vals
+}
@@ -0,0 +1,60 @@
+package fitness
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+func ExampleNewBatchFitness() {
+	// Sums of squares, computed for the whole batch at once.
+	f := NewBatchFitness(2, vec.Vec{-1, -1}, vec.Vec{1, 1}, 0, func(f *Fitness, pos []vec.Vec) []float64 {
+		vals := make([]float64, len(pos))
+		for i, p := range pos {
+			vals[i] = p.Dot(p)
+		}
+		return vals
+	})
+	fmt.Println(f.QueryBatch([]vec.Vec{{1, 0}, {1, 1}, {0.5, 0}}))
+	fmt.Println(f.Query(vec.Vec{1, 1}))
+
+	// Output:
+	// [1 2 0.25]
+	// 2
+}
+
+func ExampleBatched() {
+	f := Batched(NewParabola(2, 0))
+	fmt.Println(f.QueryBatch([]vec.Vec{{1, 2}, {3, 0}}))
+
+	// Output:
+	// [5 9]
+}
+
+func TestBuiltinBatchesMatchQuery(t *testing.T) {
+	rgen := rand.New(rand.NewSource(1))
+	for name, f := range map[string]*Fitness{
+		"parabola":   NewParabola(7, 0.25),
+		"rastrigin":  NewRastrigin(7, 0.25),
+		"rosenbrock": NewRosenbrock(7, 0.25),
+		"ackley":     NewAckley(7, 0.25),
+		"dejongf4":   NewDeJongF4(7, 0.25),
+		"easom":      NewEasom(7, 0.25),
+		"schwefel":   NewSchwefel(7, 0.25),
+	} {
+		if f.bq == nil {
+			t.Errorf("%s: expected a batch query", name)
+		}
+		pos := make([]vec.Vec, 50)
+		for i := range pos {
+			pos[i] = f.RandomPos(rgen)
+		}
+		for i, val := range Batched(f).QueryBatch(pos) {
+			if want := f.Query(pos[i]); val != want {
+				t.Errorf("%s: position %d has value %v in a batch, %v alone", name, i, val, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package fitness
+
+import (
+	"container/list"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+// CacheStats counts the lookups of a Cache.
+type CacheStats struct {
+	Hits   int
+	Misses int
+	Size   int // entries currently held
+}
+
+// HitRate returns the fraction of lookups that were hits.
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (s CacheStats) String() string {
+	return fmt.Sprintf("%d hits, %d misses (%.1f%%), %d entries", s.Hits, s.Misses, 100*s.HitRate(), s.Size)
+}
+
+// Cache is a Function that remembers the values of the function it wraps, so
+// that repeated queries are not evaluated again. Positions are keyed exactly,
+// or, with a positive quantum, by the cell of a grid with that spacing that
+// they fall in, so that nearly repeated queries get the value of the first
+// query in the same cell. The least recently used entries are dropped once the
+// cache is full.
+//
+// Only Function is implemented: constraints and dynamic changes of the wrapped
+// function are hidden, so those should not be cached.
+type Cache struct {
+	Function
+
+	size    int
+	quantum float64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // of *cacheEntry, most recently used first
+	hits    int
+	misses  int
+}
+
+type cacheEntry struct {
+	Key string
+	Val float64
+}
+
+// NewCache creates a cache around f holding at most size entries (no limit if
+// size is not positive), keyed by grid cells of the given spacing (exact
+// positions if quantum is not positive).
+func NewCache(f Function, size int, quantum float64) *Cache {
+	return &Cache{
+		Function: f,
+		size:     size,
+		quantum:  quantum,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// key encodes a position, or the grid cell that it falls in.
+func (c *Cache) key(pos vec.Vec) string {
+	buf := make([]byte, 8*len(pos))
+	for i, x := range pos {
+		var bits uint64
+		if c.quantum > 0 {
+			bits = uint64(int64(math.Floor(x / c.quantum)))
+		} else {
+			bits = math.Float64bits(x)
+		}
+		binary.LittleEndian.PutUint64(buf[8*i:], bits)
+	}
+	return string(buf)
+}
+
+// lookup returns the cached value for key, and counts the hit or miss. The
+// caller must hold the lock.
+func (c *Cache) lookup(key string) (val float64, ok bool) {
+	if elem, ok := c.entries[key]; ok {
+		c.hits++
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*cacheEntry).Val, true
+	}
+	c.misses++
+	return 0, false
+}
+
+// store adds an entry, evicting the least recently used entries if the cache
+// is full. The caller must hold the lock.
+func (c *Cache) store(key string, val float64) {
+	if elem, ok := c.entries[key]; ok {
+		// Someone else evaluated the same position concurrently.
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{Key: key, Val: val})
+	for c.size > 0 && c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).Key)
+	}
+}
+
+// Query returns the cached value at pos, evaluating the wrapped function only
+// on a miss. The lock is not held during evaluation, so concurrent misses on
+// the same position may both evaluate it.
+func (c *Cache) Query(pos vec.Vec) float64 {
+	key := c.key(pos)
+	c.mu.Lock()
+	val, ok := c.lookup(key)
+	c.mu.Unlock()
+	if ok {
+		return val
+	}
+
+	val = c.Function.Query(pos)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(key, val)
+	return val
+}
+
+// Stats returns the lookup counts so far.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Size: c.lru.Len()}
+}
+
+// cacheFile is the persistent form of a Cache.
+type cacheFile struct {
+	Quantum float64
+	Entries []cacheEntry // least recently used first
+}
+
+// Save writes the cached entries to w, so that later runs on the same function
+// can reuse them.
+func (c *Cache) Save(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cf := cacheFile{Quantum: c.quantum}
+	for elem := c.lru.Back(); elem != nil; elem = elem.Prev() {
+		cf.Entries = append(cf.Entries, *elem.Value.(*cacheEntry))
+	}
+	return gob.NewEncoder(w).Encode(cf)
+}
+
+// Load adds entries written by Save to the cache, as the most recently used.
+// The entries must have been saved with the same quantum, and it is up to the
+// caller to make sure that they came from the same function.
+func (c *Cache) Load(r io.Reader) error {
+	var cf cacheFile
+	if err := gob.NewDecoder(r).Decode(&cf); err != nil {
+		return err
+	}
+	if cf.Quantum != c.quantum {
+		return fmt.Errorf("cache saved with quantum %v cannot be loaded with quantum %v", cf.Quantum, c.quantum)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range cf.Entries {
+		c.store(e.Key, e.Val)
+	}
+	return nil
+}
+
+// SaveFile saves the cache to the named file.
+func (c *Cache) SaveFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := c.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadFile loads the cache from the named file, if it exists.
+func (c *Cache) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.Load(f)
+}
+
+// BatchCache is a Cache around a BatchFunction. Misses in a batch are passed
+// on to the wrapped function as one smaller batch.
+type BatchCache struct {
+	*Cache
+
+	batch BatchFunction
+}
+
+// NewBatchCache creates a cache around a batch function, as in NewCache.
+func NewBatchCache(f BatchFunction, size int, quantum float64) *BatchCache {
+	return &BatchCache{Cache: NewCache(f, size, quantum), batch: f}
+}
+
+// QueryBatch returns the cached value at every position, evaluating all of
+// the misses in one batch. Repeats within the batch are evaluated once, and
+// count as hits.
+func (c *BatchCache) QueryBatch(pos []vec.Vec) []float64 {
+	vals := make([]float64, len(pos))
+	keys := make([]string, len(pos))
+	pending := make(map[string]int) // index into missPos
+	var missPos []vec.Vec
+	c.mu.Lock()
+	for i, p := range pos {
+		keys[i] = c.key(p)
+		if _, ok := pending[keys[i]]; ok {
+			c.hits++
+			continue
+		}
+		var ok bool
+		if vals[i], ok = c.lookup(keys[i]); !ok {
+			pending[keys[i]] = len(missPos)
+			missPos = append(missPos, p)
+		}
+	}
+	c.mu.Unlock()
+	if len(missPos) == 0 {
+		return vals
+	}
+
+	missVals := c.batch.QueryBatch(missPos)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, key := range keys {
+		if j, ok := pending[key]; ok {
+			vals[i] = missVals[j]
+			c.store(key, vals[i])
+		}
+	}
+	return vals
+}
@@ -0,0 +1,83 @@
+package fitness
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+func ExampleNewCache() {
+	evals := 0
+	parabola := NewParabola(2, 0)
+	f := NewCache(NewFitnessSquareDomain(2, -50, 50, 0, func(f *Fitness, pos vec.Vec) float64 {
+		evals++
+		return parabola.Query(pos)
+	}), 2, 0)
+
+	f.Query(vec.Vec{1, 2})
+	f.Query(vec.Vec{3, 4})
+	f.Query(vec.Vec{1, 2})
+	f.Query(vec.Vec{5, 6}) // evicts (3, 4)
+	f.Query(vec.Vec{3, 4})
+	fmt.Println(evals, f.Stats())
+
+	// Output:
+	// 4 1 hits, 4 misses (20.0%), 2 entries
+}
+
+func ExampleNewCache_quantized() {
+	f := NewCache(NewParabola(1, 0), 0, 0.5)
+	fmt.Println(f.Query(vec.Vec{1.1}))
+	fmt.Println(f.Query(vec.Vec{1.4})) // same cell as 1.1
+	fmt.Println(f.Query(vec.Vec{1.6}))
+
+	// Output:
+	// 1.2100000000000002
+	// 1.2100000000000002
+	// 2.5600000000000005
+}
+
+func ExampleCache_Save() {
+	f := NewCache(NewParabola(2, 0), 10, 0)
+	f.Query(vec.Vec{1, 2})
+	f.Query(vec.Vec{3, 4})
+	var buf bytes.Buffer
+	if err := f.Save(&buf); err != nil {
+		fmt.Println(err)
+	}
+
+	g := NewCache(NewParabola(2, 0), 10, 0)
+	if err := g.Load(&buf); err != nil {
+		fmt.Println(err)
+	}
+	g.Query(vec.Vec{3, 4})
+	fmt.Println(g.Stats())
+
+	// Output:
+	// 1 hits, 0 misses (100.0%), 2 entries
+}
+
+func ExampleNewBatchCache() {
+	batches := 0
+	f := NewBatchCache(NewBatchFitness(1, vec.Vec{-1}, vec.Vec{1}, 0, func(f *Fitness, pos []vec.Vec) []float64 {
+		batches++
+		fmt.Println("evaluating", len(pos))
+		vals := make([]float64, len(pos))
+		for i, p := range pos {
+			vals[i] = 10 * p[0]
+		}
+		return vals
+	}), 0, 0)
+
+	fmt.Println(f.QueryBatch([]vec.Vec{{0.1}, {0.2}, {0.1}}))
+	fmt.Println(f.QueryBatch([]vec.Vec{{0.2}, {0.3}}))
+	fmt.Println(batches, f.Stats())
+
+	// Output:
+	// evaluating 2
+	// [1 2 1]
+	// evaluating 1
+	// [2 3]
+	// 2 2 hits, 3 misses (40.0%), 3 entries
+}
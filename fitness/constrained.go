@@ -0,0 +1,114 @@
+package fitness
+
+import (
+	"math"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+// ConstrainedFunction is a fitness function with inequality and/or equality
+// constraints. Query returns the objective alone; Violations says how far a
+// position is from satisfying each constraint.
+type ConstrainedFunction interface {
+	Function
+
+	// Violations returns the amount by which each constraint is violated at
+	// the given position. Satisfied constraints have zero violation.
+	Violations(vec.Vec) vec.Vec
+}
+
+// TotalViolation sums constraint violations into the single measure used to
+// compare infeasible positions. Zero means feasible.
+func TotalViolation(violations vec.Vec) float64 {
+	return violations.Sum()
+}
+
+type ConstraintFunc func(fit *Fitness, pos vec.Vec) float64
+
+// ConstrainedFitness adds constraints to a Fitness. Inequalities are satisfied
+// when g(x) <= 0, and equalities when |h(x)| <= Tolerance.
+type ConstrainedFitness struct {
+	*Fitness
+
+	Inequalities []ConstraintFunc
+	Equalities   []ConstraintFunc
+	Tolerance    float64
+}
+
+// DefaultEqualityTolerance is the usual tolerance for equality constraints in
+// the constrained optimization literature (e.g., the CEC 2006 benchmarks).
+const DefaultEqualityTolerance = 1e-4
+
+func NewConstrainedFitness(f *Fitness, inequalities, equalities []ConstraintFunc) *ConstrainedFitness {
+	return &ConstrainedFitness{
+		Fitness:      f,
+		Inequalities: inequalities,
+		Equalities:   equalities,
+		Tolerance:    DefaultEqualityTolerance,
+	}
+}
+
+func (f *ConstrainedFitness) Violations(pos vec.Vec) vec.Vec {
+	v := vec.New(len(f.Inequalities) + len(f.Equalities))
+	for i, g := range f.Inequalities {
+		v[i] = math.Max(0, g(f.Fitness, pos))
+	}
+	for i, h := range f.Equalities {
+		v[len(f.Inequalities)+i] = math.Max(0, math.Abs(h(f.Fitness, pos))-f.Tolerance)
+	}
+	return v
+}
+
+// NewG06 creates problem g06 from the CEC 2006 constrained benchmarks: a cubic
+// objective with two nonlinear inequality constraints and a tiny feasible
+// region. The optimum is -6961.81387558 at (14.095, 0.84296).
+func NewG06() *ConstrainedFitness {
+	f := NewFitness(2, vec.Vec{13, 0}, vec.Vec{100, 100}, 0, func(f *Fitness, pos vec.Vec) float64 {
+		a, b := pos[0]-10, pos[1]-20
+		return a*a*a + b*b*b
+	})
+	return NewConstrainedFitness(f, []ConstraintFunc{
+		func(f *Fitness, pos vec.Vec) float64 {
+			a, b := pos[0]-5, pos[1]-5
+			return -a*a - b*b + 100
+		},
+		func(f *Fitness, pos vec.Vec) float64 {
+			a, b := pos[0]-6, pos[1]-5
+			return a*a + b*b - 82.81
+		},
+	}, nil)
+}
+
+// NewG08 creates problem g08 from the CEC 2006 constrained benchmarks: a
+// multimodal objective with two inequality constraints. The optimum is
+// -0.0958250415 at (1.2279713, 4.2453733).
+func NewG08() *ConstrainedFitness {
+	f := NewFitnessSquareDomain(2, 0, 10, 0, func(f *Fitness, pos vec.Vec) float64 {
+		s := math.Sin(2 * math.Pi * pos[0])
+		return -s * s * s * math.Sin(2*math.Pi*pos[1]) / (pos[0] * pos[0] * pos[0] * (pos[0] + pos[1]))
+	})
+	return NewConstrainedFitness(f, []ConstraintFunc{
+		func(f *Fitness, pos vec.Vec) float64 {
+			return pos[0]*pos[0] - pos[1] + 1
+		},
+		func(f *Fitness, pos vec.Vec) float64 {
+			b := pos[1] - 4
+			return 1 - pos[0] + b*b
+		},
+	}, nil)
+}
+
+// NewG11 creates problem g11 from the CEC 2006 constrained benchmarks: a
+// parabola restricted to a curve by one equality constraint. The optimum is
+// 0.7499 at (+-1/sqrt(2), 1/2).
+func NewG11() *ConstrainedFitness {
+	f := NewFitnessSquareDomain(2, -1, 1, 0, func(f *Fitness, pos vec.Vec) float64 {
+		b := pos[1] - 1
+		return pos[0]*pos[0] + b*b
+	})
+	return NewConstrainedFitness(f, nil, []ConstraintFunc{
+		func(f *Fitness, pos vec.Vec) float64 {
+			return pos[1] - pos[0]*pos[0]
+		},
+	})
+}
@@ -0,0 +1,118 @@
+package fitness
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+// BinaryFitness is a function over bit strings, represented as positions
+// whose components are all 0 or 1. Random positions are random bit strings.
+type BinaryFitness struct {
+	*Fitness
+}
+
+func NewBinaryFitness(dims int, q QueryFunc) *BinaryFitness {
+	return &BinaryFitness{NewFitnessSquareDomain(dims, 0, 1, 0, q)}
+}
+
+func (f *BinaryFitness) RandomPos(rgen *rand.Rand) vec.Vec {
+	v := vec.New(f.dims)
+	for i := range v {
+		v[i] = float64(rgen.Intn(2))
+	}
+	return v
+}
+
+// VecInterpreter shows bit strings as strings of 0s and 1s, and anything else
+// (like velocities) as numbers.
+func (f *BinaryFitness) VecInterpreter(v vec.Vec) string {
+	b := make([]byte, len(v))
+	for i, x := range v {
+		switch x {
+		case 0:
+			b[i] = '0'
+		case 1:
+			b[i] = '1'
+		default:
+			return f.Fitness.VecInterpreter(v)
+		}
+	}
+	return string(b)
+}
+
+// countOnes counts the components of pos that are at least 1/2, so that
+// continuous positions can be evaluated as bit strings too.
+func countOnes(pos vec.Vec) int {
+	n := 0
+	for _, x := range pos {
+		if x >= 0.5 {
+			n++
+		}
+	}
+	return n
+}
+
+// NewOneMax creates the OneMax problem: the value is the number of zero bits,
+// so the optimum is 0 at all ones.
+func NewOneMax(dims int) *BinaryFitness {
+	return NewBinaryFitness(dims, func(f *Fitness, pos vec.Vec) float64 {
+		return float64(len(pos) - countOnes(pos))
+	})
+}
+
+// NewTrap creates a concatenated deceptive trap problem (Deb and Goldberg,
+// 1993) of the given number of blocks of k bits. A block with u ones
+// contributes k if u == k and k-1-u otherwise, so local information points
+// toward all zeros while the optimum is all ones. The value is the shortfall
+// from the maximum, so the optimum is 0.
+func NewTrap(blocks, k int) *BinaryFitness {
+	return NewBinaryFitness(blocks*k, func(f *Fitness, pos vec.Vec) float64 {
+		total := 0
+		for b := 0; b < blocks; b++ {
+			u := countOnes(pos[b*k : (b+1)*k])
+			if u == k {
+				total += k
+			} else {
+				total += k - 1 - u
+			}
+		}
+		return float64(blocks*k - total)
+	})
+}
+
+// NewKnapsack creates a 0/1 knapsack problem. Feasible selections have the
+// negated total value of the chosen items, and selections over capacity have
+// their (positive) excess weight, so that every feasible selection is better
+// than every infeasible one.
+func NewKnapsack(weights, values vec.Vec, capacity float64) *BinaryFitness {
+	if len(weights) != len(values) {
+		panic(fmt.Sprintf("Knapsack weights and values have different lengths: %d != %d", len(weights), len(values)))
+	}
+	return NewBinaryFitness(len(weights), func(f *Fitness, pos vec.Vec) float64 {
+		weight, value := 0.0, 0.0
+		for i, x := range pos {
+			if x >= 0.5 {
+				weight += weights[i]
+				value += values[i]
+			}
+		}
+		if weight > capacity {
+			return weight - capacity
+		}
+		return -value
+	})
+}
+
+// NewRandomKnapsack creates a knapsack problem with n items whose weights and
+// values are uniform integers in [1, 100], and a capacity of half the total
+// weight.
+func NewRandomKnapsack(n int, rgen *rand.Rand) *BinaryFitness {
+	weights, values := vec.New(n), vec.New(n)
+	for i := range weights {
+		weights[i] = float64(1 + rgen.Intn(100))
+		values[i] = float64(1 + rgen.Intn(100))
+	}
+	return NewKnapsack(weights, values, weights.Sum()/2)
+}
@@ -0,0 +1,31 @@
+package fitness
+
+import (
+	"fmt"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+func ExampleNewTrap() {
+	f := NewTrap(2, 4)
+	fmt.Println(f.Query(vec.Vec{1, 1, 1, 1, 1, 1, 1, 1}))
+	fmt.Println(f.Query(vec.Vec{0, 0, 0, 0, 0, 0, 0, 0}))
+	fmt.Println(f.Query(vec.Vec{1, 1, 1, 0, 1, 1, 1, 1}))
+
+	// Output:
+	// 0
+	// 2
+	// 4
+}
+
+func ExampleNewKnapsack() {
+	f := NewKnapsack(vec.Vec{3, 4, 5}, vec.Vec{4, 5, 7}, 8)
+	fmt.Println(f.Query(vec.Vec{1, 0, 1}))
+	fmt.Println(f.Query(vec.Vec{1, 1, 1}))
+	fmt.Println(f.VecInterpreter(vec.Vec{1, 0, 1}))
+
+	// Output:
+	// -11
+	// 4
+	// 101
+}
@@ -104,6 +104,12 @@ func LogDcauchy(x, x0, gamma float64) float64 {
 	return -Log_pi - math.Log(gamma+(d*d)/gamma)
 }
 
+// Scauchy computes a single sample from a one-dimensional Cauchy distribution
+// by inverting its CDF.
+func Scauchy(x0, gamma float64, rgen *rand.Rand) float64 {
+	return x0 + gamma*math.Tan(math.Pi*(rgen.Float64()-0.5))
+}
+
 // Ccauchy computes the CDF.
 func Ccauchy(x, x0, gamma float64) float64 {
 	return math.Atan((x-x0)/gamma)/math.Pi + .5
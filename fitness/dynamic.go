@@ -0,0 +1,253 @@
+package fitness
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+// DynamicFunction is a fitness function whose landscape can change over time.
+// Updaters call Advance between batches (never during one), with the total
+// number of evaluations so far, and the function decides whether to change.
+type DynamicFunction interface {
+	Function
+
+	// Advance lets the function change, now that evals evaluations have
+	// happened.
+	Advance(evals int)
+}
+
+// MovingPeaks is Branke's moving peaks benchmark ("Memory enhanced
+// evolutionary algorithms for changing optimization problems", 1999), with
+// cone-shaped peaks as in his scenario 2. Every Frequency evaluations, each
+// peak changes its height and width by a normally distributed amount, and
+// moves ShiftLength in a direction that is random (Lambda = 0) or correlated
+// with its previous move (up to Lambda = 1).
+//
+// The benchmark maximizes, so Query returns the negated height of the
+// landscape, and the optimum is the negated height of the highest peak.
+type MovingPeaks struct {
+	*Fitness
+
+	Frequency      int     // evaluations between changes
+	HeightSeverity float64 // standard deviation of height changes
+	WidthSeverity  float64 // standard deviation of width changes
+	ShiftLength    float64 // distance each peak moves per change
+	Lambda         float64 // correlation between successive moves
+	MinHeight      float64
+	MaxHeight      float64
+	MinWidth       float64
+	MaxWidth       float64
+
+	mu         sync.RWMutex
+	rsrc       rand.Source
+	rgen       *rand.Rand
+	heights    vec.Vec
+	widths     vec.Vec
+	peaks      []vec.Vec
+	shifts     []vec.Vec
+	changes    int
+	nextChange int
+}
+
+// NewMovingPeaks creates a moving peaks landscape on [0, 100]^dims with the
+// standard scenario 2 settings. All randomness, including the initial peak
+// positions, comes from rsrc.
+func NewMovingPeaks(dims, numPeaks, frequency int, rsrc rand.Source) *MovingPeaks {
+	mp := &MovingPeaks{
+		Frequency:      frequency,
+		HeightSeverity: 7.0,
+		WidthSeverity:  1.0,
+		ShiftLength:    1.0,
+		MinHeight:      30.0,
+		MaxHeight:      70.0,
+		MinWidth:       1.0,
+		MaxWidth:       12.0,
+		rsrc:           rsrc,
+		rgen:           rand.New(rsrc),
+		heights:        vec.New(numPeaks),
+		widths:         vec.New(numPeaks),
+		peaks:          make([]vec.Vec, numPeaks),
+		shifts:         make([]vec.Vec, numPeaks),
+		nextChange:     frequency,
+	}
+	mp.Fitness = NewFitnessSquareDomain(dims, 0, 100, 0, func(f *Fitness, pos vec.Vec) float64 {
+		mp.mu.RLock()
+		defer mp.mu.RUnlock()
+		return -mp.height(pos)
+	})
+	for i := range mp.peaks {
+		mp.heights[i] = 50.0
+		mp.widths[i] = mp.MinWidth + mp.rgen.Float64()*(mp.MaxWidth-mp.MinWidth)
+		mp.peaks[i] = UniformCubeSample(dims, 0, 100, mp.rgen)
+		mp.shifts[i] = vec.New(dims)
+	}
+	return mp
+}
+
+// height computes the landscape height at pos, which is that of the tallest
+// peak there. The caller must hold the lock.
+func (mp *MovingPeaks) height(pos vec.Vec) float64 {
+	h := math.Inf(-1)
+	for i, peak := range mp.peaks {
+		h = math.Max(h, mp.heights[i]-mp.widths[i]*pos.Sub(peak).Mag())
+	}
+	return h
+}
+
+// QueryBatch evaluates every position against the same landscape, even if
+// Advance is called concurrently.
+func (mp *MovingPeaks) QueryBatch(pos []vec.Vec) []float64 {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	vals := make([]float64, len(pos))
+	for i, p := range pos {
+		vals[i] = -mp.height(p)
+	}
+	return vals
+}
+
+// Advance changes the landscape once for every multiple of Frequency that
+// evals has reached since the last change.
+func (mp *MovingPeaks) Advance(evals int) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	for mp.Frequency > 0 && evals >= mp.nextChange {
+		mp.change()
+		mp.nextChange += mp.Frequency
+	}
+}
+
+// Changes returns the number of times the landscape has changed.
+func (mp *MovingPeaks) Changes() int {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return mp.changes
+}
+
+// movingPeaksState is the part of a MovingPeaks that changes over time.
+type movingPeaksState struct {
+	Rand       []byte
+	Heights    vec.Vec
+	Widths     vec.Vec
+	Peaks      []vec.Vec
+	Shifts     []vec.Vec
+	Changes    int
+	NextChange int
+}
+
+// MarshalBinary encodes the current landscape and, if it implements
+// encoding.BinaryMarshaler, the random source, so that a checkpointed run
+// sees the same changes when it resumes.
+func (mp *MovingPeaks) MarshalBinary() ([]byte, error) {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	s := movingPeaksState{
+		Heights:    mp.heights,
+		Widths:     mp.widths,
+		Peaks:      mp.peaks,
+		Shifts:     mp.shifts,
+		Changes:    mp.changes,
+		NextChange: mp.nextChange,
+	}
+	if m, ok := mp.rsrc.(encoding.BinaryMarshaler); ok {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		s.Rand = b
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary restores state encoded by MarshalBinary. The landscape must
+// have been created with the same dimensions and number of peaks.
+func (mp *MovingPeaks) UnmarshalBinary(b []byte) error {
+	var s movingPeaksState
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&s); err != nil {
+		return err
+	}
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	if len(s.Peaks) != len(mp.peaks) || len(s.Heights) != len(mp.peaks) || len(s.Widths) != len(mp.peaks) || len(s.Shifts) != len(mp.peaks) {
+		return fmt.Errorf("moving peaks: saved state has %d peaks, want %d", len(s.Peaks), len(mp.peaks))
+	}
+	for i := range s.Peaks {
+		if len(s.Peaks[i]) != mp.Dims() || len(s.Shifts[i]) != mp.Dims() {
+			return fmt.Errorf("moving peaks: saved state has %d dimensions, want %d", len(s.Peaks[i]), mp.Dims())
+		}
+	}
+	if len(s.Rand) > 0 {
+		um, ok := mp.rsrc.(encoding.BinaryUnmarshaler)
+		if !ok {
+			return fmt.Errorf("moving peaks: random source %T cannot restore saved state", mp.rsrc)
+		}
+		if err := um.UnmarshalBinary(s.Rand); err != nil {
+			return err
+		}
+	}
+	mp.heights, mp.widths, mp.peaks, mp.shifts = s.Heights, s.Widths, s.Peaks, s.Shifts
+	mp.changes, mp.nextChange = s.Changes, s.NextChange
+	return nil
+}
+
+// Optimum returns the current global optimum and its value.
+func (mp *MovingPeaks) Optimum() (pos vec.Vec, val float64) {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	best := 0
+	for i := range mp.peaks {
+		if mp.heights[i] > mp.heights[best] {
+			best = i
+		}
+	}
+	return mp.peaks[best].Copy(), -mp.height(mp.peaks[best])
+}
+
+// reflect bounces x back into [min, max] if it has stepped outside.
+func reflect(x, min, max float64) float64 {
+	if x < min {
+		return 2*min - x
+	}
+	if x > max {
+		return 2*max - x
+	}
+	return x
+}
+
+// change moves every peak. The caller must hold the lock.
+func (mp *MovingPeaks) change() {
+	dims := mp.Dims()
+	for i, peak := range mp.peaks {
+		mp.heights[i] = reflect(mp.heights[i]+mp.HeightSeverity*mp.rgen.NormFloat64(), mp.MinHeight, mp.MaxHeight)
+		mp.widths[i] = reflect(mp.widths[i]+mp.WidthSeverity*mp.rgen.NormFloat64(), mp.MinWidth, mp.MaxWidth)
+
+		// The new shift mixes a random direction with the previous shift, and
+		// has length ShiftLength.
+		shift := vec.NewFFilled(dims, mp.rgen.NormFloat64)
+		shift.SMulBy(mp.ShiftLength / shift.Mag())
+		shift.SMulBy(1 - mp.Lambda).AddBy(mp.shifts[i].SMul(mp.Lambda))
+		if mag := shift.Mag(); mag > 0 {
+			shift.SMulBy(mp.ShiftLength / mag)
+		}
+		for d := range peak {
+			peak[d] += shift[d]
+			if peak[d] < 0 || peak[d] > 100 {
+				peak[d] = reflect(peak[d], 0, 100)
+				shift[d] = -shift[d]
+			}
+		}
+		mp.shifts[i] = shift
+	}
+	mp.changes++
+}
@@ -9,27 +9,25 @@ import (
 	"github.com/shiblon/entrogo/vec"
 )
 
-// Function is an interface that can represent any kind of constant-dimension fitness function
-// with a concept of random domain sampling.
-type Function interface {
-	// The main entry point for asking a fitness function questions.
-	Query(vec.Vec) float64
-
+// Domain is the part of a fitness function that describes its search space,
+// independent of what is being computed there.
+type Domain interface {
 	// Produces a random position from the function's domain.
 	RandomPos(rgen *rand.Rand) vec.Vec
 
 	// Produces a random velocity suitable for exploring the function's domain.
 	RandomVel(rgen *rand.Rand) vec.Vec
 
-	// Compare two fitness values. True if (a <less fit than> b)
-	LessFit(a, b float64) bool
-
 	// A suitable size to use as a starting point for a radius calculation.
 	Diameter() float64
 
 	// SideLengths returns a vector of positive-valued lengths of the domain rectangle sides.
 	SideLengths() vec.Vec
 
+	// Bounds returns the minimum and maximum corners of the domain rectangle
+	// that RandomPos samples from.
+	Bounds() (min, max vec.Vec)
+
 	// Dims returns the number of inputs.
 	Dims() int
 
@@ -37,6 +35,18 @@ type Function interface {
 	VecInterpreter(v vec.Vec) string
 }
 
+// Function is an interface that can represent any kind of constant-dimension fitness function
+// with a concept of random domain sampling.
+type Function interface {
+	Domain
+
+	// The main entry point for asking a fitness function questions.
+	Query(vec.Vec) float64
+
+	// Compare two fitness values. True if (a <less fit than> b)
+	LessFit(a, b float64) bool
+}
+
 // UniformCubeSample samples uniformly from a cube with corners at (min, min,
 // min, ...), (max, max, max, ...).
 func UniformCubeSample(dims int, min, max float64, rgen *rand.Rand) (v vec.Vec) {
@@ -66,6 +76,7 @@ type Fitness struct {
 	sideLengths    vec.Vec
 	negSideLengths vec.Vec
 	q              QueryFunc
+	bq             BatchQueryFunc // used by Batched, if set
 
 	Center vec.Vec
 }
@@ -116,12 +127,16 @@ func (f *Fitness) SideLengths() vec.Vec {
 	return f.sideLengths
 }
 
+func (f *Fitness) Bounds() (min, max vec.Vec) {
+	return f.minCorner.Sub(f.Center), f.maxCorner.Sub(f.Center)
+}
+
 func (f *Fitness) Query(pos vec.Vec) float64 {
 	return f.q(f, pos)
 }
 
 func NewParabola(dims int, offset float64) *Fitness {
-	return NewFitnessSquareDomain(dims, -50.0, 50.0, offset, func(f *Fitness, pos vec.Vec) float64 {
+	f := NewFitnessSquareDomain(dims, -50.0, 50.0, offset, func(f *Fitness, pos vec.Vec) float64 {
 		s := 0.0
 		for i := range pos {
 			p := pos[i] - f.Center[i]
@@ -129,10 +144,12 @@ func NewParabola(dims int, offset float64) *Fitness {
 		}
 		return s
 	})
+	f.bq = parabolaBatch
+	return f
 }
 
 func NewRastrigin(dims int, offset float64) *Fitness {
-	return NewFitnessSquareDomain(dims, -5.12, 5.12, offset, func(f *Fitness, pos vec.Vec) float64 {
+	f := NewFitnessSquareDomain(dims, -5.12, 5.12, offset, func(f *Fitness, pos vec.Vec) float64 {
 		s := 10.0 * float64(f.dims)
 		for i := range pos {
 			p := pos[i] - f.Center[i]
@@ -140,10 +157,12 @@ func NewRastrigin(dims int, offset float64) *Fitness {
 		}
 		return s
 	})
+	f.bq = rastriginBatch
+	return f
 }
 
 func NewRosenbrock(dims int, offset float64) *Fitness {
-	return NewFitnessSquareDomain(dims, -100.0, 100.0, offset, func(f *Fitness, pos vec.Vec) float64 {
+	f := NewFitnessSquareDomain(dims, -100.0, 100.0, offset, func(f *Fitness, pos vec.Vec) float64 {
 		s := 0.0
 		for i := 0; i < len(pos)-1; i++ {
 			p := pos[i] - f.Center[i]
@@ -154,12 +173,14 @@ func NewRosenbrock(dims int, offset float64) *Fitness {
 		}
 		return s
 	})
+	f.bq = rosenbrockBatch
+	return f
 }
 
 func NewAckley(dims int, offset float64) *Fitness {
 	twopi := 2.0 * math.Pi
 	D := float64(dims)
-	return NewFitnessSquareDomain(dims, -5.0, 5.0, offset, func(f *Fitness, pos vec.Vec) float64 {
+	f := NewFitnessSquareDomain(dims, -5.0, 5.0, offset, func(f *Fitness, pos vec.Vec) float64 {
 		s1, s2 := 0.0, 0.0
 		for i, p := range pos {
 			p -= f.Center[i]
@@ -170,20 +191,24 @@ func NewAckley(dims int, offset float64) *Fitness {
 		s2 /= D
 		return -20.0*math.Exp(-0.2*math.Sqrt(s1)) - math.Exp(s2) + 20.0 + math.E
 	})
+	f.bq = ackleyBatch
+	return f
 }
 
 func NewDeJongF4(dims int, offset float64) *Fitness {
-	return NewFitnessSquareDomain(dims, -20.0, 20.0, offset, func(f *Fitness, pos vec.Vec) float64 {
+	f := NewFitnessSquareDomain(dims, -20.0, 20.0, offset, func(f *Fitness, pos vec.Vec) float64 {
 		s := 0.0
 		for i, x := range pos {
 			s += float64(i+1) * math.Pow(x-f.Center[i], 4)
 		}
 		return s
 	})
+	f.bq = deJongF4Batch
+	return f
 }
 
 func NewEasom(dims int, offset float64) *Fitness {
-	return NewFitnessSquareDomain(dims, -100.0, 100.0, offset, func(f *Fitness, pos vec.Vec) float64 {
+	f := NewFitnessSquareDomain(dims, -100.0, 100.0, offset, func(f *Fitness, pos vec.Vec) float64 {
 		sum := 0.0
 		prod := 1.0
 		for i, x := range pos {
@@ -193,10 +218,12 @@ func NewEasom(dims int, offset float64) *Fitness {
 		}
 		return 1.0 + math.Exp(-sum)*prod
 	})
+	f.bq = easomBatch
+	return f
 }
 
 func NewSchwefel(dims int, offset float64) *Fitness {
-	return NewFitnessSquareDomain(dims, -500.0, 500.0, offset, func(f *Fitness, pos vec.Vec) float64 {
+	f := NewFitnessSquareDomain(dims, -500.0, 500.0, offset, func(f *Fitness, pos vec.Vec) float64 {
 		sum := 0.0
 		for i, x := range pos {
 			p := x - f.Center[i]
@@ -204,4 +231,6 @@ func NewSchwefel(dims int, offset float64) *Fitness {
 		}
 		return 418.9829*float64(f.Dims()) + sum
 	})
+	f.bq = schwefelBatch
+	return f
 }
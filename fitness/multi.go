@@ -0,0 +1,107 @@
+package fitness
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+// MultiFunction is a fitness function with several competing objectives. All
+// objectives are minimized.
+type MultiFunction interface {
+	Domain
+
+	// QueryMulti returns the value of every objective at the given position.
+	QueryMulti(vec.Vec) vec.Vec
+
+	// Objectives returns the number of objectives.
+	Objectives() int
+}
+
+type MultiQueryFunc func(fit *MultiFitness, pos vec.Vec) vec.Vec
+
+// MultiFitness is a multi-objective function over a hyperrectangle.
+type MultiFitness struct {
+	domain     *Fitness
+	objectives int
+	q          MultiQueryFunc
+}
+
+func NewMultiFitness(dims, objectives int, minCorner, maxCorner vec.Vec, q MultiQueryFunc) *MultiFitness {
+	return &MultiFitness{
+		domain:     NewFitness(dims, minCorner, maxCorner, 0, nil),
+		objectives: objectives,
+		q:          q,
+	}
+}
+
+func (f *MultiFitness) QueryMulti(pos vec.Vec) vec.Vec {
+	return f.q(f, pos)
+}
+
+func (f *MultiFitness) Objectives() int {
+	return f.objectives
+}
+
+func (f *MultiFitness) RandomPos(rgen *rand.Rand) vec.Vec {
+	return f.domain.RandomPos(rgen)
+}
+
+func (f *MultiFitness) RandomVel(rgen *rand.Rand) vec.Vec {
+	return f.domain.RandomVel(rgen)
+}
+
+func (f *MultiFitness) Diameter() float64 {
+	return f.domain.Diameter()
+}
+
+func (f *MultiFitness) SideLengths() vec.Vec {
+	return f.domain.SideLengths()
+}
+
+func (f *MultiFitness) Bounds() (min, max vec.Vec) {
+	return f.domain.Bounds()
+}
+
+func (f *MultiFitness) Dims() int {
+	return f.domain.Dims()
+}
+
+func (f *MultiFitness) VecInterpreter(v vec.Vec) string {
+	return f.domain.VecInterpreter(v)
+}
+
+// zdtG is the g function shared by ZDT1-3.
+func zdtG(pos vec.Vec) float64 {
+	if len(pos) < 2 {
+		return 1.0
+	}
+	return 1.0 + 9.0*pos[1:].Sum()/float64(len(pos)-1)
+}
+
+// NewZDT1 creates the ZDT1 problem (convex front f2 = 1 - sqrt(f1)) on [0, 1]^dims.
+func NewZDT1(dims int) *MultiFitness {
+	return NewMultiFitness(dims, 2, vec.NewFilled(dims, 0), vec.NewFilled(dims, 1), func(f *MultiFitness, pos vec.Vec) vec.Vec {
+		g := zdtG(pos)
+		return vec.Vec{pos[0], g * (1 - math.Sqrt(pos[0]/g))}
+	})
+}
+
+// NewZDT2 creates the ZDT2 problem (concave front f2 = 1 - f1^2) on [0, 1]^dims.
+func NewZDT2(dims int) *MultiFitness {
+	return NewMultiFitness(dims, 2, vec.NewFilled(dims, 0), vec.NewFilled(dims, 1), func(f *MultiFitness, pos vec.Vec) vec.Vec {
+		g := zdtG(pos)
+		r := pos[0] / g
+		return vec.Vec{pos[0], g * (1 - r*r)}
+	})
+}
+
+// NewZDT3 creates the ZDT3 problem (disconnected front) on [0, 1]^dims.
+func NewZDT3(dims int) *MultiFitness {
+	return NewMultiFitness(dims, 2, vec.NewFilled(dims, 0), vec.NewFilled(dims, 1), func(f *MultiFitness, pos vec.Vec) vec.Vec {
+		g := zdtG(pos)
+		r := pos[0] / g
+		return vec.Vec{pos[0], g * (1 - math.Sqrt(r) - r*math.Sin(10*math.Pi*pos[0]))}
+	})
+}
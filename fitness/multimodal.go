@@ -0,0 +1,67 @@
+package fitness
+
+import (
+	"math"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+// Multimodal is a fitness function with several global optima, all with the
+// same value, for testing niching methods.
+type Multimodal struct {
+	*Fitness
+
+	NumOptima  int     // number of global optima
+	OptimumVal float64 // value at every global optimum
+}
+
+// NewHimmelblau creates Himmelblau's function on [-6, 6]^2, which has four
+// global minima of 0 at (3, 2), (-2.805118, 3.131312), (-3.779310, -3.283186)
+// and (3.584428, -1.848126).
+func NewHimmelblau() *Multimodal {
+	f := NewFitnessSquareDomain(2, -6, 6, 0, func(f *Fitness, pos vec.Vec) float64 {
+		x, y := pos[0], pos[1]
+		a, b := x*x+y-11, x+y*y-7
+		return a*a + b*b
+	})
+	return &Multimodal{Fitness: f, NumOptima: 4, OptimumVal: 0}
+}
+
+// NewEqualMaxima creates Deb's equal maxima function, -sin^6(5 pi x) on [0, 1],
+// which has five equally spaced global minima of -1 at 0.1, 0.3, ..., 0.9.
+func NewEqualMaxima() *Multimodal {
+	f := NewFitnessSquareDomain(1, 0, 1, 0, func(f *Fitness, pos vec.Vec) float64 {
+		return -math.Pow(math.Sin(5*math.Pi*pos[0]), 6)
+	})
+	return &Multimodal{Fitness: f, NumOptima: 5, OptimumVal: -1}
+}
+
+// NewSixHumpCamel creates the six-hump camel back function on [-1.9, 1.9] x
+// [-1.1, 1.1], which has two global minima of -1.0316285 at (0.0898, -0.7126)
+// and (-0.0898, 0.7126), and four local minima.
+func NewSixHumpCamel() *Multimodal {
+	f := NewFitness(2, vec.Vec{-1.9, -1.1}, vec.Vec{1.9, 1.1}, 0, func(f *Fitness, pos vec.Vec) float64 {
+		x, y := pos[0], pos[1]
+		x2, y2 := x*x, y*y
+		return (4-2.1*x2+x2*x2/3)*x2 + x*y + (-4+4*y2)*y2
+	})
+	return &Multimodal{Fitness: f, NumOptima: 2, OptimumVal: -1.031628453}
+}
+
+// NewShubert creates the two-dimensional Shubert function on [-10, 10]^2,
+// which has 18 global minima of -186.7309088, in nine pairs, among 760 local
+// minima.
+func NewShubert() *Multimodal {
+	f := NewFitnessSquareDomain(2, -10, 10, 0, func(f *Fitness, pos vec.Vec) float64 {
+		prod := 1.0
+		for _, x := range pos {
+			s := 0.0
+			for j := 1.0; j <= 5; j++ {
+				s += j * math.Cos((j+1)*x+j)
+			}
+			prod *= s
+		}
+		return prod
+	})
+	return &Multimodal{Fitness: f, NumOptima: 18, OptimumVal: -186.7309088}
+}
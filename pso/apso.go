@@ -0,0 +1,339 @@
+package pso
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// Coefficients are the parts of the velocity update that a Strategy controls.
+type Coefficients struct {
+	Momentum float64
+	SocConst float64
+	CogConst float64
+}
+
+// Strategy adapts the velocity update of a StandardUpdater as the run goes on.
+// When Conf.Strategy is set, its coefficients take the place of
+// Conf.Momentum, Conf.SocConst and Conf.CogConst. Everything else, such as
+// DecayAdapt, the lower bounds and Tug, still applies on top of them.
+type Strategy interface {
+	// Adapt is called before the swarm moves in every batch after the first.
+	// It returns the coefficients for the batch and the number of function
+	// evaluations it performed.
+	Adapt(u *StandardUpdater) (c Coefficients, evals int)
+}
+
+// EvolutionaryState is the phase of the search that APSO thinks the swarm is
+// in.
+type EvolutionaryState int
+
+const (
+	Exploration  EvolutionaryState = iota // spread out, looking for promising regions
+	Exploitation                          // closing in on a region around the best
+	Convergence                           // gathered around the best
+	JumpingOut                            // the best has left the rest of the swarm behind
+)
+
+func (s EvolutionaryState) String() string {
+	switch s {
+	case Exploration:
+		return "exploration"
+	case Exploitation:
+		return "exploitation"
+	case Convergence:
+		return "convergence"
+	case JumpingOut:
+		return "jumping-out"
+	}
+	return fmt.Sprintf("EvolutionaryState(%d)", int(s))
+}
+
+// Parameters of APSO from Zhan, Zhang, Li and Chung, "Adaptive Particle Swarm
+// Optimization", 2009.
+const (
+	APSOMomentum0 = 0.9 // momentum before the first estimate
+	APSOAccel0    = 2.0 // starting social and cognitive constants
+	APSOAccelMin  = 1.5 // lower bound for each constant
+	APSOAccelMax  = 2.5 // upper bound for each constant
+	APSOAccelSum  = 4.0 // upper bound for their sum
+	APSODeltaMin  = 0.05
+	APSODeltaMax  = 0.1
+	APSOSigmaMax  = 1.0
+	APSOSigmaMin  = 0.1
+)
+
+// APSO is the adaptive PSO controller. Before each batch, it works out the
+// evolutionary factor f, which is where the best particle's mean distance to
+// the others falls between the smallest and largest mean distances in the
+// swarm, classifies the swarm's state from f with fuzzy membership functions,
+// and then:
+//
+//   - sets momentum to 1/(1+1.5e^(-2.6f)), so that a spread out swarm keeps
+//     its speed and a converged one slows down,
+//   - nudges the cognitive constant up and the social one down while
+//     exploring, and the other way around while jumping out,
+//   - in convergence, tries to pull the swarm out of a local optimum with
+//     elitist learning: one random dimension of the global best is perturbed
+//     by a Gaussian whose deviation falls from SigmaMax to SigmaMin side
+//     lengths over MaxEvals evaluations. If that is fitter, the best particle
+//     moves there. Otherwise it replaces the worst particle.
+//
+// APSO keeps state between batches, so each updater needs its own.
+type APSO struct {
+	MaxEvals int     // evaluation budget of the run, for the elitist learning schedule (0 keeps SigmaMax)
+	SigmaMax float64 // starting elitist learning deviation, as a fraction of the side length
+	SigmaMin float64 // final elitist learning deviation
+
+	State  EvolutionaryState // state of the last batch
+	Factor float64           // evolutionary factor of the last batch
+	Coeffs Coefficients      // coefficients of the last batch
+
+	rsrc rand.Source
+	rgen *rand.Rand
+}
+
+// NewAPSO creates an adaptive controller with the parameters from the paper,
+// for a run of about maxEvals evaluations. Its random choices are drawn from
+// rsrc.
+func NewAPSO(rsrc rand.Source, maxEvals int) *APSO {
+	return &APSO{
+		MaxEvals: maxEvals,
+		SigmaMax: APSOSigmaMax,
+		SigmaMin: APSOSigmaMin,
+		State:    Exploration,
+		Coeffs: Coefficients{
+			Momentum: APSOMomentum0,
+			SocConst: APSOAccel0,
+			CogConst: APSOAccel0,
+		},
+		rsrc: rsrc,
+		rgen: rand.New(rsrc),
+	}
+}
+
+// Adapt estimates the state of u's swarm and returns the coefficients for it.
+func (a *APSO) Adapt(u *StandardUpdater) (Coefficients, int) {
+	a.Factor = EvolutionaryFactor(u.swarm, u.BestParticle())
+	a.State = a.classify(a.Factor)
+
+	c := &a.Coeffs
+	c.Momentum = 1 / (1 + 1.5*math.Exp(-2.6*a.Factor))
+	delta := APSODeltaMin + a.rgen.Float64()*(APSODeltaMax-APSODeltaMin)
+	switch a.State {
+	case Exploration:
+		c.CogConst += delta
+		c.SocConst -= delta
+	case Exploitation:
+		c.CogConst += delta / 2
+		c.SocConst -= delta / 2
+	case Convergence:
+		c.CogConst += delta / 2
+		c.SocConst += delta / 2
+	case JumpingOut:
+		c.CogConst -= delta
+		c.SocConst += delta
+	}
+	c.CogConst = math.Max(APSOAccelMin, math.Min(APSOAccelMax, c.CogConst))
+	c.SocConst = math.Max(APSOAccelMin, math.Min(APSOAccelMax, c.SocConst))
+	if sum := c.CogConst + c.SocConst; sum > APSOAccelSum {
+		c.CogConst *= APSOAccelSum / sum
+		c.SocConst *= APSOAccelSum / sum
+	}
+
+	evals := 0
+	if a.State == Convergence {
+		evals = a.elitistLearning(u)
+	}
+	return *c, evals
+}
+
+// elitistLearning perturbs the global best, and moves either the best or the
+// worst particle to the result.
+func (a *APSO) elitistLearning(u *StandardUpdater) int {
+	best := u.BestParticle()
+	worst := best
+	for _, p := range u.swarm {
+		if u.lessFitBest(p, worst) {
+			worst = p
+		}
+	}
+
+	sigma := a.SigmaMax
+	if a.MaxEvals > 0 {
+		progress := math.Min(1, float64(u.totalEvals)/float64(a.MaxEvals))
+		sigma -= (a.SigmaMax - a.SigmaMin) * progress
+	}
+	d := a.rgen.Intn(len(best.BestPos))
+	pos := best.BestPos.Copy()
+	pos[d] += (u.maxCorner[d] - u.minCorner[d]) * sigma * a.rgen.NormFloat64()
+	pos[d] = math.Max(u.minCorner[d], math.Min(u.maxCorner[d], pos[d]))
+	discretize(pos, u.Conf.Discrete, a.rgen)
+
+	val := u.queryAll([]vec.Vec{pos})[0]
+	viol := u.violation(pos)
+	target := worst
+	if u.LessFit(best.BestVal, best.BestViolation, val, viol) {
+		target = best
+	}
+	u.totalEvals++
+	u.observeEval(target, moveTo(target, pos, val, viol, u.LessFit))
+	return 1
+}
+
+// moveTo puts the particle at pos, keeping its velocity, and updates its best
+// if pos is fitter. Returns whether the best was updated.
+func moveTo(p *particle.Particle, pos vec.Vec, val, viol float64, lessFit func(a, aViol, b, bViol float64) bool) bool {
+	s := p.Scratch()
+	s.Pos.Replace(pos)
+	s.Vel.Replace(p.Vel)
+	s.Val, s.Violation = val, viol
+	s.Bounced, s.WallHit = false, false
+	p.UpdateCur()
+	if !lessFit(p.BestVal, p.BestViolation, p.Val, p.Violation) {
+		return false
+	}
+	p.UpdateBest()
+	return true
+}
+
+// classify picks the state with the largest membership for the evolutionary
+// factor f. Where memberships overlap, the swarm stays in its last state if
+// it can, or else moves on to the next one in the usual order (exploration,
+// exploitation, convergence, jumping out, and back to exploration).
+func (a *APSO) classify(f float64) EvolutionaryState {
+	m := StateMemberships(f)
+	if m[a.State] > 0 {
+		return a.State
+	}
+	if next := (a.State + 1) % (JumpingOut + 1); m[next] > 0 {
+		return next
+	}
+	state := Exploration
+	for s := range m {
+		if m[s] > m[state] {
+			state = EvolutionaryState(s)
+		}
+	}
+	return state
+}
+
+// StateMemberships returns the fuzzy membership of the evolutionary factor f
+// in each state, indexed by EvolutionaryState.
+func StateMemberships(f float64) [JumpingOut + 1]float64 {
+	var m [JumpingOut + 1]float64
+	switch {
+	case f <= 0.4:
+	case f <= 0.6:
+		m[Exploration] = 5*f - 2
+	case f <= 0.7:
+		m[Exploration] = 1
+	case f <= 0.8:
+		m[Exploration] = -10*f + 8
+	}
+	switch {
+	case f <= 0.2:
+	case f <= 0.3:
+		m[Exploitation] = 10*f - 2
+	case f <= 0.4:
+		m[Exploitation] = 1
+	case f <= 0.6:
+		m[Exploitation] = -5*f + 3
+	}
+	switch {
+	case f <= 0.1:
+		m[Convergence] = 1
+	case f <= 0.3:
+		m[Convergence] = -5*f + 1.5
+	}
+	switch {
+	case f <= 0.7:
+	case f <= 0.9:
+		m[JumpingOut] = 5*f - 3.5
+	default:
+		m[JumpingOut] = 1
+	}
+	return m
+}
+
+// EvolutionaryFactor computes (dg-dmin)/(dmax-dmin), where each particle's d
+// is the mean distance from its current position to those of the others, and
+// dg is that of best. It is near 0 when the swarm has gathered around best
+// and near 1 when best is far away from a crowd.
+func EvolutionaryFactor(swarm []*particle.Particle, best *particle.Particle) float64 {
+	if len(swarm) < 2 {
+		return 0
+	}
+	mean := make([]float64, len(swarm))
+	for i := range swarm {
+		for j := i + 1; j < len(swarm); j++ {
+			d := swarm[i].Pos.Dist(swarm[j].Pos)
+			mean[i] += d
+			mean[j] += d
+		}
+	}
+	dmin, dmax, dg := math.Inf(1), math.Inf(-1), 0.0
+	for i, p := range swarm {
+		mean[i] /= float64(len(swarm) - 1)
+		dmin = math.Min(dmin, mean[i])
+		dmax = math.Max(dmax, mean[i])
+		if p == best {
+			dg = mean[i]
+		}
+	}
+	if dmax == dmin {
+		return 0
+	}
+	return (dg - dmin) / (dmax - dmin)
+}
+
+// apsoState is what APSO saves in a checkpoint.
+type apsoState struct {
+	State  EvolutionaryState
+	Factor float64
+	Coeffs Coefficients
+	Rand   []byte
+}
+
+// MarshalBinary encodes the controller's state, including its random source
+// if that implements encoding.BinaryMarshaler.
+func (a *APSO) MarshalBinary() ([]byte, error) {
+	s := apsoState{State: a.State, Factor: a.Factor, Coeffs: a.Coeffs}
+	if m, ok := a.rsrc.(encoding.BinaryMarshaler); ok {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		s.Rand = b
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary restores state encoded by MarshalBinary.
+func (a *APSO) UnmarshalBinary(b []byte) error {
+	var s apsoState
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&s); err != nil {
+		return err
+	}
+	if len(s.Rand) > 0 {
+		um, ok := a.rsrc.(encoding.BinaryUnmarshaler)
+		if !ok {
+			return fmt.Errorf("apso: random source %T cannot restore saved state", a.rsrc)
+		}
+		if err := um.UnmarshalBinary(s.Rand); err != nil {
+			return err
+		}
+	}
+	a.State, a.Factor, a.Coeffs = s.State, s.Factor, s.Coeffs
+	return nil
+}
@@ -0,0 +1,103 @@
+package pso
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+func TestEvolutionaryState(t *testing.T) {
+	// Four particles on a line, 0, 1, 2 and 10: the mean distances are 13/3,
+	// 11/3, 11/3 and 27/3.
+	f := fitness.NewParabola(1, 0)
+	var swarm []*particle.Particle
+	for i, x := range []float64{0, 1, 2, 10} {
+		p := particle.NewRandomParticle(rng.New(int64(i)), i, f)
+		p.Init(vec.Vec{x}, vec.Vec{0}, f.Query(vec.Vec{x}))
+		swarm = append(swarm, p)
+	}
+	if got := EvolutionaryFactor(swarm, swarm[3]); got != 1 {
+		t.Errorf("expected factor 1 for an outlying best, got %v", got)
+	}
+	if got := EvolutionaryFactor(swarm, swarm[1]); got != 0 {
+		t.Errorf("expected factor 0 for a central best, got %v", got)
+	}
+
+	tests := []struct {
+		last EvolutionaryState
+		f    float64
+		want EvolutionaryState
+	}{
+		{Exploration, 0.05, Convergence},
+		{Exploration, 0.35, Exploitation},
+		{Convergence, 0.65, Exploration},
+		{Exploration, 0.95, JumpingOut},
+		{Exploitation, 0.5, Exploitation}, // ambiguous: stay
+		{Exploration, 0.5, Exploration},   // ambiguous: stay
+		{Exploitation, 0.25, Exploitation},
+		{Convergence, 0.25, Convergence},
+		{Exploration, 0.25, Exploitation}, // ambiguous: move on
+		{JumpingOut, 0.75, JumpingOut},
+		{Exploitation, 0.75, Exploration}, // neither: largest membership
+	}
+	for _, test := range tests {
+		a := NewAPSO(rng.New(1), 0)
+		a.State = test.last
+		if got := a.classify(test.f); got != test.want {
+			t.Errorf("f=%v after %v: expected %v, got %v", test.f, test.last, test.want, got)
+		}
+	}
+}
+
+func TestAPSO(t *testing.T) {
+	f := fitness.NewRastrigin(6, 0.25)
+	newUpdater := func() (*StandardUpdater, *APSO) {
+		conf := NewBasicConfig(rng.Streams(21))
+		a := NewAPSO(rng.Derive(21, -1), 20000)
+		conf.Strategy = a
+		return NewStandardPSO(topology.NewRing(20), f, conf), a
+	}
+
+	u, a := newUpdater()
+	seen := make(map[EvolutionaryState]bool)
+	u.Update()
+	for u.Evals() < 10000 {
+		u.Update()
+		seen[a.State] = true
+		c := a.Coeffs
+		if c.SocConst < APSOAccelMin || c.CogConst < APSOAccelMin || c.SocConst+c.CogConst > APSOAccelSum+1e-9 {
+			t.Fatalf("coefficients out of bounds: %+v", c)
+		}
+		if c.Momentum < 0.4 || c.Momentum > 0.9 {
+			t.Fatalf("momentum out of range: %+v", c)
+		}
+	}
+	if !seen[Convergence] || len(seen) < 2 {
+		t.Errorf("expected several states including convergence, got %v", seen)
+	}
+
+	// Elitist learning evaluations are counted, and the state survives a
+	// checkpoint.
+	var buf bytes.Buffer
+	if err := u.WriteCheckpoint(&buf); err != nil {
+		t.Fatalf("Failed to write checkpoint: %v", err)
+	}
+	resumed, _ := newUpdater()
+	if err := resumed.ReadCheckpoint(&buf); err != nil {
+		t.Fatalf("Failed to read checkpoint: %v", err)
+	}
+	res := Run(context.Background(), u, MaxEvals(20000))
+	resumedRes := Run(context.Background(), resumed, MaxEvals(20000))
+	if res.Evals != resumedRes.Evals || res.BestVal != resumedRes.BestVal {
+		t.Errorf("resumed run differs: %+v, %+v", res, resumedRes)
+	}
+	if res.BestVal > 10 {
+		t.Errorf("expected APSO to get close to the optimum, got %v", res.BestVal)
+	}
+}
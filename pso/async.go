@@ -0,0 +1,327 @@
+package pso
+
+import (
+	"math"
+	"sync"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// AsyncUpdater is a steady-state version of StandardUpdater for expensive
+// fitness functions. A fixed number of workers each take a particle, move it
+// using the latest personal bests of its neighbors, evaluate it, and record
+// the result, without waiting for the rest of the swarm. A slow evaluation
+// only holds up its own particle.
+//
+// Each call to Update waits for at least one evaluation to finish, and
+// returns the number that have finished since the last call. The first call
+// initializes the swarm, and waits for every initial evaluation. The topology
+// ticks once for every swarm-sized group of evaluations. Evaluations may still
+// be in flight when Update returns; Close waits for them.
+//
+// Particles are guarded by their own locks, so Swarm and BestParticle return
+// copies. Runs are not reproducible, since the order of evaluations depends on
+// timing. There is no bouncing or backward adaptation, since both need the
+// whole swarm at once, and adaptive constraint handlers are never updated.
+type AsyncUpdater struct {
+	swarmBase
+
+	Topology topology.Topology
+	Conf     *Config
+	Workers  int
+
+	locks     []sync.RWMutex
+	counts    sync.Mutex // guards the batch and evaluation counters
+	jobs      chan asyncJob
+	quit      chan bool
+	results   chan asyncResult
+	workers   sync.WaitGroup
+	running   bool
+	sinceTick int
+}
+
+type asyncJob struct {
+	pidx int
+	iter int // evaluations since initialization when the job was queued
+	init bool
+}
+
+type asyncResult struct {
+	pidx     int
+	evals    int
+	improved bool
+}
+
+// NewAsync creates an asynchronous updater that evaluates up to the given
+// number of particles at once.
+func NewAsync(t topology.Topology, f fitness.Function, c *Config, workers int) *AsyncUpdater {
+	return &AsyncUpdater{
+		swarmBase: newSwarmBase(f),
+		Topology:  t,
+		Conf:      c,
+		Workers:   workers,
+	}
+}
+
+// Swarm returns copies of all of the particles.
+func (u *AsyncUpdater) Swarm() []*particle.Particle {
+	swarm := make([]*particle.Particle, len(u.swarm))
+	for i, p := range u.swarm {
+		u.locks[i].RLock()
+		swarm[i] = p.Clone()
+		u.locks[i].RUnlock()
+	}
+	return swarm
+}
+
+// BestParticle returns a copy of the particle with the fittest BestVal.
+func (u *AsyncUpdater) BestParticle() *particle.Particle {
+	best := 0
+	for i := range u.swarm[1:] {
+		if u.topoLessFit(best, i+1) {
+			best = i + 1
+		}
+	}
+	u.locks[best].RLock()
+	defer u.locks[best].RUnlock()
+	return u.swarm[best].Clone()
+}
+
+// Batches returns the number of improved batches and the total batches. It
+// can be called from workers (e.g., by a momentum function).
+func (u *AsyncUpdater) Batches() (improved, total int) {
+	u.counts.Lock()
+	defer u.counts.Unlock()
+	return u.totalImproved, u.totalBatches
+}
+
+// Evals returns the total number of function evaluations so far.
+func (u *AsyncUpdater) Evals() int {
+	u.counts.Lock()
+	defer u.counts.Unlock()
+	return u.totalEvals
+}
+
+// count adds to the counters.
+func (u *AsyncUpdater) count(evals, batches, improved int) {
+	u.counts.Lock()
+	defer u.counts.Unlock()
+	u.totalEvals += evals
+	u.totalBatches += batches
+	u.totalImproved += improved
+}
+
+// start launches the workers.
+func (u *AsyncUpdater) start() {
+	workers := u.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	// At most one job per particle is ever outstanding, so these never block.
+	u.jobs = make(chan asyncJob, len(u.swarm))
+	u.results = make(chan asyncResult, len(u.swarm))
+	u.quit = make(chan bool)
+	for w := 0; w < workers; w++ {
+		u.workers.Add(1)
+		go func() {
+			defer u.workers.Done()
+			for job := range u.jobs {
+				select {
+				case <-u.quit:
+					// Drop jobs that haven't started.
+				default:
+					u.results <- u.run(job)
+				}
+			}
+		}()
+	}
+	u.running = true
+}
+
+// Close stops the workers after waiting for all evaluations in flight, and
+// records their results. Particles that are waiting for a worker are not
+// evaluated. A later call to Update starts the workers again.
+func (u *AsyncUpdater) Close() {
+	u.swarmBase.Close()
+	if !u.running {
+		return
+	}
+	close(u.quit)
+	close(u.jobs)
+	u.workers.Wait()
+	close(u.results)
+	for res := range u.results {
+		u.record(res)
+	}
+	u.running = false
+}
+
+// Update waits for at least one evaluation to finish, then queues the
+// evaluated particles again. The first call initializes the swarm.
+// Returns the number of function evaluations performed.
+func (u *AsyncUpdater) Update() (evals int) {
+	u.beginBatch()
+	defer func() { u.endBatch(evals) }()
+	if !u.Initialized() {
+		u.count(0, 1, 1)
+		return u.init()
+	}
+	if !u.running {
+		u.start()
+		for i := range u.swarm {
+			u.jobs <- asyncJob{pidx: i, iter: u.iterations()}
+		}
+	}
+
+	evals, improved := u.requeue(<-u.results)
+	for more := true; more; {
+		select {
+		case res := <-u.results:
+			e, imp := u.requeue(res)
+			evals += e
+			improved = improved || imp
+		default:
+			more = false
+		}
+	}
+	if improved {
+		u.count(0, 1, 1)
+	} else {
+		u.count(0, 1, 0)
+	}
+	return evals
+}
+
+// init creates the particles and evaluates them using the workers, waiting
+// for all of them before moving any.
+func (u *AsyncUpdater) init() int {
+	size := u.Topology.Size()
+	u.useConstraints(u.Conf.Constraints)
+	u.locks = make([]sync.RWMutex, size)
+	u.swarm = make([]*particle.Particle, size)
+	for i := range u.swarm {
+		u.swarm[i] = discretizeParticle(particle.NewRandomParticle(u.Conf.NewRNG(i), i, u.Fitness), u.Conf.Discrete)
+	}
+	u.start()
+	for i := range u.swarm {
+		u.jobs <- asyncJob{pidx: i, init: true}
+	}
+	for _ = range u.swarm {
+		u.record(<-u.results)
+	}
+	u.initialized = true
+	u.Topology.Tick()
+	for i := range u.swarm {
+		u.jobs <- asyncJob{pidx: i, iter: u.iterations()}
+	}
+	return size
+}
+
+// record accounts for a finished job, ticking the topology once per
+// swarm-sized group of evaluations. The particle's job is done, so the
+// observer can look at it until it is queued again.
+func (u *AsyncUpdater) record(res asyncResult) {
+	u.count(res.evals, 0, 0)
+	if res.evals > 0 {
+		u.observeEval(u.swarm[res.pidx], res.improved)
+	}
+	if u.sinceTick++; u.sinceTick >= len(u.swarm) {
+		u.sinceTick = 0
+		u.Topology.Tick()
+	}
+}
+
+// requeue records a finished job and sends its particle back to the workers.
+func (u *AsyncUpdater) requeue(res asyncResult) (evals int, improved bool) {
+	u.record(res)
+	u.jobs <- asyncJob{pidx: res.pidx, iter: u.iterations()}
+	return res.evals, res.improved
+}
+
+// run performs one job in a worker.
+func (u *AsyncUpdater) run(job asyncJob) asyncResult {
+	p := u.swarm[job.pidx]
+	lock := &u.locks[job.pidx]
+	if job.init {
+		val, viol := u.query(p.Pos), u.violation(p.Pos)
+		lock.Lock()
+		p.ResetVal(val)
+		p.ResetViolation(viol)
+		lock.Unlock()
+		return asyncResult{pidx: job.pidx, evals: 1, improved: true}
+	}
+
+	// Only this worker changes the particle, so it can read its own state
+	// without locking, and the scratch state is private to it.
+	u.moveOneParticle(job.pidx, job.iter)
+	res := asyncResult{pidx: job.pidx}
+	scratch := p.Scratch()
+	if confine := u.confiner(u.Conf.Boundary, u.Conf.Discrete); confine == nil || confine(p) {
+		scratch.Val = u.query(scratch.Pos)
+		scratch.Violation = u.violation(scratch.Pos)
+		res.evals = 1
+	} else {
+		scratch.Val = u.worst
+		scratch.Violation = 0
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	p.UpdateCur()
+	if res.evals > 0 && u.LessFit(p.BestVal, p.BestViolation, p.Val, p.Violation) {
+		p.UpdateBest()
+		res.improved = true
+	}
+	return res
+}
+
+func (u *AsyncUpdater) topoLessFit(a, b int) bool {
+	u.locks[a].RLock()
+	aVal, aViol := u.swarm[a].BestVal, u.swarm[a].BestViolation
+	u.locks[a].RUnlock()
+	u.locks[b].RLock()
+	bVal, bViol := u.swarm[b].BestVal, u.swarm[b].BestViolation
+	u.locks[b].RUnlock()
+	return u.LessFit(aVal, aViol, bVal, bViol)
+}
+
+// moveOneParticle applies the StandardUpdater equations to the particle,
+// using a copy of its informer's current and best state.
+func (u *AsyncUpdater) moveOneParticle(pidx, iter int) {
+	p := u.swarm[pidx]
+	inf := u.Topology.BestNeighbor(pidx, u.topoLessFit)
+	informer := p
+	if inf != pidx {
+		u.locks[inf].RLock()
+		informer = u.swarm[inf].Clone()
+		u.locks[inf].RUnlock()
+	}
+
+	soc, cog := u.Conf.SocConst, u.Conf.CogConst
+	socLower, cogLower := u.Conf.SocLower, u.Conf.CogLower
+	if adapt := u.Conf.DecayAdapt; adapt != 1.0 {
+		socFactor := math.Pow(adapt, float64(informer.T-informer.BestT))
+		cogFactor := math.Pow(adapt, float64(p.T-p.BestT))
+		soc *= socFactor
+		socLower *= socFactor
+		cog *= cogFactor
+		cogLower *= cogFactor
+	}
+
+	dims := len(p.Pos)
+	randSoc := vec.NewFFilled(dims, p.Rand().Float64).SMulBy(soc - socLower).SAddBy(socLower)
+	randCog := vec.NewFFilled(dims, p.Rand().Float64).SMulBy(cog - cogLower).SAddBy(cogLower)
+	acc := p.BestPos.Sub(p.Pos).MulBy(randCog).AddBy(informer.BestPos.Sub(p.Pos).MulBy(randSoc))
+
+	scratch := p.Scratch()
+	dot := p.Vel.Normalized().Dot(acc.Normalized())
+	momentum := u.Conf.Momentum(u, iter, p.Id) * u.Conf.Tug(p, dot)
+	scratch.Vel.Replace(p.Vel).SMulBy(momentum).AddBy(acc)
+	u.capVelocity(scratch.Vel, u.Conf.VelCapMultiplier)
+	scratch.Pos.Replace(p.Pos).AddBy(scratch.Vel)
+	scratch.Bounced = false
+	scratch.WallHit = false
+}
@@ -0,0 +1,98 @@
+package pso
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+func TestAsyncConverges(t *testing.T) {
+	f := fitness.NewParabola(5, 0.25)
+	conf := NewBasicConfig(rng.Streams(1))
+	conf.Momentum0 = 0.7298
+	conf.SocConst, conf.CogConst = 1.49618, 1.49618
+	u := NewAsync(topology.NewRing(20), f, conf, 4)
+	res := Run(context.Background(), u, MaxEvals(20000))
+	u.Close()
+
+	if res.BestVal > 1e-6 {
+		t.Errorf("expected best value near 0, got %v", res.BestVal)
+	}
+	if u.Evals() < 20000 {
+		t.Errorf("expected at least 20000 evals, got %v", u.Evals())
+	}
+	for _, p := range u.Swarm() {
+		if got := f.Query(p.BestPos); got != p.BestVal {
+			t.Errorf("particle %d has best value %v, but %v at its best position", p.Id, p.BestVal, got)
+		}
+	}
+}
+
+func TestAsyncMomentumIter(t *testing.T) {
+	const size = 10
+	conf := NewBasicConfig(rng.Streams(1))
+	var (
+		mu    sync.Mutex
+		iters []int
+	)
+	conf.Momentum = func(u Updater, iter int, particle int) float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		iters = append(iters, iter)
+		return conf.Momentum0
+	}
+	u := NewAsync(topology.NewRing(size), fitness.NewParabola(2, 0), conf, 4)
+	Run(context.Background(), u, MaxEvals(200))
+	u.Close()
+
+	// As with StandardUpdater, schedules count evaluations from the end of
+	// initialization, so the first move of every particle is at 0.
+	mu.Lock()
+	defer mu.Unlock()
+	zeros := 0
+	for _, iter := range iters {
+		if iter == 0 {
+			zeros++
+		}
+		if iter < 0 || iter > u.Evals()-size {
+			t.Errorf("momentum at iteration %d, outside of [0, %d]", iter, u.Evals()-size)
+		}
+	}
+	if zeros != size {
+		t.Errorf("expected %d moves at iteration 0, got %d", size, zeros)
+	}
+}
+
+func TestAsyncSlowEvaluationDoesNotBlock(t *testing.T) {
+	const size = 10
+	var calls int64
+	block := make(chan bool)
+	parabola := fitness.NewParabola(2, 0)
+	f := fitness.NewFitnessSquareDomain(2, -50, 50, 0, func(f *fitness.Fitness, pos vec.Vec) float64 {
+		// The first evaluation after initialization never finishes until the
+		// test says so.
+		if atomic.AddInt64(&calls, 1) == size+1 {
+			<-block
+		}
+		return parabola.Query(pos)
+	})
+
+	u := NewAsync(topology.NewStar(size), f, NewBasicConfig(rng.Streams(2)), 3)
+	evals := 0
+	for evals < 50*size {
+		evals += u.Update()
+	}
+	close(block)
+	u.Close()
+	// Close waits for the blocked evaluation, and for any others in flight
+	// (one per worker at most).
+	if got := u.Evals(); got < evals+1 || got > evals+3 {
+		t.Errorf("expected %d to %d evals after the blocked one finished, got %d", evals+1, evals+3, got)
+	}
+}
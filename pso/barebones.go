@@ -0,0 +1,110 @@
+package pso
+
+import (
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/topology"
+)
+
+// BareBonesDist selects the sampling distribution for bare-bones PSO.
+type BareBonesDist int
+
+const (
+	BareBonesGaussian BareBonesDist = iota // normal, as in Kennedy's original
+	BareBonesCauchy                        // heavy-tailed variant
+)
+
+// BareBonesUpdater implements Kennedy's bare-bones PSO ("Bare Bones Particle
+// Swarms", 2003). There is no velocity: every coordinate of a particle's new
+// position is sampled from a distribution centered halfway between its
+// personal best and its best neighbor's best, with a spread equal to the
+// distance between them. Scratch velocities still record the displacement,
+// so that observers and swarm statistics see how far particles moved. There
+// is no bouncing, so RadiusMultiplier and Collisions are ignored.
+//
+// From Conf, it uses NewRNG, Boundary, Discrete, Constraints and Concurrency.
+type BareBonesUpdater struct {
+	swarmBase
+
+	Topology topology.Topology
+	Conf     *Config
+	Dist     BareBonesDist
+
+	// Exploit is the probability that a coordinate simply keeps the
+	// personal best value instead of being sampled. Kennedy's "BBExp" variant
+	// uses 0.5.
+	Exploit float64
+}
+
+// NewBareBones creates a bare-bones updater using the given distribution.
+func NewBareBones(t topology.Topology, f fitness.Function, c *Config, dist BareBonesDist) *BareBonesUpdater {
+	return &BareBonesUpdater{
+		swarmBase: newSwarmBase(f),
+		Topology:  t,
+		Conf:      c,
+		Dist:      dist,
+	}
+}
+
+func (u *BareBonesUpdater) init() int {
+	u.useConstraints(u.Conf.Constraints)
+	return u.initSwarm(u.Topology.Size(), func(i int) *particle.Particle {
+		return discretizeParticle(particle.NewRandomParticle(u.Conf.NewRNG(i), i, u.Fitness), u.Conf.Discrete)
+	})
+}
+
+// Update moves the swarm from one time slice to another. The first call moves
+// the swarm to t[0] by initializing it. After that it ticks the clock with each call.
+// Returns the number of function evaluations performed.
+func (u *BareBonesUpdater) Update() (evals int) {
+	u.beginBatch()
+	defer func() { u.endBatch(evals) }()
+	improved := false
+	defer func() {
+		u.Topology.Tick()
+		u.updateConstraints()
+		u.countBatch(improved)
+	}()
+	u.limitConcurrency(u.Conf.Concurrency)
+	if !u.Initialized() {
+		improved = true
+		return u.init()
+	}
+
+	u.moveAll(u.moveOneParticle)
+	evals, improved = u.evaluateAll(u.confiner(u.Conf.Boundary, u.Conf.Discrete))
+	return evals
+}
+
+func (u *BareBonesUpdater) topoLessFit(a, b int) bool {
+	return u.lessFitBest(u.swarm[a], u.swarm[b])
+}
+
+func (u *BareBonesUpdater) moveOneParticle(pidx int) {
+	p := u.swarm[pidx]
+	informer := u.swarm[u.Topology.BestNeighbor(pidx, u.topoLessFit)]
+	rgen := p.Rand()
+
+	scratch := p.Scratch()
+	for d, best := range p.BestPos {
+		if u.Exploit > 0 && rgen.Float64() < u.Exploit {
+			scratch.Pos[d] = best
+			continue
+		}
+		other := informer.BestPos[d]
+		mid := (best + other) / 2
+		spread := best - other
+		if spread < 0 {
+			spread = -spread
+		}
+		switch u.Dist {
+		case BareBonesCauchy:
+			scratch.Pos[d] = fitness.Scauchy(mid, spread, rgen)
+		default:
+			scratch.Pos[d] = fitness.Snorm(mid, spread, rgen)
+		}
+	}
+	scratch.Vel.Replace(scratch.Pos).SubBy(p.Pos)
+	scratch.Bounced = false
+	scratch.WallHit = false
+}
@@ -0,0 +1,31 @@
+package pso
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+)
+
+func TestBareBonesConverges(t *testing.T) {
+	tests := []struct {
+		name    string
+		dist    BareBonesDist
+		exploit float64
+	}{
+		{"gaussian", BareBonesGaussian, 0},
+		{"gaussian exploit", BareBonesGaussian, 0.5},
+		{"cauchy", BareBonesCauchy, 0},
+	}
+	for _, test := range tests {
+		f := fitness.NewParabola(10, 0.25)
+		u := NewBareBones(topology.NewRing(20), f, NewBasicConfig(rng.Streams(7)), test.dist)
+		u.Exploit = test.exploit
+		res := Run(context.Background(), u, MaxEvals(40000))
+		if res.BestVal > 1e-3 {
+			t.Errorf("%s: expected best <= 1e-3 after %d evals, got %v", test.name, res.Evals, res.BestVal)
+		}
+	}
+}
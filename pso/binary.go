@@ -0,0 +1,111 @@
+package pso
+
+import (
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// BinaryUpdater implements Kennedy and Eberhart's binary PSO ("A Discrete
+// Binary Version of the Particle Swarm Algorithm", 1997). Velocities are
+// updated as usual, but each coordinate of the new position is a bit that is
+// set with probability equal to the sigmoid of the corresponding velocity.
+// Positions are always vectors of 0s and 1s, which suits fitness functions like
+// fitness.BinaryFitness.
+//
+// From Conf, it uses NewRNG, Constraints and Concurrency.
+type BinaryUpdater struct {
+	swarmBase
+
+	Topology topology.Topology
+	Conf     *Config
+	W        float64 // inertia weight
+	C1, C2   float64 // cognitive and social constants
+	VMax     float64 // velocity limit, which keeps bit probabilities away from 0 and 1
+}
+
+// NewBinary creates a binary PSO updater with the original parameters: no
+// inertia damping, acceleration constants of 2, and a velocity limit of 4.
+func NewBinary(t topology.Topology, f fitness.Function, c *Config) *BinaryUpdater {
+	return &BinaryUpdater{
+		swarmBase: newSwarmBase(f),
+		Topology:  t,
+		Conf:      c,
+		W:         1.0,
+		C1:        2.0,
+		C2:        2.0,
+		VMax:      4.0,
+	}
+}
+
+// init creates particles at random bit strings, with velocities drawn
+// uniformly from [-VMax, VMax].
+func (u *BinaryUpdater) init() int {
+	u.useConstraints(u.Conf.Constraints)
+	return u.initSwarm(u.Topology.Size(), func(i int) *particle.Particle {
+		p := particle.NewRandomParticle(u.Conf.NewRNG(i), i, u.Fitness)
+		for d := range p.Pos {
+			p.Pos[d] = float64(p.Rand().Intn(2))
+			p.Vel[d] = u.VMax * (2*p.Rand().Float64() - 1)
+		}
+		p.BestPos.Replace(p.Pos)
+		p.Scratch().Pos.Replace(p.Pos)
+		p.Scratch().Vel.Replace(p.Vel)
+		return p
+	})
+}
+
+// Update moves the swarm from one time slice to another. The first call moves
+// the swarm to t[0] by initializing it. After that it ticks the clock with each call.
+// Returns the number of function evaluations performed.
+func (u *BinaryUpdater) Update() (evals int) {
+	u.beginBatch()
+	defer func() { u.endBatch(evals) }()
+	improved := false
+	defer func() {
+		u.Topology.Tick()
+		u.updateConstraints()
+		u.countBatch(improved)
+	}()
+	u.limitConcurrency(u.Conf.Concurrency)
+	if !u.Initialized() {
+		improved = true
+		return u.init()
+	}
+
+	u.moveAll(u.moveOneParticle)
+	evals, improved = u.evaluateAll(nil)
+	return evals
+}
+
+func (u *BinaryUpdater) topoLessFit(a, b int) bool {
+	return u.lessFitBest(u.swarm[a], u.swarm[b])
+}
+
+func (u *BinaryUpdater) moveOneParticle(pidx int) {
+	p := u.swarm[pidx]
+	informer := u.swarm[u.Topology.BestNeighbor(pidx, u.topoLessFit)]
+	dims := len(p.Pos)
+
+	rCog := vec.NewFFilled(dims, p.Rand().Float64).SMulBy(u.C1)
+	rSoc := vec.NewFFilled(dims, p.Rand().Float64).SMulBy(u.C2)
+	acc := p.BestPos.Sub(p.Pos).MulBy(rCog).AddBy(informer.BestPos.Sub(p.Pos).MulBy(rSoc))
+
+	scratch := p.Scratch()
+	scratch.Vel.Replace(p.Vel).SMulBy(u.W).AddBy(acc)
+	for d, v := range scratch.Vel {
+		if v > u.VMax {
+			v = u.VMax
+		} else if v < -u.VMax {
+			v = -u.VMax
+		}
+		scratch.Vel[d] = v
+		scratch.Pos[d] = 0
+		if p.Rand().Float64() < fitness.Sigmoid(v, 0, 1, 1) {
+			scratch.Pos[d] = 1
+		}
+	}
+	scratch.Bounced = false
+	scratch.WallHit = false
+}
@@ -0,0 +1,82 @@
+package pso
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// knapsackOptimum finds the best knapsack value by trying every selection.
+func knapsackOptimum(f fitness.Function, n int) float64 {
+	best := math.Inf(1)
+	pos := vec.New(n)
+	for bits := 0; bits < 1<<uint(n); bits++ {
+		for i := range pos {
+			pos[i] = float64((bits >> uint(i)) & 1)
+		}
+		best = math.Min(best, f.Query(pos))
+	}
+	return best
+}
+
+func TestBinarySolvesDiscreteProblems(t *testing.T) {
+	problems := []struct {
+		name  string
+		f     fitness.Function
+		opt   float64
+		evals int
+	}{
+		{"onemax", fitness.NewOneMax(40), 0, 20000},
+		{"knapsack", fitness.NewRandomKnapsack(16, rand.New(rng.New(5))), 0, 20000},
+	}
+	problems[1].opt = knapsackOptimum(problems[1].f, 16)
+
+	for _, prob := range problems {
+		u := NewBinary(topology.NewStar(20), prob.f, NewBasicConfig(rng.Streams(2)))
+		res := Run(context.Background(), u, MaxEvals(prob.evals), TargetVal(prob.f, prob.opt))
+		if res.BestVal != prob.opt {
+			t.Errorf("%s: expected optimum %v, got %v", prob.name, prob.opt, res.BestVal)
+		}
+		for _, p := range u.Swarm() {
+			for _, x := range p.Pos {
+				if x != 0 && x != 1 {
+					t.Fatalf("%s: particle %d has non-binary position %v", prob.name, p.Id, p.Pos)
+				}
+			}
+		}
+	}
+}
+
+func TestDiscreteRounding(t *testing.T) {
+	// The parabola's optimum is at 25 in every dimension, which integer
+	// positions can hit exactly.
+	f := fitness.NewParabola(5, 0.25)
+	for _, mode := range []Discretization{DiscreteRound, DiscreteProbRound} {
+		conf := NewBasicConfig(rng.Streams(4))
+		conf.Discrete = AllDiscrete(f.Dims(), mode)
+		conf.Discrete[4] = DiscreteNone
+		conf.RadiusMultiplier = 0
+		conf.Momentum0 = 0.7298
+		conf.SocConst, conf.CogConst = 1.49618, 1.49618
+		u := NewStandardPSO(topology.NewRing(20), f, conf)
+		Run(context.Background(), u, MaxEvals(10000))
+
+		for _, p := range u.Swarm() {
+			for d, x := range p.Pos[:4] {
+				if x != math.Floor(x) {
+					t.Fatalf("mode %v: particle %d has non-integer coordinate %d: %v", mode, p.Id, d, x)
+				}
+			}
+		}
+		best := u.BestParticle()
+		if best.BestVal > 1e-3 {
+			t.Errorf("mode %v: expected best near 0, got %v at %v", mode, best.BestVal, best.BestPos)
+		}
+	}
+}
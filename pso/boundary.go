@@ -0,0 +1,125 @@
+package pso
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// BoundaryPolicy keeps a particle's proposed (scratch) state inside the domain
+// rectangle given by its min and max corners. It is applied after the
+// particle has moved (and bounced), right before its new position is
+// evaluated. It returns the number of dimensions in which the particle ran
+// into a wall, and whether the resulting position should be evaluated at all.
+// The random source belongs to the particle.
+type BoundaryPolicy func(s *particle.TempParticleState, min, max vec.Vec, rgen *rand.Rand) (hits int, evaluate bool)
+
+// ClampBoundary stops particles at the wall, leaving velocity alone.
+func ClampBoundary(s *particle.TempParticleState, min, max vec.Vec, rgen *rand.Rand) (hits int, evaluate bool) {
+	for i, x := range s.Pos {
+		if x < min[i] {
+			s.Pos[i] = min[i]
+			hits++
+		} else if x > max[i] {
+			s.Pos[i] = max[i]
+			hits++
+		}
+	}
+	return hits, true
+}
+
+// AbsorbBoundary stops particles at the wall and zeroes the velocity in every
+// dimension that hit it.
+func AbsorbBoundary(s *particle.TempParticleState, min, max vec.Vec, rgen *rand.Rand) (hits int, evaluate bool) {
+	for i, x := range s.Pos {
+		if x < min[i] {
+			s.Pos[i] = min[i]
+		} else if x > max[i] {
+			s.Pos[i] = max[i]
+		} else {
+			continue
+		}
+		s.Vel[i] = 0
+		hits++
+	}
+	return hits, true
+}
+
+// ReflectBoundary mirrors particles back into the domain at the wall they
+// crossed, and reverses the velocity in that dimension. Particles that went
+// out by more than a whole side length keep reflecting between the walls, and
+// after an even number of reflections their velocity points the way it did.
+func ReflectBoundary(s *particle.TempParticleState, min, max vec.Vec, rgen *rand.Rand) (hits int, evaluate bool) {
+	for i, x := range s.Pos {
+		if x >= min[i] && x <= max[i] {
+			continue
+		}
+		side := max[i] - min[i]
+		// Fold into a period of two side lengths, then mirror the second half.
+		d := math.Mod(x-min[i], 2*side)
+		if d < 0 {
+			d += 2 * side
+		}
+		if d > side {
+			d = 2*side - d
+			s.Vel[i] = -s.Vel[i]
+		}
+		s.Pos[i] = min[i] + d
+		hits++
+	}
+	return hits, true
+}
+
+// WrapBoundary treats the domain as periodic: leaving through one wall means
+// entering through the opposite one.
+func WrapBoundary(s *particle.TempParticleState, min, max vec.Vec, rgen *rand.Rand) (hits int, evaluate bool) {
+	for i, x := range s.Pos {
+		if x >= min[i] && x <= max[i] {
+			continue
+		}
+		side := max[i] - min[i]
+		d := math.Mod(x-min[i], side)
+		if d < 0 {
+			d += side
+		}
+		s.Pos[i] = min[i] + d
+		hits++
+	}
+	return hits, true
+}
+
+// RandomBoundary places every out-of-bounds coordinate at a uniformly random
+// location between the walls.
+func RandomBoundary(s *particle.TempParticleState, min, max vec.Vec, rgen *rand.Rand) (hits int, evaluate bool) {
+	for i, x := range s.Pos {
+		if x < min[i] || x > max[i] {
+			s.Pos[i] = min[i] + rgen.Float64()*(max[i]-min[i])
+			hits++
+		}
+	}
+	return hits, true
+}
+
+// InfinityBoundary lets particles fly wherever they like, but positions
+// outside of the domain are never evaluated. They get the worst possible
+// value instead, so they can never become a personal best, and the pull of
+// the bests eventually brings the particle back.
+func InfinityBoundary(s *particle.TempParticleState, min, max vec.Vec, rgen *rand.Rand) (hits int, evaluate bool) {
+	for i, x := range s.Pos {
+		if x < min[i] || x > max[i] {
+			hits++
+		}
+	}
+	return hits, hits == 0
+}
+
+// worstVal returns the least fit value possible for the function.
+func worstVal(f fitness.Function) float64 {
+	if f.LessFit(math.Inf(1), math.Inf(-1)) {
+		return math.Inf(1)
+	}
+	return math.Inf(-1)
+}
@@ -0,0 +1,55 @@
+package pso
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/vec"
+)
+
+func TestBoundaryPolicies(t *testing.T) {
+	min, max := vec.Vec{0, 0, 0}, vec.Vec{10, 10, 10}
+	tests := []struct {
+		name     string
+		policy   BoundaryPolicy
+		pos, vel vec.Vec
+		wantPos  vec.Vec
+		wantVel  vec.Vec
+		wantHits int
+		wantEval bool
+	}{
+		{"clamp", ClampBoundary, vec.Vec{-1, 5, 12}, vec.Vec{-2, 1, 3}, vec.Vec{0, 5, 10}, vec.Vec{-2, 1, 3}, 2, true},
+		{"absorb", AbsorbBoundary, vec.Vec{-1, 5, 12}, vec.Vec{-2, 1, 3}, vec.Vec{0, 5, 10}, vec.Vec{0, 1, 0}, 2, true},
+		{"reflect", ReflectBoundary, vec.Vec{-1, 5, 12}, vec.Vec{-2, 1, 3}, vec.Vec{1, 5, 8}, vec.Vec{2, 1, -3}, 2, true},
+		{"reflect far", ReflectBoundary, vec.Vec{-13, 5, 33}, vec.Vec{-2, 1, 3}, vec.Vec{7, 5, 7}, vec.Vec{-2, 1, -3}, 2, true},
+		{"reflect thrice", ReflectBoundary, vec.Vec{-23, 5, 5}, vec.Vec{-2, 1, 3}, vec.Vec{3, 5, 5}, vec.Vec{2, 1, 3}, 1, true},
+		{"wrap", WrapBoundary, vec.Vec{-1, 5, 12}, vec.Vec{-2, 1, 3}, vec.Vec{9, 5, 2}, vec.Vec{-2, 1, 3}, 2, true},
+		{"infinity inside", InfinityBoundary, vec.Vec{1, 5, 9}, vec.Vec{-2, 1, 3}, vec.Vec{1, 5, 9}, vec.Vec{-2, 1, 3}, 0, true},
+		{"infinity outside", InfinityBoundary, vec.Vec{-1, 5, 12}, vec.Vec{-2, 1, 3}, vec.Vec{-1, 5, 12}, vec.Vec{-2, 1, 3}, 2, false},
+	}
+	for _, test := range tests {
+		s := &particle.TempParticleState{Pos: test.pos.Copy(), Vel: test.vel.Copy()}
+		hits, eval := test.policy(s, min, max, rand.New(rand.NewSource(1)))
+		if hits != test.wantHits || eval != test.wantEval {
+			t.Errorf("%s: expected (%d, %v), got (%d, %v)", test.name, test.wantHits, test.wantEval, hits, eval)
+		}
+		if s.Pos.Sub(test.wantPos).Mag() > 1e-12 || s.Vel.Sub(test.wantVel).Mag() > 1e-12 {
+			t.Errorf("%s: expected pos=%v vel=%v, got pos=%v vel=%v", test.name, test.wantPos, test.wantVel, s.Pos, s.Vel)
+		}
+	}
+}
+
+func TestRandomBoundaryStaysInside(t *testing.T) {
+	min, max := vec.Vec{0, 0}, vec.Vec{1, 1}
+	rgen := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		s := &particle.TempParticleState{Pos: vec.Vec{-5, 0.5}, Vel: vec.Vec{1, 1}}
+		if hits, _ := RandomBoundary(s, min, max, rgen); hits != 1 {
+			t.Fatalf("expected 1 hit, got %d", hits)
+		}
+		if s.Pos[0] < 0 || s.Pos[0] > 1 || s.Pos[1] != 0.5 {
+			t.Fatalf("unexpected position after random reinitialization: %v", s.Pos)
+		}
+	}
+}
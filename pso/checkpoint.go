@@ -0,0 +1,272 @@
+package pso
+
+import (
+	"encoding"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/shiblon/entrogo/pso/particle"
+)
+
+const (
+	checkpointMagic   = "entrogo-pso-checkpoint"
+	checkpointVersion = 1
+)
+
+// checkpointHeader starts every checkpoint. It is decoded on its own so that
+// an incompatible version can be rejected before the rest is read.
+type checkpointHeader struct {
+	Magic   string
+	Version int
+}
+
+// checkpoint is the full (version 1) state of a StandardUpdater.
+type checkpoint struct {
+	Evals       int
+	Batches     int
+	Improved    int
+	Changes     int      // landscape changes detected so far
+	Observed    observed // what observers have been told, so events carry on
+	Particles   []*particle.Snapshot
+	Fitness     []byte
+	Topology    []byte
+	Constraints []byte
+	Strategy    []byte
+}
+
+// observed is the part of an observation that outlives the observer.
+type observed struct {
+	Batches       int
+	SeenBest      bool
+	BestVal       float64
+	BestViolation float64
+}
+
+// WriteCheckpoint writes the complete swarm state to w. This includes every
+// particle (with its random source), the batch counters, what observers have
+// been told (so that batch numbers and global bests carry on), the number of
+// detected landscape changes, and the state of the fitness function,
+// topology, constraint handler and strategy if they implement
+// encoding.BinaryMarshaler (as fitness.MovingPeaks does).
+//
+// Configuration functions (momentum, tug, etc.) are not saved: the updater
+// that reads the checkpoint must be created with the same topology, fitness
+// function and configuration, and any state hidden in those functions starts
+// over. A run whose momentum schedule adapts to the swarm (such as
+// momentum.RandExplore) therefore does not resume exactly.
+func (u *StandardUpdater) WriteCheckpoint(w io.Writer) error {
+	if !u.initialized {
+		return fmt.Errorf("checkpoint: swarm is not initialized")
+	}
+	cp := checkpoint{
+		Evals:    u.totalEvals,
+		Batches:  u.totalBatches,
+		Improved: u.totalImproved,
+		Changes:  u.changes,
+	}
+	u.obs.Lock()
+	cp.Observed = observed{
+		Batches:       u.obs.batches,
+		SeenBest:      u.obs.seenBest,
+		BestVal:       u.obs.bestVal,
+		BestViolation: u.obs.bestViolation,
+	}
+	u.obs.Unlock()
+	for _, p := range u.swarm {
+		snap, err := p.Snapshot()
+		if err != nil {
+			return fmt.Errorf("checkpoint: %v", err)
+		}
+		cp.Particles = append(cp.Particles, snap)
+	}
+	if m, ok := u.Fitness.(encoding.BinaryMarshaler); ok {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("checkpoint fitness: %v", err)
+		}
+		cp.Fitness = b
+	}
+	if m, ok := u.Topology.(encoding.BinaryMarshaler); ok {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("checkpoint topology: %v", err)
+		}
+		cp.Topology = b
+	}
+	if m, ok := u.Conf.Constraints.(encoding.BinaryMarshaler); ok {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("checkpoint constraints: %v", err)
+		}
+		cp.Constraints = b
+	}
+	if m, ok := u.Conf.Strategy.(encoding.BinaryMarshaler); ok {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("checkpoint strategy: %v", err)
+		}
+		cp.Strategy = b
+	}
+
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(checkpointHeader{Magic: checkpointMagic, Version: checkpointVersion}); err != nil {
+		return fmt.Errorf("checkpoint header: %v", err)
+	}
+	if err := enc.Encode(cp); err != nil {
+		return fmt.Errorf("checkpoint: %v", err)
+	}
+	return nil
+}
+
+// ReadCheckpoint replaces the swarm state with one written by
+// WriteCheckpoint. Particle random sources are created with Conf.NewRNG and
+// then overwritten with the saved state. If the checkpoint cannot be read, the
+// updater, its fitness function, topology, constraint handler and strategy are
+// left as they were.
+func (u *StandardUpdater) ReadCheckpoint(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	var hdr checkpointHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return fmt.Errorf("read checkpoint header: %v", err)
+	}
+	if hdr.Magic != checkpointMagic {
+		return fmt.Errorf("read checkpoint: not a checkpoint (magic %q)", hdr.Magic)
+	}
+	if hdr.Version != checkpointVersion {
+		return fmt.Errorf("read checkpoint: unsupported version %d (want %d)", hdr.Version, checkpointVersion)
+	}
+	var cp checkpoint
+	if err := dec.Decode(&cp); err != nil {
+		return fmt.Errorf("read checkpoint: %v", err)
+	}
+	if len(cp.Particles) != u.Topology.Size() {
+		return fmt.Errorf("read checkpoint: %d particles, topology wants %d", len(cp.Particles), u.Topology.Size())
+	}
+
+	swarm := make([]*particle.Particle, len(cp.Particles))
+	for i, snap := range cp.Particles {
+		if snap.Id != i {
+			return fmt.Errorf("read checkpoint: particle at index %d has Id %d", i, snap.Id)
+		}
+		p, err := particle.NewParticleFromSnapshot(snap, u.Conf.NewRNG(i), u.Fitness)
+		if err != nil {
+			return fmt.Errorf("read checkpoint: %v", err)
+		}
+		swarm[i] = p
+	}
+	// Saved state is restored into the live objects, so every one of them is
+	// checked first, and its current state kept so that it can be put back
+	// if a later one fails. The updater is only changed once all have worked.
+	parts := []*savedState{
+		{label: "fitness", desc: "fitness function", obj: u.Fitness, data: cp.Fitness},
+		{label: "topology", desc: "topology", obj: u.Topology, data: cp.Topology},
+		{label: "constraints", desc: "constraint handler", obj: u.Conf.Constraints, data: cp.Constraints},
+		{label: "strategy", desc: "strategy", obj: u.Conf.Strategy, data: cp.Strategy},
+	}
+	for _, part := range parts {
+		if err := part.prepare(); err != nil {
+			return err
+		}
+	}
+	for i, part := range parts {
+		if err := part.restore(); err != nil {
+			for _, done := range parts[:i] {
+				done.undo()
+			}
+			return err
+		}
+	}
+
+	u.useConstraints(u.Conf.Constraints)
+	u.swarm = swarm
+	u.totalEvals = cp.Evals
+	u.totalBatches = cp.Batches
+	u.totalImproved = cp.Improved
+	u.changes = cp.Changes
+	u.obs.Lock()
+	u.obs.batches = cp.Observed.Batches
+	u.obs.seenBest = cp.Observed.SeenBest
+	u.obs.bestVal, u.obs.bestViolation = cp.Observed.BestVal, cp.Observed.BestViolation
+	u.obs.Unlock()
+	u.initialized = true
+	return nil
+}
+
+// savedState is the checkpointed state of a part of the updater, such as its
+// fitness function, to be restored with encoding.BinaryUnmarshaler.
+type savedState struct {
+	label string      // names the saved state in errors
+	desc  string      // names the object in errors
+	obj   interface{} // the live object
+	data  []byte      // state from the checkpoint, if any
+	prev  []byte      // state of obj before restoring data
+}
+
+// prepare checks that obj can restore the saved state, and keeps its current
+// state so that undo can put it back.
+func (s *savedState) prepare() error {
+	if len(s.data) == 0 {
+		return nil
+	}
+	_, um := s.obj.(encoding.BinaryUnmarshaler)
+	m, ok := s.obj.(encoding.BinaryMarshaler)
+	if !um || !ok {
+		return fmt.Errorf("read checkpoint: %s %T cannot restore saved state", s.desc, s.obj)
+	}
+	prev, err := m.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("read checkpoint %s: %v", s.label, err)
+	}
+	s.prev = prev
+	return nil
+}
+
+// restore gives obj the saved state.
+func (s *savedState) restore() error {
+	if len(s.data) == 0 {
+		return nil
+	}
+	if err := s.obj.(encoding.BinaryUnmarshaler).UnmarshalBinary(s.data); err != nil {
+		return fmt.Errorf("read checkpoint %s: %v", s.label, err)
+	}
+	return nil
+}
+
+// undo puts back the state that obj had before restore.
+func (s *savedState) undo() {
+	if len(s.data) > 0 {
+		s.obj.(encoding.BinaryUnmarshaler).UnmarshalBinary(s.prev)
+	}
+}
+
+// SaveCheckpoint writes a checkpoint to the named file. The file is replaced
+// atomically, so an interrupted save leaves any previous checkpoint intact.
+func (u *StandardUpdater) SaveCheckpoint(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := u.WriteCheckpoint(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadCheckpoint reads a checkpoint from the named file.
+func (u *StandardUpdater) LoadCheckpoint(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return u.ReadCheckpoint(f)
+}
@@ -0,0 +1,289 @@
+package pso
+
+import (
+	"math"
+	"runtime"
+	"sort"
+
+	"github.com/shiblon/entrogo/pso/grid"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// CollisionPolicy responds to particles that have come too close together.
+// It is called after the swarm moves and before it is evaluated, so each
+// particle's new position and velocity are in its scratch state. Particle i
+// has a collision radius of radius*factors[i], and two particles collide when
+// they are closer than the sum of their radii.
+//
+// The policy changes scratch states, and sets Scratch().Bounced on every
+// particle that it pushes away from another. That counts as a bounce for the
+// particle, which shrinks its radius by Conf.DecayRadius from then on.
+type CollisionPolicy interface {
+	Collide(u *StandardUpdater, radius float64, factors []float64)
+}
+
+// nearby finds, for each particle, the others whose scratch positions are
+// within width of its own and for which keep returns true. If laterOnly is
+// set, only particles after it in the swarm are considered. The comparisons
+// are done concurrently, so keep must not change anything. Returns the
+// starting positions, a grid of them with cells of the given width, and the
+// particles found, in order, for each one.
+func (u *StandardUpdater) nearby(width float64, laterOnly bool, keep func(i, n int) bool) ([]vec.Vec, *grid.Grid, [][]int) {
+	start := make([]vec.Vec, len(u.swarm))
+	for i, p := range u.swarm {
+		start[i] = p.Scratch().Pos.Copy()
+	}
+	found := make([][]int, len(u.swarm))
+	if !(width > 0) {
+		return start, nil, found
+	}
+	g := grid.New(start, width)
+	for i, pos := range start {
+		g.Insert(i, pos)
+	}
+	workers := runtime.GOMAXPROCS(0)
+	u.pool.run(workers, func(w int) {
+		for i := w; i < len(u.swarm); i += workers {
+			after := -1
+			if laterOnly {
+				after = i
+			}
+			found[i] = g.Near(start[i], after, func(n int) bool {
+				return n != i && keep(i, n)
+			})
+		}
+	})
+	return start, g, found
+}
+
+// collisionWidth returns the largest collision distance between two
+// particles.
+func collisionWidth(radius float64, factors []float64) float64 {
+	maxFactor := 0.0
+	for _, f := range factors {
+		maxFactor = math.Max(maxFactor, f)
+	}
+	return 2 * maxFactor * radius
+}
+
+// ReflectCollisions is the default collision policy. Particles are checked
+// against those after them in index order, using their positions after any
+// bounce so far, and each one bounces at most once. A particle that has
+// already bounced is not checked as it comes up, though later ones can still
+// bounce off of it. A bouncing particle reverses its velocity, and goes back
+// past its last position by its move times Conf.BounceMultiplier divided by
+// its factor, so that the particles that have bounced the most, and have the
+// smallest radii, bounce the furthest.
+//
+// Comparisons between positions from before any bounce are independent, so
+// they are done concurrently, and then the bounces are replayed in order with
+// fresh comparisons for the particles that moved. This gives the same bounces
+// as comparing every pair in turn.
+type ReflectCollisions struct{}
+
+func (ReflectCollisions) Collide(u *StandardUpdater, radius float64, factors []float64) {
+	collides := func(i, n int, pos vec.Vec) bool {
+		test_dist := (factors[i] + factors[n]) * radius
+		return u.swarm[n].Scratch().Pos.Dist(pos) < test_dist
+	}
+	start, unmoved, hits := u.nearby(collisionWidth(radius, factors), true, func(i, n int) bool {
+		return collides(i, n, u.swarm[i].Scratch().Pos)
+	})
+	if unmoved == nil {
+		return
+	}
+
+	// Particles move when they bounce, so comparisons with particles that
+	// have bounced in this call are made against their new positions, which
+	// go into a separate grid.
+	moved := unmoved.Empty()
+	hasMoved := make([]bool, len(u.swarm))
+	bounce := func(n int) {
+		p := u.swarm[n]
+		if p.Scratch().Bounced {
+			return
+		}
+		u.doBounce(p, 1.0/factors[n])
+		hasMoved[n] = true
+		moved.Insert(n, p.Scratch().Pos)
+	}
+	for i, p := range u.swarm {
+		if p.Scratch().Bounced {
+			// TODO: determine whether we want to do it this way.
+			// This approach means that a particle only ever collides with
+			// one other, which means that all overlaps are not detected.
+			// That is probably the best approach, because that means that
+			// some particles can still occupy that space.
+			continue
+		}
+		first := -1
+		for _, n := range hits[i] {
+			if !hasMoved[n] {
+				first = n
+				break
+			}
+		}
+		if near := moved.Near(start[i], i, func(n int) bool { return collides(i, n, start[i]) }); len(near) > 0 {
+			if first < 0 || near[0] < first {
+				first = near[0]
+			}
+		}
+		if first < 0 {
+			continue
+		}
+		bounce(i)
+		bounce(first)
+
+		// This particle has moved, so it needs new comparisons with the rest.
+		pos := p.Scratch().Pos
+		rest := unmoved.Near(pos, first, func(n int) bool {
+			return !hasMoved[n] && collides(i, n, pos)
+		})
+		rest = append(rest, moved.Near(pos, first, func(n int) bool { return collides(i, n, pos) })...)
+		sort.Ints(rest)
+		for _, n := range rest {
+			bounce(n)
+		}
+	}
+}
+
+func (u *StandardUpdater) doBounce(particle *particle.Particle, springiness float64) {
+	bounceBy := springiness * u.Conf.BounceMultiplier
+	particle.Scratch().Vel.Negate()
+	particle.Scratch().Pos.SMulBy(bounceBy).Negate().AddBy(particle.Pos.SMul(1 + bounceBy))
+	particle.Scratch().Bounced = true
+}
+
+// ElasticCollisions treats colliding particles as equal balls: if they are
+// moving toward each other, they exchange the components of their velocities
+// along the line between them, and then move by their new velocities from
+// their last positions. Collisions are found between the positions before any
+// of them, and handled in index order, so a particle can collide with more
+// than one other.
+type ElasticCollisions struct{}
+
+func (ElasticCollisions) Collide(u *StandardUpdater, radius float64, factors []float64) {
+	start, _, hits := u.nearby(collisionWidth(radius, factors), true, func(i, n int) bool {
+		return u.swarm[n].Scratch().Pos.Dist(u.swarm[i].Scratch().Pos) < (factors[i]+factors[n])*radius
+	})
+	for i, p := range u.swarm {
+		for _, n := range hits[i] {
+			normal := start[n].Sub(start[i])
+			if mag := normal.Mag(); mag > 0 {
+				normal.SMulBy(1 / mag)
+			} else {
+				continue
+			}
+			a, b := p.Scratch(), u.swarm[n].Scratch()
+			va, vb := a.Vel.Dot(normal), b.Vel.Dot(normal)
+			if va <= vb {
+				continue // already moving apart
+			}
+			a.Vel.AddBy(normal.SMul(vb - va))
+			b.Vel.AddBy(normal.SMul(va - vb))
+			a.Bounced, b.Bounced = true, true
+		}
+	}
+	for _, p := range u.swarm {
+		if s := p.Scratch(); s.Bounced {
+			u.capVelocity(s.Vel, u.Conf.VelCapMultiplier)
+			s.Pos.Replace(p.Pos).AddBy(s.Vel)
+		}
+	}
+}
+
+// RepulsionCollisions pushes particles apart as if they were equally charged,
+// as in the charged PSO of Blackwell and Bentley ("Dynamic search with charged
+// swarms", 2002). Each particle is accelerated away from every other one that
+// is within Perception times their collision distance. At the collision
+// distance, the acceleration is Strength times that distance, and it falls
+// off with the square of the distance beyond it. Closer in, it stays the
+// same, so that particles on top of each other are not thrown across the
+// domain. Only particles that are closer than their collision distance count
+// as bounced.
+type RepulsionCollisions struct {
+	Strength   float64 // acceleration at the collision distance, as a fraction of it
+	Perception float64 // distance beyond which particles do not interact, in collision distances
+}
+
+func (r RepulsionCollisions) Collide(u *StandardUpdater, radius float64, factors []float64) {
+	perception := math.Max(r.Perception, 1)
+	start, _, near := u.nearby(perception*collisionWidth(radius, factors), false, func(i, n int) bool {
+		return u.swarm[n].Scratch().Pos.Dist(u.swarm[i].Scratch().Pos) < perception*(factors[i]+factors[n])*radius
+	})
+
+	// Every particle's acceleration depends only on the starting positions.
+	acc := make([]vec.Vec, len(u.swarm))
+	touching := make([]bool, len(u.swarm))
+	workers := runtime.GOMAXPROCS(0)
+	u.pool.run(workers, func(w int) {
+		for i := w; i < len(u.swarm); i += workers {
+			for _, n := range near[i] {
+				away := start[i].Sub(start[n])
+				dist := away.Mag()
+				if dist == 0 {
+					continue
+				}
+				contact := (factors[i] + factors[n]) * radius
+				if dist < contact {
+					touching[i] = true
+				}
+				strength := r.Strength * contact * math.Pow(contact/math.Max(dist, contact), 2)
+				if acc[i] == nil {
+					acc[i] = vec.New(len(away))
+				}
+				acc[i].AddBy(away.SMulBy(strength / dist))
+			}
+		}
+	})
+	for i, p := range u.swarm {
+		if acc[i] == nil {
+			continue
+		}
+		s := p.Scratch()
+		s.Vel.AddBy(acc[i])
+		u.capVelocity(s.Vel, u.Conf.VelCapMultiplier)
+		s.Pos.Replace(p.Pos).AddBy(s.Vel)
+		if touching[i] {
+			s.Bounced = true
+		}
+	}
+}
+
+// NoOverlapCollisions keeps colliding particles apart by moving the less fit
+// one of each pair (by personal best) directly away from the other until they
+// just touch, while the fitter one stays where it is. The moved particle's
+// velocity becomes the move that took it there. Collisions are found between
+// the positions before any of them, and handled in index order, skipping
+// pairs that earlier moves have already separated. A moved particle can end
+// up touching one that it was not colliding with.
+type NoOverlapCollisions struct{}
+
+func (NoOverlapCollisions) Collide(u *StandardUpdater, radius float64, factors []float64) {
+	_, _, hits := u.nearby(collisionWidth(radius, factors), true, func(i, n int) bool {
+		return u.swarm[n].Scratch().Pos.Dist(u.swarm[i].Scratch().Pos) < (factors[i]+factors[n])*radius
+	})
+	for i, hits := range hits {
+		for _, n := range hits {
+			fit, unfit := u.swarm[i], u.swarm[n]
+			if u.lessFitBest(fit, unfit) {
+				fit, unfit = unfit, fit
+			}
+			contact := (factors[i] + factors[n]) * radius
+			away := unfit.Scratch().Pos.Sub(fit.Scratch().Pos)
+			dist := away.Mag()
+			if dist >= contact {
+				continue
+			}
+			for dist == 0 {
+				away.FFill(unfit.Rand().NormFloat64)
+				dist = away.Mag()
+			}
+			s := unfit.Scratch()
+			s.Pos.Replace(fit.Scratch().Pos).AddBy(away.SMulBy(contact / dist))
+			s.Vel.Replace(s.Pos).SubBy(unfit.Pos)
+			s.Bounced = true
+		}
+	}
+}
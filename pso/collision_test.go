@@ -0,0 +1,145 @@
+package pso
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// placedSwarm creates an updater with a particle for each of the values,
+// which are all at the origin. Use place to move them.
+func placedSwarm(vals ...float64) *StandardUpdater {
+	conf := NewBasicConfig(rng.Streams(1))
+	conf.VelCapMultiplier = 10
+	u := NewStandardPSO(topology.NewRing(len(vals)), fitness.NewParabola(2, 0), conf)
+	u.Update()
+	for i, p := range u.Swarm() {
+		p.Init(vec.Vec{0, 0}, vec.Vec{0, 0}, vals[i])
+	}
+	return u
+}
+
+// place puts the particle at pos, moving by vel in this batch.
+func place(u *StandardUpdater, i int, pos, vel vec.Vec) {
+	p := u.Swarm()[i]
+	p.Pos.Replace(pos)
+	p.Vel.Replace(vel)
+	p.Scratch().Vel.Replace(vel)
+	p.Scratch().Pos.Replace(pos).AddBy(vel)
+}
+
+func closeTo(a, b vec.Vec) bool {
+	return a.Dist(b) < 1e-12
+}
+
+func TestElasticCollisions(t *testing.T) {
+	u := placedSwarm(0, 0, 0)
+	place(u, 0, vec.Vec{0, 0}, vec.Vec{1, 1})
+	place(u, 1, vec.Vec{2.5, 1}, vec.Vec{-1, 0})
+	place(u, 2, vec.Vec{5, 0}, vec.Vec{0, 1})
+	ElasticCollisions{}.Collide(u, 0.5, []float64{1, 1, 1})
+
+	a, b, c := u.Swarm()[0].Scratch(), u.Swarm()[1].Scratch(), u.Swarm()[2].Scratch()
+	if !a.Bounced || !b.Bounced || c.Bounced {
+		t.Fatalf("expected only the first two particles to collide: %v %v %v", a.Bounced, b.Bounced, c.Bounced)
+	}
+	if !closeTo(a.Vel, vec.Vec{-1, 1}) || !closeTo(b.Vel, vec.Vec{1, 0}) {
+		t.Errorf("expected the x velocities to be exchanged, got %v and %v", a.Vel, b.Vel)
+	}
+	if !closeTo(a.Pos, vec.Vec{-1, 1}) || !closeTo(b.Pos, vec.Vec{3.5, 1}) {
+		t.Errorf("expected particles to move by their new velocities, got %v and %v", a.Pos, b.Pos)
+	}
+
+	// Particles that are already moving apart are left alone.
+	u = placedSwarm(0, 0)
+	place(u, 0, vec.Vec{0, 0}, vec.Vec{0.5, 0})
+	place(u, 1, vec.Vec{0.3, 0}, vec.Vec{1, 0})
+	ElasticCollisions{}.Collide(u, 0.5, []float64{1, 1})
+	if u.Swarm()[0].Scratch().Bounced || u.Swarm()[1].Scratch().Bounced {
+		t.Errorf("expected particles moving apart not to collide")
+	}
+}
+
+func TestRepulsionCollisions(t *testing.T) {
+	u := placedSwarm(0, 0, 0)
+	place(u, 0, vec.Vec{0, 0}, vec.Vec{0, 0})
+	place(u, 1, vec.Vec{0.5, 0}, vec.Vec{0, 0})
+	place(u, 2, vec.Vec{20, 0}, vec.Vec{0, 0})
+	RepulsionCollisions{Strength: 1, Perception: 3}.Collide(u, 0.5, []float64{1, 1, 1})
+
+	a, b, c := u.Swarm()[0].Scratch(), u.Swarm()[1].Scratch(), u.Swarm()[2].Scratch()
+	// Inside the collision distance of 1, the acceleration is 1.
+	if !closeTo(a.Vel, vec.Vec{-1, 0}) || !closeTo(b.Vel, vec.Vec{1, 0}) || !a.Bounced || !b.Bounced {
+		t.Errorf("expected touching particles to be pushed apart, got %v and %v", a, b)
+	}
+	if !closeTo(c.Vel, vec.Vec{0, 0}) || c.Bounced {
+		t.Errorf("expected a distant particle to be left alone, got %v", c)
+	}
+
+	// Between 1 and 3 collision distances, it falls off with the square.
+	u = placedSwarm(0, 0)
+	place(u, 0, vec.Vec{0, 0}, vec.Vec{0, 0})
+	place(u, 1, vec.Vec{0, 2}, vec.Vec{0, 0})
+	RepulsionCollisions{Strength: 1, Perception: 3}.Collide(u, 0.5, []float64{1, 1})
+	a, b = u.Swarm()[0].Scratch(), u.Swarm()[1].Scratch()
+	if !closeTo(a.Vel, vec.Vec{0, -0.25}) || !closeTo(b.Pos, vec.Vec{0, 2.25}) || a.Bounced || b.Bounced {
+		t.Errorf("expected nearby particles to be pushed apart without bouncing, got %v and %v", a, b)
+	}
+}
+
+func TestNoOverlapCollisions(t *testing.T) {
+	u := placedSwarm(5, 1, 9)
+	place(u, 0, vec.Vec{0, 0}, vec.Vec{0, 0})
+	place(u, 1, vec.Vec{0.6, 0}, vec.Vec{0, 0.2})
+	place(u, 2, vec.Vec{0, 7}, vec.Vec{0, 0})
+	NoOverlapCollisions{}.Collide(u, 0.5, []float64{1, 1, 1})
+
+	a, b, c := u.Swarm()[0].Scratch(), u.Swarm()[1].Scratch(), u.Swarm()[2].Scratch()
+	if !a.Bounced || b.Bounced || c.Bounced {
+		t.Fatalf("expected only the less fit particle to be moved: %v %v %v", a.Bounced, b.Bounced, c.Bounced)
+	}
+	if !closeTo(b.Pos, vec.Vec{0.6, 0.2}) {
+		t.Errorf("expected the fitter particle to stay put, got %v", b.Pos)
+	}
+	if d := a.Pos.Dist(b.Pos); math.Abs(d-1) > 1e-12 {
+		t.Errorf("expected the particles to just touch, got distance %v", d)
+	}
+	if !closeTo(a.Vel, a.Pos) {
+		t.Errorf("expected the velocity to be the move, got %v for %v", a.Vel, a.Pos)
+	}
+}
+
+func TestCollisionPolicies(t *testing.T) {
+	policies := map[string]CollisionPolicy{
+		"reflect":   ReflectCollisions{},
+		"elastic":   ElasticCollisions{},
+		"repulsion": RepulsionCollisions{Strength: 0.5, Perception: 3},
+		"nooverlap": NoOverlapCollisions{},
+	}
+	for name, policy := range policies {
+		f := fitness.NewRastrigin(4, 0.25)
+		conf := NewBasicConfig(rng.Streams(6))
+		conf.Collisions = policy
+		conf.RadiusMultiplier = 0.02
+		u := NewStandardPSO(topology.NewRing(30), f, conf)
+		obs := &bounceRecorder{}
+		u.Observe(obs)
+		res := Run(context.Background(), u, MaxEvals(6000))
+
+		bounces := 0
+		for _, p := range u.Swarm() {
+			bounces += int(p.Bounces)
+		}
+		if bounces == 0 || len(obs.ids) != bounces {
+			t.Errorf("%s: expected %d bounce events, got %d", name, bounces, len(obs.ids))
+		}
+		if math.IsNaN(res.BestVal) || res.BestVal > 20 {
+			t.Errorf("%s: expected progress, got best value %v", name, res.BestVal)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package pso
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"sort"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+)
+
+// ConstraintHandler decides how constraint violations take part in fitness
+// comparisons, for functions that implement fitness.ConstrainedFunction.
+// Values and total violations are kept separately on particles, so a handler
+// whose behavior changes over time (e.g., an adaptive penalty) always compares
+// with its current settings.
+type ConstraintHandler interface {
+	// LessFit returns true if value a with total violation aViol is less fit
+	// than value b with total violation bViol.
+	LessFit(f fitness.Function, a, aViol, b, bViol float64) bool
+
+	// Update is called after every batch (including initialization) with the
+	// whole swarm and the total number of evaluations so far.
+	Update(f fitness.Function, swarm []*particle.Particle, evals int)
+}
+
+// FeasibilityRules implements Deb's feasibility rules ("An efficient
+// constraint handling method for genetic algorithms", 2000): a feasible
+// solution beats an infeasible one, two feasible solutions are compared by
+// value, and two infeasible solutions by total violation.
+type FeasibilityRules struct{}
+
+func (FeasibilityRules) LessFit(f fitness.Function, a, aViol, b, bViol float64) bool {
+	if aViol > 0 || bViol > 0 {
+		return bViol < aViol
+	}
+	return f.LessFit(a, b)
+}
+
+func (FeasibilityRules) Update(f fitness.Function, swarm []*particle.Particle, evals int) {}
+
+// AdaptivePenalty compares values that are made worse in proportion to their
+// violation. The coefficient adapts as in Hadj-Alouane and Bean ("A genetic
+// algorithm for the multiple-choice integer program", 1997): when the best
+// personal best has been feasible for Window batches in a row, the penalty is
+// relaxed, and when it has been infeasible for that long, it is tightened.
+type AdaptivePenalty struct {
+	Coeff   float64 // current penalty per unit of violation
+	Window  int     // number of batches that trigger an adjustment
+	Tighten float64 // multiplier applied to Coeff after Window infeasible batches
+	Relax   float64 // divisor applied to Coeff after Window feasible batches
+
+	feasibleRun   int
+	infeasibleRun int
+}
+
+// NewAdaptivePenalty creates an adaptive penalty with a starting coefficient
+// of 1. Relax is larger than Tighten so that the coefficient does not cycle.
+func NewAdaptivePenalty() *AdaptivePenalty {
+	return &AdaptivePenalty{
+		Coeff:   1.0,
+		Window:  5,
+		Tighten: 1.5,
+		Relax:   2.0,
+	}
+}
+
+func (h *AdaptivePenalty) LessFit(f fitness.Function, a, aViol, b, bViol float64) bool {
+	return f.LessFit(penalize(f, a, h.Coeff*aViol), penalize(f, b, h.Coeff*bViol))
+}
+
+func (h *AdaptivePenalty) Update(f fitness.Function, swarm []*particle.Particle, evals int) {
+	best := swarm[0]
+	for _, p := range swarm[1:] {
+		if h.LessFit(f, best.BestVal, best.BestViolation, p.BestVal, p.BestViolation) {
+			best = p
+		}
+	}
+	if best.BestViolation > 0 {
+		h.feasibleRun = 0
+		h.infeasibleRun++
+	} else {
+		h.infeasibleRun = 0
+		h.feasibleRun++
+	}
+	switch {
+	case h.infeasibleRun >= h.Window:
+		h.Coeff *= h.Tighten
+		h.infeasibleRun = 0
+	case h.feasibleRun >= h.Window:
+		h.Coeff /= h.Relax
+		h.feasibleRun = 0
+	}
+}
+
+type adaptivePenaltyState struct {
+	Coeff                      float64
+	FeasibleRun, InfeasibleRun int
+}
+
+// MarshalBinary encodes the current coefficient and feasibility history, so
+// that a checkpointed run resumes with the same penalty.
+func (h *AdaptivePenalty) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(adaptivePenaltyState{h.Coeff, h.feasibleRun, h.infeasibleRun})
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary restores a state produced by MarshalBinary.
+func (h *AdaptivePenalty) UnmarshalBinary(b []byte) error {
+	var state adaptivePenaltyState
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&state); err != nil {
+		return err
+	}
+	h.Coeff, h.feasibleRun, h.infeasibleRun = state.Coeff, state.FeasibleRun, state.InfeasibleRun
+	return nil
+}
+
+// penalize moves val in the less fit direction by amount.
+func penalize(f fitness.Function, val, amount float64) float64 {
+	if worse := val + amount; f.LessFit(worse, val) {
+		return worse
+	}
+	return val - amount
+}
+
+// EpsilonConstraint implements the epsilon constrained method of Takahama and
+// Sakai ("Constrained optimization by the epsilon constrained differential
+// evolution with gradient-based mutation and feasible elites", 2006).
+// Violations up to Epsilon count as feasible, and otherwise comparisons follow
+// the feasibility rules. Epsilon starts at the violation of the Theta quantile
+// of the initial swarm, and shrinks to zero after Evals evaluations, so early
+// on the swarm can cross infeasible regions on its way to better values.
+type EpsilonConstraint struct {
+	Theta   float64 // quantile of initial violations used as the starting level
+	CP      float64 // exponent controlling how quickly the level shrinks
+	Evals   int     // evaluations after which only truly feasible solutions count
+	Epsilon float64 // current level
+
+	eps0    float64
+	started bool
+}
+
+// NewEpsilonConstraint creates an epsilon constraint handler whose level
+// reaches zero after the given number of evaluations.
+func NewEpsilonConstraint(evals int) *EpsilonConstraint {
+	return &EpsilonConstraint{
+		Theta: 0.2,
+		CP:    5,
+		Evals: evals,
+	}
+}
+
+func (h *EpsilonConstraint) LessFit(f fitness.Function, a, aViol, b, bViol float64) bool {
+	if (aViol <= h.Epsilon && bViol <= h.Epsilon) || aViol == bViol {
+		return f.LessFit(a, b)
+	}
+	return bViol < aViol
+}
+
+func (h *EpsilonConstraint) Update(f fitness.Function, swarm []*particle.Particle, evals int) {
+	if !h.started {
+		viols := make([]float64, len(swarm))
+		for i, p := range swarm {
+			viols[i] = p.Violation
+		}
+		sort.Float64s(viols)
+		h.eps0 = viols[int(h.Theta*float64(len(viols)-1))]
+		h.started = true
+	}
+	if evals >= h.Evals {
+		h.Epsilon = 0
+		return
+	}
+	h.Epsilon = h.eps0 * math.Pow(1-float64(evals)/float64(h.Evals), h.CP)
+}
+
+type epsilonState struct {
+	Eps0, Epsilon float64
+	Started       bool
+}
+
+// MarshalBinary encodes the starting and current levels.
+func (h *EpsilonConstraint) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(epsilonState{h.eps0, h.Epsilon, h.started})
+	return buf.Bytes(), err
+}
+
+// UnmarshalBinary restores a state produced by MarshalBinary.
+func (h *EpsilonConstraint) UnmarshalBinary(b []byte) error {
+	var state epsilonState
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&state); err != nil {
+		return err
+	}
+	h.eps0, h.Epsilon, h.started = state.Eps0, state.Epsilon, state.Started
+	return nil
+}
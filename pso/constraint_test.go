@@ -0,0 +1,115 @@
+package pso
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+)
+
+func TestFeasibilityRules(t *testing.T) {
+	f := fitness.NewParabola(1, 0)
+	tests := []struct {
+		a, aViol, b, bViol float64
+		want               bool
+	}{
+		{2, 0, 1, 0, true},  // both feasible: by value
+		{1, 0, 2, 0, false}, // both feasible: by value
+		{1, 1, 5, 0, true},  // feasible beats infeasible
+		{5, 0, 1, 1, false}, // feasible beats infeasible
+		{1, 2, 5, 1, true},  // both infeasible: by violation
+		{5, 1, 1, 2, false}, // both infeasible: by violation
+	}
+	for _, test := range tests {
+		if got := (FeasibilityRules{}).LessFit(f, test.a, test.aViol, test.b, test.bViol); got != test.want {
+			t.Errorf("LessFit(%v, %v, %v, %v): expected %v, got %v", test.a, test.aViol, test.b, test.bViol, test.want, got)
+		}
+	}
+}
+
+func TestConstraintHandlers(t *testing.T) {
+	const evals = 40000
+	problems := []struct {
+		name string
+		f    *fitness.ConstrainedFitness
+		opt  float64
+		tol  float64
+	}{
+		{"g06", fitness.NewG06(), -6961.81387558, 1},
+		{"g08", fitness.NewG08(), -0.0958250415, 1e-4},
+		{"g11", fitness.NewG11(), 0.7499, 5e-3},
+	}
+	// Penalties trade a little violation for value, so they are allowed to end
+	// up slightly infeasible.
+	handlers := []struct {
+		name    string
+		new     func() ConstraintHandler
+		maxViol float64
+	}{
+		{"feasibility", func() ConstraintHandler { return FeasibilityRules{} }, 0},
+		{"penalty", func() ConstraintHandler { return NewAdaptivePenalty() }, 1e-3},
+		{"epsilon", func() ConstraintHandler { return NewEpsilonConstraint(evals / 2) }, 0},
+	}
+	for _, prob := range problems {
+		for _, h := range handlers {
+			conf := NewBasicConfig(rng.Streams(13))
+			// Clamping or absorbing tends to pin the swarm in the corner where
+			// the unconstrained g06 objective is smallest.
+			conf.Boundary = ReflectBoundary
+			conf.RadiusMultiplier = 0
+			conf.Momentum0 = 0.7298
+			conf.SocConst, conf.CogConst = 1.49618, 1.49618
+			conf.Constraints = h.new()
+			u := NewStandardPSO(topology.NewRing(20), prob.f, conf)
+			Run(context.Background(), u, MaxEvals(evals))
+
+			best := u.BestParticle()
+			if best.BestViolation > h.maxViol {
+				t.Errorf("%s with %s: best is infeasible, violation %v", prob.name, h.name, best.BestViolation)
+				continue
+			}
+			if viol := fitness.TotalViolation(prob.f.Violations(best.BestPos)); viol != best.BestViolation {
+				t.Errorf("%s with %s: best position %v has violation %v, particle says %v", prob.name, h.name, best.BestPos, viol, best.BestViolation)
+			}
+			if math.Abs(best.BestVal-prob.opt) > prob.tol {
+				t.Errorf("%s with %s: expected best near %v, got %v at %v", prob.name, h.name, prob.opt, best.BestVal, best.BestPos)
+			}
+		}
+	}
+}
+
+func TestAllUpdatersHandleConstraints(t *testing.T) {
+	f := fitness.NewG06()
+	newConf := func() *Config {
+		conf := NewBasicConfig(rng.Streams(13))
+		conf.Boundary = ReflectBoundary
+		return conf
+	}
+	spso, err := NewSPSO2011(f, 20, rng.Streams(13))
+	if err != nil {
+		t.Fatalf("NewSPSO2011: %v", err)
+	}
+	updaters := []struct {
+		name string
+		u    Updater
+	}{
+		{"spso2011", spso},
+		{"fips", NewFIPS(topology.NewRing(20), f, newConf(), FIPSUniform)},
+		{"wfips", NewFIPS(topology.NewRing(20), f, newConf(), FIPSFitness)},
+		{"barebones", NewBareBones(topology.NewRing(20), f, newConf(), BareBonesGaussian)},
+		{"niching", NewNiching(f, 20, 10, newConf())},
+	}
+	for _, test := range updaters {
+		Run(context.Background(), test.u, MaxEvals(20000))
+		best := test.u.BestParticle()
+		if best.BestViolation > 0 {
+			t.Errorf("%s: best is infeasible, f=%v with violation %v", test.name, best.BestVal, best.BestViolation)
+		}
+		if viol := fitness.TotalViolation(f.Violations(best.BestPos)); viol != best.BestViolation {
+			t.Errorf("%s: best position %v has violation %v, particle says %v", test.name, best.BestPos, viol, best.BestViolation)
+		}
+	}
+}
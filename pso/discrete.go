@@ -0,0 +1,61 @@
+package pso
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// Discretization says how a continuous coordinate is turned into a discrete
+// one before it is evaluated.
+type Discretization int
+
+const (
+	DiscreteNone      Discretization = iota // leave the coordinate continuous
+	DiscreteRound                           // round to the nearest integer
+	DiscreteProbRound                       // round up with probability equal to the fractional part
+)
+
+// AllDiscrete returns a per-dimension discretization that treats every one of
+// dims dimensions the same way, for use in Config.Discrete.
+func AllDiscrete(dims int, d Discretization) []Discretization {
+	modes := make([]Discretization, dims)
+	for i := range modes {
+		modes[i] = d
+	}
+	return modes
+}
+
+// discretize applies the per-dimension discretization to pos in place.
+// Dimensions beyond the end of modes are left alone.
+func discretize(pos vec.Vec, modes []Discretization, rgen *rand.Rand) {
+	for d, mode := range modes {
+		if d >= len(pos) {
+			break
+		}
+		switch mode {
+		case DiscreteRound:
+			pos[d] = math.Floor(pos[d] + 0.5)
+		case DiscreteProbRound:
+			lo := math.Floor(pos[d])
+			if rgen.Float64() < pos[d]-lo {
+				lo++
+			}
+			pos[d] = lo
+		}
+	}
+}
+
+// discretizeParticle discretizes a newly created particle's position, so that
+// the initial evaluation happens on a discrete point too.
+func discretizeParticle(p *particle.Particle, modes []Discretization) *particle.Particle {
+	if len(modes) == 0 {
+		return p
+	}
+	discretize(p.Pos, modes, p.Rand())
+	p.BestPos.Replace(p.Pos)
+	p.Scratch().Pos.Replace(p.Pos)
+	return p
+}
@@ -0,0 +1,145 @@
+package pso
+
+import (
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// ChangeResponse reacts to a detected change in a dynamic fitness function. It
+// is called between batches, and returns the number of function evaluations
+// it used.
+type ChangeResponse func(u *StandardUpdater) int
+
+// ReevaluateBests re-evaluates every personal best at its stored position, so
+// that the swarm forgets values that no longer hold. This is the default
+// response.
+func ReevaluateBests(u *StandardUpdater) int {
+	return u.reevaluate(nil)
+}
+
+// Rerandomize returns a response that moves the given fraction of particles
+// (chosen at random, but at least one, and never the best one) to random
+// positions, and then re-evaluates all personal bests. Re-randomized particles
+// forget their memory entirely.
+func Rerandomize(fraction float64) ChangeResponse {
+	return func(u *StandardUpdater) int {
+		best := u.BestParticle()
+		n := int(fraction * float64(len(u.swarm)))
+		if n < 1 {
+			n = 1
+		}
+		// Use the best particle's random source for the selection, so the
+		// outcome doesn't depend on goroutine scheduling.
+		chosen := make([]bool, len(u.swarm))
+		for _, i := range best.Rand().Perm(len(u.swarm)) {
+			if n == 0 {
+				break
+			}
+			if u.swarm[i] != best {
+				chosen[i] = true
+				n--
+			}
+		}
+		return u.reevaluate(chosen)
+	}
+}
+
+// reevaluate moves the chosen particles, if any, to random positions where
+// they start over, and re-evaluates the personal bests of the rest. All of
+// the evaluations go to the fitness function together. Returns the number of
+// evaluations.
+func (u *StandardUpdater) reevaluate(chosen []bool) int {
+	pos := make([]vec.Vec, len(u.swarm))
+	vel := make([]vec.Vec, len(u.swarm))
+	for i, p := range u.swarm {
+		if chosen == nil || !chosen[i] {
+			pos[i] = p.BestPos
+			continue
+		}
+		pos[i], vel[i] = u.Fitness.RandomPos(p.Rand()), u.Fitness.RandomVel(p.Rand())
+		discretize(pos[i], u.Conf.Discrete, p.Rand())
+	}
+	vals := u.queryAll(pos)
+	u.pool.run(len(u.swarm), func(i int) {
+		p := u.swarm[i]
+		if vel[i] == nil {
+			p.BestVal = vals[i]
+			p.BestViolation = u.violation(p.BestPos)
+			return
+		}
+		p.Init(pos[i], vel[i], vals[i])
+		p.ResetViolation(u.violation(pos[i]))
+	})
+	u.totalEvals += len(u.swarm)
+	return len(u.swarm)
+}
+
+// Changes returns the number of landscape changes that have been detected.
+func (u *StandardUpdater) Changes() int {
+	return u.changes
+}
+
+// detectChange re-evaluates the personal bests of Conf.Sentinels particles,
+// spread evenly through the swarm, and applies Conf.ChangeResponse if any of
+// them has a different value than before. Returns the number of evaluations.
+func (u *StandardUpdater) detectChange() int {
+	num := u.Conf.Sentinels
+	if num <= 0 {
+		return 0
+	}
+	if num > len(u.swarm) {
+		num = len(u.swarm)
+	}
+	sentinels := make([]*particle.Particle, num)
+	pos := make([]vec.Vec, num)
+	for i := range sentinels {
+		sentinels[i] = u.swarm[i*len(u.swarm)/num]
+		pos[i] = sentinels[i].BestPos
+	}
+	changed := false
+	for i, val := range u.queryAll(pos) {
+		if val != sentinels[i].BestVal {
+			changed = true
+		}
+	}
+	u.totalEvals += num
+	if !changed {
+		return num
+	}
+
+	u.changes++
+	u.forgetBest()
+	respond := u.Conf.ChangeResponse
+	if respond == nil {
+		respond = ReevaluateBests
+	}
+	return num + respond(u)
+}
+
+// advanceFitness lets a dynamic fitness function change between batches.
+func (u *StandardUpdater) advanceFitness() {
+	if df, ok := u.Fitness.(fitness.DynamicFunction); ok {
+		df.Advance(u.totalEvals)
+	}
+}
+
+// isQuantum returns true if the particle at the given index is one of the
+// Conf.QuantumFraction quantum particles at the end of the swarm.
+func (u *StandardUpdater) isQuantum(pidx int) bool {
+	return pidx >= len(u.swarm)-int(u.Conf.QuantumFraction*float64(len(u.swarm)))
+}
+
+// moveQuantumParticle places the particle uniformly at random in a ball around
+// its informer's best position, as in the quantum swarms of Blackwell and
+// Branke ("Multiswarms, exclusion, and anti-convergence in dynamic
+// environments", 2006). Quantum particles keep the swarm spread out enough to
+// notice nearby changes.
+func (u *StandardUpdater) moveQuantumParticle(p, informer *particle.Particle) {
+	target := sampleSphere(informer.BestPos, u.Conf.QuantumRadius*u.domainDiameter, p.Rand())
+	scratch := p.Scratch()
+	scratch.Vel.Replace(target).SubBy(p.Pos)
+	scratch.Pos.Replace(target)
+	scratch.Bounced = false
+	scratch.WallHit = false
+}
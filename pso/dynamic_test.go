@@ -0,0 +1,191 @@
+package pso
+
+import (
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// runMovingPeaks optimizes a moving peaks landscape, and returns the average
+// error of the best particle's (stated) value just before each change, along
+// with the updater.
+func runMovingPeaks(configure func(c *Config)) (float64, *StandardUpdater, *fitness.MovingPeaks) {
+	const freq = 2500
+	f := fitness.NewMovingPeaks(5, 10, freq, rng.New(3))
+	conf := NewBasicConfig(rng.Streams(11))
+	conf.RadiusMultiplier = 0
+	conf.Momentum0 = 0.7298
+	conf.SocConst, conf.CogConst = 1.49618, 1.49618
+	configure(conf)
+	u := NewStandardPSO(topology.NewStar(25), f, conf)
+
+	totalErr, periods := 0.0, 0
+	for f.Changes() < 20 {
+		before := f.Changes()
+		_, opt := f.Optimum()
+		best := u.BestParticle
+		u.Update()
+		if f.Changes() != before {
+			// The value that counts is the true value at the best position.
+			totalErr += f.Query(best().BestPos) - opt
+			periods++
+		}
+	}
+	return totalErr / float64(periods), u, f
+}
+
+func TestChangeDetection(t *testing.T) {
+	staticErr, _, _ := runMovingPeaks(func(c *Config) {})
+	detectErr, u, f := runMovingPeaks(func(c *Config) { c.Sentinels = 2 })
+	randErr, _, _ := runMovingPeaks(func(c *Config) {
+		c.Sentinels = 2
+		c.ChangeResponse = Rerandomize(0.5)
+	})
+	quantumErr, _, _ := runMovingPeaks(func(c *Config) {
+		c.Sentinels = 2
+		c.QuantumFraction = 0.2
+	})
+
+	// The last change happened after the last batch, so it is noticed in the
+	// next one.
+	u.Update()
+	if u.Changes() != f.Changes() {
+		t.Errorf("expected to detect %d changes, detected %d", f.Changes(), u.Changes())
+	}
+	for _, p := range u.Swarm() {
+		if got := f.Query(p.BestPos); got != p.BestVal {
+			t.Errorf("particle %d has stale best value %v, should be %v", p.Id, p.BestVal, got)
+		}
+	}
+	for name, err := range map[string]float64{"detect": detectErr, "rerandomize": randErr, "quantum": quantumErr} {
+		if err >= staticErr {
+			t.Errorf("expected %s error %v to be less than static error %v", name, err, staticErr)
+		}
+	}
+}
+
+func TestDynamicCheckpoint(t *testing.T) {
+	newUpdater := func() (*StandardUpdater, *fitness.MovingPeaks) {
+		f := fitness.NewMovingPeaks(3, 5, 300, rng.New(8))
+		conf := NewBasicConfig(rng.Streams(8))
+		conf.Sentinels = 2
+		return NewStandardPSO(topology.NewRing(10), f, conf), f
+	}
+
+	straight, _ := newUpdater()
+	for i := 0; i < 80; i++ {
+		straight.Update()
+	}
+
+	first, _ := newUpdater()
+	for i := 0; i < 40; i++ {
+		first.Update()
+	}
+	var buf bytes.Buffer
+	if err := first.WriteCheckpoint(&buf); err != nil {
+		t.Fatalf("Failed to write checkpoint: %v", err)
+	}
+	resumed, f := newUpdater()
+	if err := resumed.ReadCheckpoint(&buf); err != nil {
+		t.Fatalf("Failed to read checkpoint: %v", err)
+	}
+	if resumed.Changes() != first.Changes() || f.Changes() == 0 {
+		t.Errorf("expected %d detected changes after resume, got %d (landscape changed %d times)", first.Changes(), resumed.Changes(), f.Changes())
+	}
+	for i := 0; i < 40; i++ {
+		resumed.Update()
+	}
+
+	if straight.Changes() != resumed.Changes() {
+		t.Errorf("expected %d detected changes, got %d", straight.Changes(), resumed.Changes())
+	}
+	for i, p := range straight.Swarm() {
+		q := resumed.Swarm()[i]
+		if p.String() != q.String() || p.Pos.Sub(q.Pos).Mag() != 0 {
+			t.Fatalf("particle %d differs after resume:\n%v\n%v", i, p, q)
+		}
+	}
+}
+
+// brokenTopology is a topology whose saved state can never be restored.
+type brokenTopology struct {
+	topology.Topology
+}
+
+func (brokenTopology) MarshalBinary() ([]byte, error) { return []byte{1}, nil }
+func (brokenTopology) UnmarshalBinary([]byte) error   { return errors.New("broken") }
+
+func TestFailedCheckpointChangesNothing(t *testing.T) {
+	newUpdater := func() (*StandardUpdater, *fitness.MovingPeaks) {
+		f := fitness.NewMovingPeaks(3, 5, 300, rng.New(8))
+		return NewStandardPSO(brokenTopology{topology.NewRing(10)}, f, NewBasicConfig(rng.Streams(8))), f
+	}
+	first, _ := newUpdater()
+	for i := 0; i < 40; i++ {
+		first.Update()
+	}
+	var buf bytes.Buffer
+	if err := first.WriteCheckpoint(&buf); err != nil {
+		t.Fatalf("Failed to write checkpoint: %v", err)
+	}
+
+	// The fitness state is restored before the topology fails, and must be
+	// put back.
+	u, f := newUpdater()
+	for i := 0; i < 5; i++ {
+		u.Update()
+	}
+	before, _ := f.MarshalBinary()
+	evals, swarm := u.Evals(), u.Swarm()
+	if err := u.ReadCheckpoint(&buf); err == nil {
+		t.Fatalf("expected the topology to fail")
+	}
+	if after, _ := f.MarshalBinary(); !bytes.Equal(before, after) {
+		t.Errorf("fitness state changed by a failed checkpoint")
+	}
+	if u.Evals() != evals || &u.Swarm()[0] != &swarm[0] {
+		t.Errorf("updater changed by a failed checkpoint")
+	}
+}
+
+// batchOnly is a dynamic batch function that records single queries, which
+// should never happen when every position can go through QueryBatch.
+type batchOnly struct {
+	*fitness.MovingPeaks
+	singles int64
+}
+
+func (f *batchOnly) Query(pos vec.Vec) float64 {
+	atomic.AddInt64(&f.singles, 1)
+	return f.MovingPeaks.Query(pos)
+}
+
+func TestDynamicQueriesAreBatched(t *testing.T) {
+	for name, configure := range map[string]func(c *Config){
+		"reevaluate":  func(c *Config) {},
+		"rerandomize": func(c *Config) { c.ChangeResponse = Rerandomize(0.5) },
+		"apso":        func(c *Config) { c.Strategy = NewAPSO(rng.New(2), 0) },
+	} {
+		f := &batchOnly{MovingPeaks: fitness.NewMovingPeaks(3, 5, 200, rng.New(4))}
+		conf := NewBasicConfig(rng.Streams(4))
+		conf.Sentinels = 3
+		configure(conf)
+		u := NewStandardPSO(topology.NewRing(10), f, conf)
+		for i := 0; i < 100; i++ {
+			u.Update()
+		}
+		u.Reinitialize()
+		if u.Changes() == 0 {
+			t.Errorf("%s: expected some changes to be detected", name)
+		}
+		if f.singles != 0 {
+			t.Errorf("%s: expected every evaluation in a batch, got %d single queries", name, f.singles)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package pso
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EvalStats summarizes how long fitness evaluations have taken.
+type EvalStats struct {
+	Count int
+	Total time.Duration
+	Min   time.Duration
+	Max   time.Duration
+}
+
+// Mean returns the average evaluation time.
+func (s EvalStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+func (s EvalStats) String() string {
+	return fmt.Sprintf("%d evals, mean %v, min %v, max %v, total %v", s.Count, s.Mean(), s.Min, s.Max, s.Total)
+}
+
+// evalPool runs fitness evaluations on a reusable set of worker goroutines,
+// with a bounded number at a time, and times them. Workers are started as
+// they are first needed, up to the largest number that a call to run has
+// used, and are kept until close.
+type evalPool struct {
+	limit int // maximum concurrent evaluations, or 0 for no limit
+
+	tasks   chan func()
+	workers sync.WaitGroup
+	size    int // number of workers started
+
+	mu    sync.Mutex
+	stats EvalStats
+}
+
+// run calls eval(i) for every i in [0, n), with at most limit calls running
+// at once, and waits for all of them. With a limit of 1, the calls happen in
+// order on the calling goroutine. Calls to run must not overlap.
+func (ep *evalPool) run(n int, eval func(i int)) {
+	workers := ep.limit
+	if workers <= 0 || workers > n {
+		workers = n
+	}
+	if workers == 1 {
+		for i := 0; i < n; i++ {
+			eval(i)
+		}
+		return
+	}
+	ep.grow(workers)
+
+	// Each task takes indices until there are none left, so the calls are
+	// shared out however quickly the workers pick the tasks up.
+	next := int64(-1)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		ep.tasks <- func() {
+			defer wg.Done()
+			for i := int(atomic.AddInt64(&next, 1)); i < n; i = int(atomic.AddInt64(&next, 1)) {
+				eval(i)
+			}
+		}
+	}
+	wg.Wait()
+}
+
+// grow starts workers until there are at least n.
+func (ep *evalPool) grow(n int) {
+	if ep.tasks == nil {
+		ep.tasks = make(chan func())
+	}
+	for ; ep.size < n; ep.size++ {
+		ep.workers.Add(1)
+		go func() {
+			defer ep.workers.Done()
+			for task := range ep.tasks {
+				task()
+			}
+		}()
+	}
+}
+
+// close stops the workers. A later call to run starts them again.
+func (ep *evalPool) close() {
+	if ep.tasks == nil {
+		return
+	}
+	close(ep.tasks)
+	ep.workers.Wait()
+	ep.tasks, ep.size = nil, 0
+}
+
+// timed calls f and records how long it took as n evaluations of equal length.
+func (ep *evalPool) timed(n int, f func()) {
+	start := time.Now()
+	f()
+	if n <= 0 {
+		return
+	}
+	total := time.Since(start)
+	d := total / time.Duration(n)
+
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	if ep.stats.Count == 0 || d < ep.stats.Min {
+		ep.stats.Min = d
+	}
+	if d > ep.stats.Max {
+		ep.stats.Max = d
+	}
+	ep.stats.Count += n
+	ep.stats.Total += total
+}
+
+// Stats returns the timing of all evaluations so far.
+func (ep *evalPool) Stats() EvalStats {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	return ep.stats
+}
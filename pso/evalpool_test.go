@@ -0,0 +1,117 @@
+package pso
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// concurrencyProbe wraps a parabola, recording the most evaluations that were
+// ever in flight at once.
+type concurrencyProbe struct {
+	mu       sync.Mutex
+	inFlight int
+	maxSeen  int
+}
+
+func (c *concurrencyProbe) fitness(dims int) *fitness.Fitness {
+	parabola := fitness.NewParabola(dims, 0)
+	return fitness.NewFitnessSquareDomain(dims, -50, 50, 0, func(f *fitness.Fitness, pos vec.Vec) float64 {
+		c.mu.Lock()
+		c.inFlight++
+		if c.inFlight > c.maxSeen {
+			c.maxSeen = c.inFlight
+		}
+		c.mu.Unlock()
+
+		time.Sleep(100 * time.Microsecond)
+
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+		return parabola.Query(pos)
+	})
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	for _, limit := range []int{1, 3} {
+		probe := &concurrencyProbe{}
+		conf := NewBasicConfig(rng.Streams(1))
+		conf.Concurrency = limit
+		u := NewStandardPSO(topology.NewRing(20), probe.fitness(3), conf)
+		evals := 0
+		for i := 0; i < 10; i++ {
+			evals += u.Update()
+		}
+
+		if probe.maxSeen > limit {
+			t.Errorf("limit %d: saw %d concurrent evaluations", limit, probe.maxSeen)
+		}
+		stats := u.EvalStats()
+		if stats.Count != evals {
+			t.Errorf("limit %d: expected %d timed evaluations, got %d", limit, evals, stats.Count)
+		}
+		if stats.Min <= 0 || stats.Min > stats.Mean() || stats.Mean() > stats.Max {
+			t.Errorf("limit %d: inconsistent timing stats %v", limit, stats)
+		}
+	}
+}
+
+func TestConcurrencyLimitIsReproducible(t *testing.T) {
+	run := func(limit int) float64 {
+		conf := NewBasicConfig(rng.Streams(7))
+		conf.Concurrency = limit
+		u := NewStandardPSO(topology.NewRing(10), fitness.NewParabola(4, 0.25), conf)
+		for i := 0; i < 50; i++ {
+			u.Update()
+		}
+		return u.BestParticle().BestVal
+	}
+
+	if seq, par := run(1), run(0); seq != par {
+		t.Errorf("expected the same best value with and without a limit, got %v and %v", seq, par)
+	}
+}
+
+func TestEvalPoolReusesWorkers(t *testing.T) {
+	ep := &evalPool{limit: 4}
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		var calls int64
+		ep.run(10, func(i int) { atomic.AddInt64(&calls, 1) })
+		if calls != 10 {
+			t.Fatalf("expected 10 calls, got %d", calls)
+		}
+	}
+	if ep.size != 4 {
+		t.Errorf("expected 4 workers, started %d", ep.size)
+	}
+	if n := runtime.NumGoroutine(); n > before+4 {
+		t.Errorf("expected at most %d goroutines after reusing the pool, got %d", before+4, n)
+	}
+
+	// Without a limit, the pool grows to the largest call.
+	ep.limit = 0
+	ep.run(6, func(i int) {})
+	if ep.size != 6 {
+		t.Errorf("expected 6 workers, started %d", ep.size)
+	}
+
+	ep.close()
+	if ep.size != 0 {
+		t.Errorf("expected no workers after close, have %d", ep.size)
+	}
+	var calls int64
+	ep.run(3, func(i int) { atomic.AddInt64(&calls, 1) })
+	if calls != 3 || ep.size != 3 {
+		t.Errorf("expected 3 calls on 3 new workers after close, got %d calls on %d", calls, ep.size)
+	}
+	ep.close()
+}
@@ -0,0 +1,147 @@
+package pso
+
+import (
+	"sort"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// FIPSWeighting determines how much each neighbor contributes to a particle's
+// acceleration in the fully informed update.
+type FIPSWeighting int
+
+const (
+	// FIPSUniform weighs all neighbors equally.
+	FIPSUniform FIPSWeighting = iota
+
+	// FIPSFitness weighs neighbors by the rank of their personal best within
+	// the neighborhood: the best of k neighbors gets weight k, the worst gets
+	// weight 1. Ranks are used instead of raw values so that the weighting
+	// does not depend on the scale of the fitness function.
+	FIPSFitness
+)
+
+// Constriction parameters for FIPS (Mendes, Kennedy and Neves, "The Fully
+// Informed Particle Swarm: Simpler, Maybe Better", 2004).
+const (
+	FIPSChi = 0.7298
+	FIPSPhi = 4.1
+)
+
+// FIPSUpdater implements the Fully Informed Particle Swarm. Instead of being
+// pulled toward its own best and a single best neighbor, every particle is
+// pulled toward the personal bests of all of its neighbors, so the topology
+// must be able to enumerate them.
+//
+// From Conf, it uses NewRNG, VelCapMultiplier, Boundary, Discrete,
+// Constraints and Concurrency.
+type FIPSUpdater struct {
+	swarmBase
+
+	Topology    topology.Topology
+	Conf        *Config
+	Chi         float64       // constriction coefficient
+	Phi         float64       // total acceleration, shared among neighbors
+	Weighting   FIPSWeighting // how to divide the acceleration among neighbors
+	IncludeSelf bool          // whether the particle's own best counts as a neighbor
+}
+
+// NewFIPS creates a fully informed updater with constriction parameters
+// from the literature, where the particle itself is not counted as a neighbor.
+func NewFIPS(t topology.Topology, f fitness.Function, c *Config, w FIPSWeighting) *FIPSUpdater {
+	return &FIPSUpdater{
+		swarmBase: newSwarmBase(f),
+		Topology:  t,
+		Conf:      c,
+		Chi:       FIPSChi,
+		Phi:       FIPSPhi,
+		Weighting: w,
+	}
+}
+
+func (u *FIPSUpdater) init() int {
+	u.useConstraints(u.Conf.Constraints)
+	return u.initSwarm(u.Topology.Size(), func(i int) *particle.Particle {
+		return discretizeParticle(particle.NewRandomParticle(u.Conf.NewRNG(i), i, u.Fitness), u.Conf.Discrete)
+	})
+}
+
+// Update moves the swarm from one time slice to another. The first call moves
+// the swarm to t[0] by initializing it. After that it ticks the clock with each call.
+// Returns the number of function evaluations performed.
+func (u *FIPSUpdater) Update() (evals int) {
+	u.beginBatch()
+	defer func() { u.endBatch(evals) }()
+	improved := false
+	defer func() {
+		u.Topology.Tick()
+		u.updateConstraints()
+		u.countBatch(improved)
+	}()
+	u.limitConcurrency(u.Conf.Concurrency)
+	if !u.Initialized() {
+		improved = true
+		return u.init()
+	}
+
+	u.moveAll(u.moveOneParticle)
+	evals, improved = u.evaluateAll(u.confiner(u.Conf.Boundary, u.Conf.Discrete))
+	return evals
+}
+
+// weights returns normalized weights (summing to 1) for the given neighbors.
+func (u *FIPSUpdater) weights(nbrs []int) []float64 {
+	weights := make([]float64, len(nbrs))
+	if u.Weighting == FIPSUniform {
+		for i := range weights {
+			weights[i] = 1.0 / float64(len(nbrs))
+		}
+		return weights
+	}
+
+	// Rank from least to most fit, so that position k gets weight k+1.
+	order := make([]int, len(nbrs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return u.lessFitBest(u.swarm[nbrs[order[a]]], u.swarm[nbrs[order[b]]])
+	})
+	total := float64(len(nbrs)*(len(nbrs)+1)) / 2
+	for rank, i := range order {
+		weights[i] = float64(rank+1) / total
+	}
+	return weights
+}
+
+func (u *FIPSUpdater) moveOneParticle(pidx int) {
+	p := u.swarm[pidx]
+	dims := len(p.Pos)
+
+	nbrs := u.Topology.Neighbors(pidx)
+	if u.IncludeSelf {
+		self := false
+		for _, n := range nbrs {
+			self = self || n == pidx
+		}
+		if !self {
+			nbrs = append(nbrs, pidx)
+		}
+	}
+
+	acc := vec.New(dims)
+	for i, w := range u.weights(nbrs) {
+		r := vec.NewFFilled(dims, p.Rand().Float64).SMulBy(u.Phi * w)
+		acc.AddBy(u.swarm[nbrs[i]].BestPos.Sub(p.Pos).MulBy(r))
+	}
+
+	scratch := p.Scratch()
+	scratch.Vel.Replace(p.Vel).AddBy(acc).SMulBy(u.Chi)
+	u.capVelocity(scratch.Vel, u.Conf.VelCapMultiplier)
+	scratch.Pos.Replace(p.Pos).AddBy(scratch.Vel)
+	scratch.Bounced = false
+	scratch.WallHit = false
+}
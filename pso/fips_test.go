@@ -0,0 +1,50 @@
+package pso
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+)
+
+func TestFIPSConverges(t *testing.T) {
+	expander, err := topology.NewRandomExpander(rng.Derive(4, -1), 20, 3)
+	if err != nil {
+		t.Fatalf("Failed to create topology: %v", err)
+	}
+	tests := []struct {
+		name      string
+		topo      topology.Topology
+		weighting FIPSWeighting
+	}{
+		{"ring uniform", topology.NewRing(20), FIPSUniform},
+		{"ring fitness", topology.NewRing(20), FIPSFitness},
+		{"expander uniform", expander, FIPSUniform},
+	}
+	for _, test := range tests {
+		f := fitness.NewParabola(10, 0.25)
+		u := NewFIPS(test.topo, f, NewBasicConfig(rng.Streams(4)), test.weighting)
+		res := Run(context.Background(), u, MaxEvals(60000))
+		if res.BestVal > 1e-3 {
+			t.Errorf("%s: expected best <= 1e-3 after %d evals, got %v", test.name, res.Evals, res.BestVal)
+		}
+	}
+}
+
+func TestFIPSWeights(t *testing.T) {
+	f := fitness.NewParabola(2, 0)
+	u := NewFIPS(topology.NewRing(4), f, NewBasicConfig(rng.Streams(1)), FIPSFitness)
+	u.Update()
+	for i, val := range []float64{3, 1, 2, 4} {
+		u.swarm[i].BestVal = val
+	}
+	got := u.weights([]int{0, 1, 2, 3})
+	want := []float64{0.2, 0.4, 0.3, 0.1}
+	for i := range want {
+		if diff := got[i] - want[i]; diff > 1e-12 || diff < -1e-12 {
+			t.Fatalf("expected weights %v, got %v", want, got)
+		}
+	}
+}
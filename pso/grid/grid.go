@@ -0,0 +1,139 @@
+// Package grid finds points that are near each other without comparing every
+// pair. The swarm uses it to find collisions between particles, and the species
+// topology uses it to find the species seeds near each particle.
+package grid
+
+import (
+	"math"
+	"sort"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+// maxDims is the most dimensions a Grid divides into cells. There are up to
+// 3^maxDims neighboring cells to look in, so more dimensions would cost more
+// than they save.
+const maxDims = 3
+
+// cellsPerPoint limits the number of cells in a Grid.
+const cellsPerPoint = 4
+
+// Grid finds the points that are near a position. It divides space
+// into cubic cells along a few dimensions, so that any two points closer
+// together than the cell width are in neighboring cells. Ignoring the other
+// dimensions never hides a close pair, it only lets through more candidates.
+type Grid struct {
+	dims  []int     // dimensions that the cells divide
+	width float64   // cell width
+	lo    []float64 // lowest cell coordinate along each of dims
+	size  []int     // number of cells along each of dims
+	cells [][]int   // points in each cell
+}
+
+// New creates an empty grid with cells of the given width that cover points.
+// It divides the dimensions in which the points span the most cells, since
+// those separate them best, as long as the grid does not get much bigger than
+// the number of points. Positions outside of the points' range belong to the
+// nearest cell, which keeps close pairs in neighboring cells. If width is not
+// positive, there is a single cell, and every point is near every other.
+func New(points []vec.Vec, width float64) *Grid {
+	g := &Grid{width: width, cells: make([][]int, 1)}
+	if len(points) == 0 || !(width > 0) {
+		return g
+	}
+	dims := len(points[0])
+	lo := make([]float64, dims)
+	spans := make([]float64, dims)
+	order := make([]int, dims)
+	for d := range order {
+		order[d] = d
+		lo[d] = math.Inf(1)
+		hi := math.Inf(-1)
+		for _, p := range points {
+			c := math.Floor(p[d] / width)
+			if c < lo[d] {
+				lo[d] = c
+			}
+			if c > hi {
+				hi = c
+			}
+		}
+		if hi >= lo[d] {
+			spans[d] = hi - lo[d] + 1
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return spans[order[a]] > spans[order[b]]
+	})
+
+	maxCells := float64(cellsPerPoint * len(points))
+	total := 1.0
+	for _, d := range order {
+		if len(g.dims) == maxDims || spans[d] <= 1 {
+			break
+		}
+		if total*spans[d] > maxCells {
+			continue
+		}
+		total *= spans[d]
+		g.dims = append(g.dims, d)
+		g.lo = append(g.lo, lo[d])
+		g.size = append(g.size, int(spans[d]))
+	}
+	g.cells = make([][]int, int(total))
+	return g
+}
+
+// Empty creates a grid with the same cells and no points.
+func (g *Grid) Empty() *Grid {
+	return &Grid{dims: g.dims, width: g.width, lo: g.lo, size: g.size, cells: make([][]int, len(g.cells))}
+}
+
+// coord returns the cell coordinate of pos along the ith of the grid's
+// dimensions.
+func (g *Grid) coord(pos vec.Vec, i int) int {
+	c := math.Floor(pos[g.dims[i]]/g.width) - g.lo[i]
+	switch {
+	case !(c >= 0): // also catches NaN
+		return 0
+	case c >= float64(g.size[i]):
+		return g.size[i] - 1
+	}
+	return int(c)
+}
+
+// Insert adds point i at pos.
+func (g *Grid) Insert(i int, pos vec.Vec) {
+	cell := 0
+	for d := range g.dims {
+		cell = cell*g.size[d] + g.coord(pos, d)
+	}
+	g.cells[cell] = append(g.cells[cell], i)
+}
+
+// Near returns, in increasing order, the points after the given index that
+// are in pos's cell or a neighboring one and for which keep returns true. It
+// is safe to call concurrently, as long as nothing is being inserted.
+func (g *Grid) Near(pos vec.Vec, after int, keep func(i int) bool) []int {
+	var found []int
+	var visit func(d, cell int)
+	visit = func(d, cell int) {
+		if d == len(g.dims) {
+			for _, i := range g.cells[cell] {
+				if i > after && keep(i) {
+					found = append(found, i)
+				}
+			}
+			return
+		}
+		c := g.coord(pos, d)
+		for n := c - 1; n <= c+1; n++ {
+			if n >= 0 && n < g.size[d] {
+				visit(d+1, cell*g.size[d]+n)
+			}
+		}
+	}
+	visit(0, 0)
+	sort.Ints(found)
+	return found
+}
@@ -0,0 +1,52 @@
+package grid
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+func TestNearFindsClosePoints(t *testing.T) {
+	rgen := rand.New(rand.NewSource(3))
+	for _, dims := range []int{1, 2, 5} {
+		for _, width := range []float64{0, 0.01, 0.1, 2} {
+			points := make([]vec.Vec, 300)
+			for i := range points {
+				points[i] = vec.NewFFilled(dims, rgen.NormFloat64)
+			}
+			g := New(points, width)
+			for i, p := range points {
+				g.Insert(i, p)
+			}
+			for i, p := range points {
+				close := func(n int) bool { return p.Dist(points[n]) < width }
+				var want []int
+				for n := i + 1; n < len(points); n++ {
+					if close(n) {
+						want = append(want, n)
+					}
+				}
+				if got := g.Near(p, i, close); fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Fatalf("dims %d, width %v: expected %v near point %d, got %v", dims, width, want, i, got)
+				}
+			}
+		}
+	}
+}
+
+func TestSingleCell(t *testing.T) {
+	points := []vec.Vec{{0, 0}, {100, -100}, {-5, 3}}
+	g := New(points, 0)
+	for i, p := range points {
+		g.Insert(i, p)
+	}
+	all := func(n int) bool { return true }
+	if got := g.Near(vec.Vec{1e9, 1e9}, -1, all); fmt.Sprint(got) != "[0 1 2]" {
+		t.Errorf("expected every point in a single cell, got %v", got)
+	}
+	if got := g.Empty().Near(vec.Vec{0, 0}, -1, all); len(got) != 0 {
+		t.Errorf("expected an empty grid to have no points, got %v", got)
+	}
+}
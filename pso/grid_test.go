@@ -0,0 +1,135 @@
+package pso
+
+import (
+	"math"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// pairwiseBounceAll is the straightforward version of bounceAll, which
+// compares every pair of particles in turn.
+func pairwiseBounceAll(u *StandardUpdater) {
+	radius := u.Conf.RadiusMultiplier * u.domainDiameter
+	if radius <= 0.0 {
+		return
+	}
+	factors := vec.New(len(u.swarm)).MapBy(
+		func(i int, _ float64) float64 {
+			return math.Pow(u.Conf.DecayRadius, float64(u.swarm[i].Bounces))
+		})
+	for i, p := range u.swarm {
+		if p.Scratch().Bounced {
+			continue
+		}
+		for n := i + 1; n < len(u.swarm); n++ {
+			other := u.swarm[n]
+			test_dist := (factors[i] + factors[n]) * radius
+			if other.Scratch().Pos.Dist(p.Scratch().Pos) < test_dist {
+				if !p.Scratch().Bounced {
+					u.doBounce(p, 1.0/factors[i])
+				}
+				if !other.Scratch().Bounced {
+					u.doBounce(other, 1.0/factors[n])
+				}
+			}
+		}
+	}
+	for _, p := range u.swarm {
+		if p.Scratch().Bounced {
+			u.observeBounce(p)
+		}
+	}
+}
+
+// bounceRecorder records the order of bounce events.
+type bounceRecorder struct {
+	NopObserver
+	ids []int
+}
+
+func (o *bounceRecorder) Bounced(p *particle.Particle) { o.ids = append(o.ids, p.Id) }
+
+func TestBounceAllMatchesPairwise(t *testing.T) {
+	tests := []struct {
+		name        string
+		dims, size  int
+		radius      float64
+		decay       float64
+		concurrency int
+	}{
+		{"crowded plane", 2, 200, 0.05, 0.9, 0},
+		{"sequential", 2, 200, 0.05, 0.9, 1},
+		{"line", 1, 100, 0.02, 0.5, 0},
+		{"high dimensional", 12, 100, 0.3, 0.9, 4},
+		{"growing radius", 3, 80, 0.05, 1.1, 0},
+	}
+	for _, test := range tests {
+		f := fitness.NewRastrigin(test.dims, 0.25)
+		newUpdater := func() (*StandardUpdater, *bounceRecorder) {
+			conf := NewBasicConfig(rng.Streams(17))
+			conf.RadiusMultiplier = test.radius
+			conf.DecayRadius = test.decay
+			conf.Concurrency = test.concurrency
+			u := NewStandardPSO(topology.NewRing(test.size), f, conf)
+			obs := &bounceRecorder{}
+			u.Observe(obs)
+			u.Update()
+			return u, obs
+		}
+		u, uObs := newUpdater()
+		ref, refObs := newUpdater()
+
+		for step := 0; step < 30; step++ {
+			u.moveAll(u.moveOneParticle)
+			ref.moveAll(ref.moveOneParticle)
+			u.bounceAll()
+			pairwiseBounceAll(ref)
+			for i, p := range u.Swarm() {
+				q := ref.Swarm()[i]
+				if p.Scratch().Bounced != q.Scratch().Bounced || p.Scratch().Pos.Dist(q.Scratch().Pos) != 0 {
+					t.Fatalf("%s: particle %d differs at step %d:\n%v\n%v", test.name, i, step, p.Scratch(), q.Scratch())
+				}
+			}
+			u.evaluateAll(nil)
+			ref.evaluateAll(nil)
+		}
+
+		if len(refObs.ids) == 0 {
+			t.Errorf("%s: expected some bounces", test.name)
+		}
+		if len(uObs.ids) != len(refObs.ids) {
+			t.Fatalf("%s: expected %d bounces, got %d", test.name, len(refObs.ids), len(uObs.ids))
+		}
+		for i := range uObs.ids {
+			if uObs.ids[i] != refObs.ids[i] {
+				t.Fatalf("%s: bounce %d is particle %d, expected %d", test.name, i, uObs.ids[i], refObs.ids[i])
+			}
+		}
+	}
+}
+
+func BenchmarkBounceAll(b *testing.B) {
+	f := fitness.NewRastrigin(10, 0.25)
+	conf := NewBasicConfig(rng.Streams(5))
+	conf.RadiusMultiplier = 0.02
+	u := NewStandardPSO(topology.NewRing(500), f, conf)
+	u.Update()
+	u.moveAll(u.moveOneParticle)
+	saved := make([]vec.Vec, len(u.swarm))
+	for i, p := range u.swarm {
+		saved[i] = p.Scratch().Pos.Copy()
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j, p := range u.swarm {
+			p.Scratch().Pos.Replace(saved[j])
+			p.Scratch().Bounced = false
+		}
+		u.bounceAll()
+	}
+}
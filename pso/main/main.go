@@ -1,55 +1,217 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
-	"math"
-	"math/rand"
+	"os"
+	"os/signal"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/shiblon/entrogo/fitness"
 	"github.com/shiblon/entrogo/pso"
+	"github.com/shiblon/entrogo/pso/metrics"
+	"github.com/shiblon/entrogo/pso/pareto"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/restart"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/spec"
 	"github.com/shiblon/entrogo/pso/topology"
 )
 
 // ./main -fit=rosenbrock:100:0.25 -topo=star:5 -m0=0.75 -m1=0.4 -cdecay=0.999 -mtype=randexplore -n=250000
 
 var (
+	configFlag = flag.String("config", "",
+		"JSON run spec to load, as printed after '# spec:' at the start of every run. "+
+			"Flags for the spec's fields that are given explicitly override its values. "+
+			"Output options (-outputfreq, -progress, -metrics, -front, -cachefile, -checkpoint, -resume) are not part of the spec.")
+
 	fitnessFlag = flag.String("fit", "parabola:100:0.25",
 		"Name of the fitness function. Specify parameters thus: "+
 			"--fit=rastrigin:100:0.25 (for 100 dimensions, "+
-			"and an offset of 1/4 each domain side length).")
+			"and an offset of 1/4 each domain side length). Multi-objective "+
+			"functions (zdt1, zdt2, zdt3) take only dimensions, e.g., --fit=zdt1:30, "+
+			"and require --alg=mopso. Constrained benchmarks (g06, g08, g11) take no parameters. "+
+			"Binary problems are onemax:bits, trap:blocks:bitsPerBlock and knapsack:items:instanceSeed. "+
+			"The dynamic moving peaks benchmark is movingpeaks:dims:peaks:changeFrequency:instanceSeed. "+
+			"Multimodal benchmarks (himmelblau, equalmaxima, sixhumpcamel, shubert) take no parameters.")
+
+	algFlag = flag.String("alg", "standard",
+		"Update algorithm: standard, spso2011, fips, wfips (fitness-weighted FIPS), "+
+			"barebones, bbexp (bare bones keeping half of the personal best), bbcauchy, async (steady state, see --workers), binary, niching (species-based, reports all optima) or mopso (multi-objective). "+
+			"The SPSO 2011 reference uses its own topology, but takes its swarm size from --topo (its reference "+
+			"size is 40), as do niching and mopso.")
+
+	workersFlag = flag.Int("workers", runtime.NumCPU(), "Number of concurrent evaluations for --alg=async.")
+
+	batchFlag        = flag.Bool("batch", false, "Evaluate each batch in one call on a single goroutine. Only for the plain benchmarks, from parabola to dejongf4.")
+	cacheFlag        = flag.Int("cache", 0, "Remember up to this many evaluations, if positive. Not for constrained or dynamic functions.")
+	cacheQuantumFlag = flag.Float64("cachequantum", 0, "Grid spacing for cache keys, so nearby positions share a value. Exact positions if 0.")
+	cacheFileFlag    = flag.String("cachefile", "", "File to load the cache from (if it exists) and save it to at the end.")
+
+	concurrencyFlag = flag.Int("concurrency", 0, "Maximum concurrent evaluations per batch (0 for no limit, 1 if the function is not thread safe).")
+
+	speciesRadiusFlag = flag.Float64("species", 0.1, "Species radius for --alg=niching, as a fraction of the domain diameter.")
+
+	archiveFlag = flag.Int("archive", 100, "Maximum Pareto archive size for --alg=mopso.")
+	frontFlag   = flag.String("front", "", "File to write the final Pareto front to as CSV, for --alg=mopso. Printed if empty.")
 
 	topoFlag = flag.String("topo", "star:5",
 		"Name of the topology. Specify parameters thus: --topo=ring:3 or --topo=expander:6:2")
 
 	iterFlag = flag.Int("n", 250000, "Number of evaluations.")
 
+	maxTimeFlag    = flag.Duration("maxtime", 0, "Maximum wall-clock running time, if positive.")
+	stagnationFlag = flag.Int("stagnation", 0, "Stop after this many batches without improvement, if positive.")
+
+	restartFlag = flag.String("restart", "none",
+		"Restart policy for --alg=standard when the swarm stagnates: none, reinit (all but the best particle), "+
+			"ipop:growth (new swarms, growing by that factor each time) or seeds (new swarms of the same size).")
+	restartAfterFlag     = flag.Int("restartafter", 50, "Batches without improvement that count as stagnation for --restart.")
+	restartDiversityFlag = flag.Float64("restartdiversity", 0, "Diversity (as a fraction of the domain diameter) below which --restart also restarts, if positive.")
+	fameFlag             = flag.Int("fame", 5, "Number of best solutions from different restarts to report.")
+
 	outFreqFlag = flag.Int("outputfreq", 25000, "Evaluations between outputs.")
 
 	outAllFlag = flag.Bool("outputall", false, "Output all particles instead of just the best.")
 
+	progressFlag = flag.Bool("progress", false, "Output every improvement of the global best as it happens.")
+	metricsFlag  = flag.String("metrics", "", "File to write swarm diagnostics to as CSV, one row per batch.")
+
 	m0Flag = flag.Float64("m0", 0.75, "'Starting' momentum.")
 	m1Flag = flag.Float64("m1", 0.4, "'Ending' momentum.")
 
 	radiusMultiplierFlag = flag.Float64("rmul", 0.1, "Fraction of domain to use as an initial radius.")
 	radiusDecayFlag      = flag.Float64("rdecay", 0.9, "Decay rate of radius (and inverse bounce).")
+	collideFlag          = flag.String("collide", "reflect", "Response to particles within each other's radius: reflect, elastic, repulsion:strength:perception, or nooverlap.")
 	cognitiveDecayFlag   = flag.Float64("cdecay", 0.999, "Decay rate of cognitive constants.")
 	momentumDecayFlag    = flag.Float64("mdecay", 0.9, "Decay rate for momentum calculations.")
-	momentumTypeFlag     = flag.String("mtype", "linear", "Type of momentum.")
+	momentumTypeFlag     = flag.String("mtype", "linear", "Type of momentum: constant, linear, nonlinear[:exponent], sigmoid[:center:steepness], chaotic, randexplore, randexplore2, prandexplore, recencyexplore, precencyexplore, or histweight.")
 	tugTypeFlag          = flag.String("ttype", "none", "Type of 'tug', which how momentum is altered based on its direction as compared with the acceleration computation.")
 	socConstFlag         = flag.Float64("sc", 2.05, "Social constant")
 	cogConstFlag         = flag.Float64("cc", 2.05, "Cognitive constant")
 	socLowerFlag         = flag.Float64("sclb", 0.0, "Social constant lower bound.")
 	cogLowerFlag         = flag.Float64("cclb", 0.0, "Cognitive constant lower bound.")
+	strategyFlag         = flag.String("strategy", "none", "Adaptation of momentum and soc/cog constants for --alg=standard: none or apso (which overrides --mtype, --sc and --cc).")
+
+	checkpointFlag = flag.String("checkpoint", "", "File to write checkpoints to, at every output and on interrupt.")
+	resumeFlag     = flag.String("resume", "", "Checkpoint file to resume from. All other flags (or the -config spec) must match the original run.")
+
+	seedFlag = flag.Int64("seed", 0, "Master random seed. A time-based seed is chosen (and printed) if 0.")
+
+	constraintsFlag = flag.String("constraints", "feasibility",
+		"Constraint handling for constrained functions: feasibility (Deb's rules), penalty (adaptive), "+
+			"or epsilon (the epsilon level reaches zero halfway through --n).")
+
+	sentinelsFlag = flag.Int("sentinels", 0, "Number of particles used to detect changes in dynamic functions.")
+	responseFlag  = flag.String("response", "reevaluate",
+		"Response to detected changes: reevaluate (all personal bests), or rerandomize:fraction.")
+	quantumFlag       = flag.Float64("quantum", 0, "Fraction of quantum particles, which are sampled around their informer's best.")
+	quantumRadiusFlag = flag.Float64("qradius", 0.05, "Radius of the quantum particle cloud as a fraction of the domain diameter.")
+
+	discreteFlag = flag.String("discrete", "none", "Discretization of all position coordinates: none, round, or probround.")
+
+	boundaryFlag = flag.String("boundary", "none", "Domain boundary handling: none, clamp, absorb, reflect, wrap, random, or infinity.")
 
 	backwardAdaptFlag = flag.Bool("bcog", false, "Adapt backward cognition based on non-convexity estimate.")
 )
 
+// specFlags sets each field of a run spec from its flag.
+var specFlags = map[string]func(s *spec.Spec){
+	"fit":         func(s *spec.Spec) { s.Fitness = *fitnessFlag },
+	"alg":         func(s *spec.Spec) { s.Algorithm = *algFlag },
+	"topo":        func(s *spec.Spec) { s.Topology = *topoFlag },
+	"n":           func(s *spec.Spec) { s.Evals = *iterFlag },
+	"seed":        func(s *spec.Spec) { s.Seed = *seedFlag },
+	"mtype":       func(s *spec.Spec) { s.Momentum = *momentumTypeFlag },
+	"mdecay":      func(s *spec.Spec) { s.MomentumDecay = *momentumDecayFlag },
+	"ttype":       func(s *spec.Spec) { s.Tug = *tugTypeFlag },
+	"strategy":    func(s *spec.Spec) { s.Strategy = *strategyFlag },
+	"collide":     func(s *spec.Spec) { s.Collisions = *collideFlag },
+	"boundary":    func(s *spec.Spec) { s.Boundary = *boundaryFlag },
+	"constraints": func(s *spec.Spec) { s.Constraints = *constraintsFlag },
+	"response":    func(s *spec.Spec) { s.ChangeResponse = *responseFlag },
+	"discrete":    func(s *spec.Spec) { s.Discrete = *discreteFlag },
+	"cdecay":      func(s *spec.Spec) { s.DecayAdapt = *cognitiveDecayFlag },
+	"rdecay":      func(s *spec.Spec) { s.DecayRadius = *radiusDecayFlag },
+	"rmul":        func(s *spec.Spec) { s.RadiusMultiplier = *radiusMultiplierFlag },
+	"m0":          func(s *spec.Spec) { s.Momentum0 = *m0Flag },
+	"m1":          func(s *spec.Spec) { s.Momentum1 = *m1Flag },
+	"sc":          func(s *spec.Spec) { s.SocConst = *socConstFlag },
+	"cc":          func(s *spec.Spec) { s.CogConst = *cogConstFlag },
+	"sclb":        func(s *spec.Spec) { s.SocLower = *socLowerFlag },
+	"cclb":        func(s *spec.Spec) { s.CogLower = *cogLowerFlag },
+	"bcog":        func(s *spec.Spec) { s.BackwardAdapt = *backwardAdaptFlag },
+	"sentinels":   func(s *spec.Spec) { s.Sentinels = *sentinelsFlag },
+	"quantum":     func(s *spec.Spec) { s.QuantumFraction = *quantumFlag },
+	"qradius":     func(s *spec.Spec) { s.QuantumRadius = *quantumRadiusFlag },
+	"concurrency": func(s *spec.Spec) { s.Concurrency = *concurrencyFlag },
+
+	"workers":          func(s *spec.Spec) { s.Workers = *workersFlag },
+	"batch":            func(s *spec.Spec) { s.Batch = *batchFlag },
+	"cache":            func(s *spec.Spec) { s.Cache = *cacheFlag },
+	"cachequantum":     func(s *spec.Spec) { s.CacheQuantum = *cacheQuantumFlag },
+	"species":          func(s *spec.Spec) { s.Species = *speciesRadiusFlag },
+	"archive":          func(s *spec.Spec) { s.Archive = *archiveFlag },
+	"maxtime":          func(s *spec.Spec) { s.MaxTime = maxTimeFlag.String() },
+	"stagnation":       func(s *spec.Spec) { s.Stagnation = *stagnationFlag },
+	"restart":          func(s *spec.Spec) { s.Restart = *restartFlag },
+	"restartafter":     func(s *spec.Spec) { s.RestartAfter = *restartAfterFlag },
+	"restartdiversity": func(s *spec.Spec) { s.RestartDiversity = *restartDiversityFlag },
+	"fame":             func(s *spec.Spec) { s.Fame = *fameFlag },
+}
+
+// closer is an updater with goroutines to stop, and perhaps work in flight to
+// wait for, at the end.
+type closer interface {
+	Close()
+}
+
+// evalTimer is an updater that times its function evaluations.
+type evalTimer interface {
+	EvalStats() pso.EvalStats
+}
+
+// evalUpdater is an updater that also knows its total evaluation count.
+type evalUpdater interface {
+	pso.Updater
+	Evals() int
+}
+
+// observed is an updater that reports events to an observer.
+type observed interface {
+	Observe(o pso.Observer)
+}
+
+// progressObserver prints each improvement of the global best, with the
+// batch it happened in.
+type progressObserver struct {
+	pso.NopObserver
+
+	fit   fitness.Domain
+	batch int
+}
+
+func (o *progressObserver) BatchStart(batch int) {
+	o.batch = batch
+}
+
+func (o *progressObserver) GlobalBest(p *particle.Particle) {
+	fmt.Printf("# batch %d: particle %d improved to f=%f x=%s\n", o.batch, p.Id, p.BestVal, o.fit.VecInterpreter(p.BestPos))
+}
+
+// checkpointer is an updater that can save and restore its state.
+type checkpointer interface {
+	SaveCheckpoint(path string) error
+	LoadCheckpoint(path string) error
+}
+
 var sflagre = regexp.MustCompile(`^\s*(\w+)(?::(.*))?\s*$`)
 
 func parseStringFlag(str string) (name string, args []string) {
@@ -62,14 +224,6 @@ func parseStringFlag(str string) (name string, args []string) {
 	return name, args
 }
 
-func parseInt(val string) int {
-	if intval, err := strconv.Atoi(val); err != nil {
-		panic(fmt.Sprintf("Failed to parse '%v' to int: %v", val, err))
-	} else {
-		return intval
-	}
-}
-
 func parseFloat(val string) float64 {
 	if floatval, err := strconv.ParseFloat(val, 64); err != nil {
 		panic(fmt.Sprintf("Failed to parse '%v' to float: %v", val, err))
@@ -78,390 +232,269 @@ func parseFloat(val string) float64 {
 	}
 }
 
-func main() {
-	rand.Seed(time.Now().UTC().UnixNano())
-
-	flag.Parse()
-
-	fitname, fitargs := parseStringFlag(*fitnessFlag)
-	toponame, topoargs := parseStringFlag(*topoFlag)
-
-	var fitfunc fitness.Function
-	switch fitname {
-	case "parabola", "sphere":
-		fitfunc = fitness.NewParabola(parseInt(fitargs[0]), parseFloat(fitargs[1]))
-	case "rastrigin":
-		fitfunc = fitness.NewRastrigin(parseInt(fitargs[0]), parseFloat(fitargs[1]))
-	case "rosenbrock":
-		fitfunc = fitness.NewRosenbrock(parseInt(fitargs[0]), parseFloat(fitargs[1]))
-	case "ackley":
-		fitfunc = fitness.NewAckley(parseInt(fitargs[0]), parseFloat(fitargs[1]))
-	case "easom":
-		fitfunc = fitness.NewEasom(parseInt(fitargs[0]), parseFloat(fitargs[1]))
-	case "schwefel":
-		fitfunc = fitness.NewSchwefel(parseInt(fitargs[0]), parseFloat(fitargs[1]))
-	case "dejongf4":
-		fitfunc = fitness.NewDeJongF4(parseInt(fitargs[0]), parseFloat(fitargs[1]))
-	default:
-		panic(fmt.Sprintf("Unknown function name '%v' in flag '%v'.", fitname, *fitnessFlag))
+// writeFront writes the Pareto front as CSV to the named file, or to stdout if
+// the name is empty.
+func writeFront(archive *pareto.Archive, name string) {
+	if name == "" {
+		if err := archive.WriteCSV(os.Stdout); err != nil {
+			log.Fatalf("Failed to write Pareto front: %v", err)
+		}
+		return
 	}
+	f, err := os.Create(name)
+	if err != nil {
+		log.Fatalf("Failed to create Pareto front file: %v", err)
+	}
+	if err := archive.WriteCSV(f); err != nil {
+		log.Fatalf("Failed to write Pareto front: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("Failed to write Pareto front: %v", err)
+	}
+	fmt.Printf("# wrote %d-point Pareto front to %s\n", archive.Len(), name)
+}
 
-	var topo topology.Topology
-	switch toponame {
-	case "ring":
-		topo = topology.NewRing(parseInt(topoargs[0]))
-	case "star":
-		topo = topology.NewStar(parseInt(topoargs[0]))
-	case "expander":
-		var err error
-		topo, err = topology.NewRandomExpander(rand.NewSource(rand.Int63()), parseInt(topoargs[0]), parseInt(topoargs[1]))
-		if err != nil {
-			log.Fatalf("Failed to create random expander topology: %v", err)
-		}
-	default:
-		log.Fatalf("Unknown topology name '%v' in flag '%v'.", toponame, *topoFlag)
+// writeMetrics writes the recorded swarm diagnostics to the named file.
+func writeMetrics(rec *metrics.Recorder, name string) {
+	f, err := os.Create(name)
+	if err != nil {
+		log.Fatalf("Failed to create metrics file: %v", err)
+	}
+	if err := rec.WriteCSV(f); err != nil {
+		log.Fatalf("Failed to write metrics: %v", err)
 	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("Failed to write metrics: %v", err)
+	}
+	fmt.Printf("# wrote metrics for %d batches to %s\n", len(rec.Snapshots), name)
+}
 
-	outputevery := *outFreqFlag
+func main() {
+	flag.Parse()
 
-	numIters := *iterFlag
-
-	config := pso.NewBasicConfig(func() rand.Source {
-		return rand.NewSource(rand.Int63())
-	})
-	config.DecayAdapt = *cognitiveDecayFlag
-	config.DecayRadius = *radiusDecayFlag
-	config.RadiusMultiplier = *radiusMultiplierFlag
-	config.Momentum0 = *m0Flag
-	config.Momentum1 = *m1Flag
-	config.SocConst = *socConstFlag
-	config.CogConst = *cogConstFlag
-	config.SocLower = *socLowerFlag
-	config.CogLower = *cogLowerFlag
-	config.BackwardAdapt = *backwardAdaptFlag
-
-	switch *tugTypeFlag {
-	case "none":
-		// Use the default tug function.
-	case "dtrunc":
-		// Truncate to 0 if < 0
-		config.Tug = func(dot float64) float64 {
-			if dot < 0 {
-				return 0.0
-			}
-			return 1.0
+	s := spec.New()
+	if *configFlag == "" {
+		for _, set := range specFlags {
+			set(s)
 		}
-	case "rtrunc":
-		// Truncate to 0 if < 0, based on a weighted flip.
-		randChan := make(chan float64, 1)
-		go func() {
-			for {
-				randChan <- rand.Float64()
-			}
-		}()
-		config.Tug = func(dot float64) float64 {
-			if dot < 0 && <-randChan <= math.Abs(dot) {
-				return 0.0
-			}
-			return 1.0
+	} else {
+		var err error
+		if s, err = spec.Load(*configFlag); err != nil {
+			log.Fatalf("Failed to load run spec: %v", err)
 		}
-	case "dflip":
-		// Deterministic flip - if dot product < 0, send -1.0.
-		config.Tug = func(dot float64) float64 {
-			if dot < 0 {
-				return -1.0
+		flag.Visit(func(f *flag.Flag) {
+			if set, ok := specFlags[f.Name]; ok {
+				set(s)
 			}
-			return 1.0
+		})
+	}
+	if s.Seed == 0 {
+		s.Seed = time.Now().UTC().UnixNano()
+	}
+	seed := s.Seed
+	fmt.Printf("# seed: %d\n", seed)
+	fmt.Printf("# spec: %s\n", s)
+
+	fitfunc, multifunc, err := s.Function()
+	if err != nil {
+		log.Fatal(err)
+	}
+	maxTime, err := time.ParseDuration(s.MaxTime)
+	if err != nil {
+		log.Fatalf("Failed to parse maximum running time: %v", err)
+	}
+
+	if s.Batch {
+		f, ok := fitfunc.(*fitness.Fitness)
+		if !ok {
+			log.Fatalf("Function %s cannot be evaluated in batches.", s.Fitness)
 		}
-	case "rflip":
-		// Random flip - if dot < 0, use -dot as the probability of a flip.
-		randChan := make(chan float64, 1)
-		go func() {
-			for {
-				randChan <- rand.Float64()
-			}
-		}()
-		config.Tug = func(dot float64) float64 {
-			if dot < 0 && <-randChan <= -dot {
-				return -1.0
-			}
-			return 1.0
+		fitfunc = fitness.Batched(f)
+	}
+
+	var cache *fitness.Cache
+	if s.Cache > 0 {
+		switch f := fitfunc.(type) {
+		case fitness.ConstrainedFunction, fitness.DynamicFunction, nil:
+			log.Fatalf("Function %s cannot be cached.", s.Fitness)
+		case fitness.BatchFunction:
+			bc := fitness.NewBatchCache(f, s.Cache, s.CacheQuantum)
+			cache, fitfunc = bc.Cache, bc
+		default:
+			cache = fitness.NewCache(f, s.Cache, s.CacheQuantum)
+			fitfunc = cache
 		}
-	case "rdflip":
-		// Flip in either direction based on probabilities.
-		// If the dot product is close to -1 or 1, then it should be less probable to flip it.
-		randChan := make(chan float64, 1)
-		go func() {
-			for {
-				randChan <- rand.Float64()
-			}
-		}()
-		config.Tug = func(dot float64) float64 {
-			if <-randChan > math.Abs(dot) {
-				return -dot
+		if *cacheFileFlag != "" {
+			if err := cache.LoadFile(*cacheFileFlag); err != nil {
+				log.Fatalf("Failed to load cache: %v", err)
 			}
-			return dot
+			fmt.Printf("# loaded %d cached evaluations from %s\n", cache.Stats().Size, *cacheFileFlag)
 		}
-	case "dweight":
-		// Just multiply the momentum by the dot product.
-		config.Tug = func(dot float64) float64 {
-			return dot
-		}
-	default:
-		panic(fmt.Sprintf("Unknown tug type: %s", *tugTypeFlag))
 	}
 
-	switch *momentumTypeFlag {
-	case "constant":
-		// Use the default momentum function.
-	case "linear":
-		config.Momentum = func(u pso.Updater, iter int, particle int) float64 {
-			if iter >= numIters {
-				iter = numIters - 1
-			}
-			factor := float64(iter) / float64(numIters)
-			return (1-factor)*config.Momentum0 + factor*config.Momentum1
-		}
-	case "randexplore":
-		type stateType struct {
-			improvements      int
-			batches           int
-			improvementWeight float64
-			smoothedMomentum  float64
-			momentum          float64
+	if (multifunc != nil) != (s.Algorithm == "mopso") {
+		log.Fatalf("Algorithm %s cannot optimize function %s.", s.Algorithm, s.Fitness)
+	}
+	var domain fitness.Domain = fitfunc
+	if multifunc != nil {
+		domain = multifunc
+	}
+
+	// Restarts may need topologies of other sizes, with other seeds.
+	newTopology := func(size int, seed int64) topology.Topology {
+		topo, err := s.NewTopology(size, seed)
+		if err != nil {
+			log.Fatalf("Failed to create topology: %v", err)
 		}
+		return topo
+	}
+	size, err := s.SwarmSize()
+	if err != nil {
+		log.Fatal(err)
+	}
+	topo := newTopology(size, seed)
+
+	outputevery := *outFreqFlag
+
+	config, err := s.Config(domain.Dims())
+	if err != nil {
+		log.Fatal(err)
+	}
+	apso, _ := config.Strategy.(*pso.APSO)
+	if apso != nil && s.Algorithm != "standard" {
+		log.Fatalf("Algorithm %s does not support --strategy.", s.Algorithm)
+	}
 
-		stateChan := make(chan stateType, 1)
-		stateChan <- stateType{
-			momentum:         config.Momentum0,
-			smoothedMomentum: config.Momentum0,
+	var (
+		updater evalUpdater
+		archive *pareto.Archive
+		niching *pso.NichingUpdater
+	)
+	switch s.Algorithm {
+	case "standard":
+		updater = pso.NewStandardPSO(topo, fitfunc, config)
+	case "spso2011":
+		u, err := pso.NewSPSO2011(fitfunc, topo.Size(), config.NewRNG)
+		if err != nil {
+			log.Fatalf("Failed to create SPSO 2011 updater: %v", err)
 		}
-		// Start supplying random values in a thread-safe manner.
-		randMomentum := make(chan float64)
-		go func() {
-			for {
-				randMomentum <- rand.Float64()*(config.Momentum1-config.Momentum0) + config.Momentum0
-			}
-		}()
-		improvementDecay := *momentumDecayFlag
-		config.Momentum = func(u pso.Updater, iter int, particle int) float64 {
-			state := <-stateChan
-			defer func() { stateChan <- state }()
-			imp, bat := u.Batches()
-			defer func() { state.batches, state.improvements = bat, imp }()
-			if bat == state.batches {
-				return state.momentum
-			}
-			state.improvementWeight *= improvementDecay
-			if imp != state.improvements {
-				// New batch, and we improved last time.
-				state.improvementWeight += 1.0
-				state.smoothedMomentum += (1.0 - improvementDecay) * (state.momentum - state.smoothedMomentum)
-			}
-			// We always calculate the improvement factor and use that to
-			// determine the next momentum value, weighing things between
-			// exploring randomly and exploiting the smoothed (only on
-			// improvements) value.
-			impFactor := (1 - improvementDecay) * state.improvementWeight // normalize to [0, 1]
-			state.momentum = state.smoothedMomentum + (1.0-impFactor)*(<-randMomentum-state.smoothedMomentum)
-			return state.momentum
+		updater = u
+	case "fips":
+		updater = pso.NewFIPS(topo, fitfunc, config, pso.FIPSUniform)
+	case "wfips":
+		updater = pso.NewFIPS(topo, fitfunc, config, pso.FIPSFitness)
+	case "barebones":
+		updater = pso.NewBareBones(topo, fitfunc, config, pso.BareBonesGaussian)
+	case "bbexp":
+		u := pso.NewBareBones(topo, fitfunc, config, pso.BareBonesGaussian)
+		u.Exploit = 0.5
+		updater = u
+	case "bbcauchy":
+		updater = pso.NewBareBones(topo, fitfunc, config, pso.BareBonesCauchy)
+	case "async":
+		updater = pso.NewAsync(topo, fitfunc, config, s.Workers)
+	case "binary":
+		updater = pso.NewBinary(topo, fitfunc, config)
+	case "niching":
+		niching = pso.NewNiching(fitfunc, topo.Size(), s.Species*fitfunc.Diameter(), config)
+		updater = niching
+	case "mopso":
+		if s.Stagnation > 0 {
+			log.Fatalf("Algorithm %s does not support --stagnation.", s.Algorithm)
 		}
-	case "randexplore2":
-		type stateType struct {
-			improvements        int
-			batches             int
-			smoothedImprovement float64
-			smoothedMomentum    float64
-			momentum            float64
+		if s.Archive < 1 {
+			log.Fatalf("The Pareto archive must hold at least one entry, got --archive=%d.", s.Archive)
 		}
+		u := pso.NewMOPSO(multifunc, topo.Size(), s.Archive, config)
+		archive = u.Archive
+		updater = u
+	default:
+		log.Fatalf("Unknown algorithm: %s", s.Algorithm)
+	}
 
-		stateChan := make(chan stateType, 1)
-		stateChan <- stateType{
-			momentum:         config.Momentum0,
-			smoothedMomentum: config.Momentum0,
+	var restarter *restart.Restarter
+	restartname, restartargs := parseStringFlag(s.Restart)
+	if restartname != "none" {
+		if s.Algorithm != "standard" {
+			log.Fatalf("Algorithm %s does not support --restart.", s.Algorithm)
 		}
-		// Start supplying random values in a thread-safe manner.
-		randMomentum := make(chan float64)
-		go func() {
-			for {
-				randMomentum <- rand.Float64()*(config.Momentum1-config.Momentum0) + config.Momentum0
-			}
-		}()
-		improvementDecay := *momentumDecayFlag
-		config.Momentum = func(u pso.Updater, iter int, particle int) float64 {
-			state := <-stateChan
-			defer func() { stateChan <- state }()
-			imp, bat := u.Batches()
-			defer func() { state.batches, state.improvements = bat, imp }()
-			if bat == state.batches {
-				return state.momentum
+		newUpdater := func(size int, seed int64) pso.Updater {
+			// Everything that keeps state or draws random numbers is new.
+			rs := *s
+			rs.Seed = seed
+			c, err := rs.Config(domain.Dims())
+			if err != nil {
+				log.Fatal(err)
 			}
-			improved := 0.0
-			if imp != state.improvements {
-				improved = 1.0
-				state.smoothedMomentum += (1.0 - improvementDecay) * (state.momentum - state.smoothedMomentum)
-			}
-			state.smoothedImprovement += (1.0 - improvementDecay) * (improved - state.smoothedImprovement)
-			// We always calculate the improvement factor and use that to
-			// determine the next momentum value, weighing things between
-			// exploring randomly and exploiting the smoothed (only on
-			// improvements) value.
-			state.momentum = state.smoothedMomentum + (1.0-state.smoothedImprovement)*(<-randMomentum-state.smoothedMomentum)
-			return state.momentum
-		}
-	case "prandexplore":
-		// per-particle random momentum exploration
-		type particleState struct {
-			t                   int
-			smoothedImprovement float64
-			smoothedMomentum    float64
-			momentum            float64
+			return pso.NewStandardPSO(newTopology(size, seed), fitfunc, c)
 		}
-		stateChan := make(chan map[int]particleState, 1)
-		stateChan <- make(map[int]particleState)
-		randMomentum := make(chan float64)
-		go func() {
-			for {
-				randMomentum <- rand.Float64()*(config.Momentum1-config.Momentum0) + config.Momentum0
+		var policy restart.Policy
+		switch restartname {
+		case "reinit":
+			var err error
+			if policy, err = restart.ReinitExceptBest(updater); err != nil {
+				log.Fatal(err)
 			}
-		}()
-		improvementDecay := *momentumDecayFlag
-		config.Momentum = func(u pso.Updater, iter int, pidx int) float64 {
-			state := <-stateChan
-			defer func() { stateChan <- state }()
-			particle := u.Swarm()[pidx]
-			pstate, ok := state[pidx]
-			if !ok {
-				pstate = particleState{
-					t:                particle.T,
-					smoothedMomentum: config.Momentum0,
-					momentum:         config.Momentum0,
-				}
-				state[pidx] = pstate
-			}
-			defer func() { pstate.t = particle.T }()
-			// Calculate new values - we have incremented time.
-			if pstate.t != particle.T {
-				improved := 0.0
-				if particle.BestT == particle.T { // improved last time
-					improved = 1.0
-					pstate.smoothedMomentum += (1.0 - improvementDecay) * (pstate.momentum - pstate.smoothedMomentum)
-				}
-				pstate.smoothedImprovement += (1.0 - improvementDecay) * (improved - pstate.smoothedImprovement)
-				pstate.momentum = pstate.smoothedMomentum + (1.0-pstate.smoothedImprovement)*(<-randMomentum-pstate.smoothedMomentum)
-			}
-			return pstate.momentum
-		}
-	case "recencyexplore":
-		type stateType struct {
-			t            int
-			bestT        int
-			improvements int
-			momentum     float64
+		case "ipop":
+			policy = restart.IPOP(newUpdater, topo.Size(), parseFloat(restartargs[0]), rng.Derive(seed, spec.RestartStream).Int63())
+		case "seeds":
+			policy = restart.RandomSeeds(newUpdater, topo.Size(), rng.Derive(seed, spec.RestartStream).Int63())
+		default:
+			log.Fatalf("Unknown restart policy: %s", s.Restart)
 		}
-		stateChan := make(chan stateType, 1)
-		stateChan <- stateType{momentum: config.Momentum0}
-		// Start supplying random values in a thread-safe manner.
-		randMomentum := make(chan float64)
-		go func() {
-			for {
-				randMomentum <- rand.Float64()*(config.Momentum1-config.Momentum0) + config.Momentum0
-			}
-		}()
-		improvementDecay := *momentumDecayFlag
-		config.Momentum = func(u pso.Updater, iter int, particle int) float64 {
-			state := <-stateChan
-			defer func() { stateChan <- state }()
-			imp, bat := u.Batches()
-			if bat != state.t {
-				if imp != state.improvements {
-					// The number of batch improvements changed, so we reset bestT.
-					state.bestT = bat
-				}
-				state.t = bat
-				state.improvements = imp
-
-				factor := math.Pow(improvementDecay, float64(state.t-state.bestT))
-				state.momentum = factor*state.momentum + (1-factor)*<-randMomentum
-			}
-			return state.momentum
+		detect := restart.NoImprovement(s.RestartAfter)
+		if s.RestartDiversity > 0 {
+			detect = restart.Any(detect, restart.LowDiversity(fitfunc, s.RestartDiversity))
 		}
-	case "precencyexplore":
-		type particleState struct {
-			t        int
-			momentum float64
+		restarter = restart.New(updater, fitfunc, detect, policy, s.Fame)
+		updater = restarter
+	}
+
+	cp, canCheckpoint := updater.(checkpointer)
+	if !canCheckpoint && (*resumeFlag != "" || *checkpointFlag != "") {
+		if restarter != nil {
+			log.Fatalf("Checkpoints are not supported with --restart.")
 		}
-		stateChan := make(chan map[int]particleState, 1)
-		stateChan <- make(map[int]particleState)
-		randMomentum := make(chan float64)
-		go func() {
-			for {
-				randMomentum <- rand.Float64()*(config.Momentum1-config.Momentum0) + config.Momentum0
-			}
-		}()
-		improvementDecay := *momentumDecayFlag
-		config.Momentum = func(u pso.Updater, iter int, pidx int) float64 {
-			state := <-stateChan
-			defer func() { stateChan <- state }()
-			particle := u.Swarm()[pidx]
-			pstate, ok := state[pidx]
-			if !ok {
-				pstate = particleState{
-					t:        particle.T,
-					momentum: config.Momentum0,
-				}
-				state[pidx] = pstate
-			}
-			// Calculate new values if we have incremented time.
-			if pstate.t != particle.T {
-				pstate.t = particle.T
-				factor := math.Pow(improvementDecay, float64(particle.T-particle.BestT))
-				pstate.momentum = factor*pstate.momentum + (1-factor)*<-randMomentum
-			}
-			return pstate.momentum
+		log.Fatalf("Algorithm %s does not support checkpoints.", s.Algorithm)
+	}
+	if *resumeFlag != "" || *checkpointFlag != "" {
+		if err := s.Resumable(); err != nil {
+			log.Fatalf("Cannot checkpoint this run: %v.", err)
 		}
-	case "histweight":
-		state := struct {
-			improvements      int
-			batches           int
-			improvementWeight float64
-			momentum          float64
-		}{
-			momentum: config.Momentum0,
+	}
+
+	var (
+		observers pso.Observers
+		recorder  *metrics.Recorder
+	)
+	if *progressFlag {
+		observers = append(observers, &progressObserver{fit: domain})
+	}
+	if *metricsFlag != "" {
+		if fitfunc == nil {
+			log.Fatalf("Algorithm %s does not support --metrics.", s.Algorithm)
 		}
-		improvementDecay := *momentumDecayFlag
-		config.Momentum = func(u pso.Updater, iter int, particle int) float64 {
-			imp, bat := u.Batches()
-			defer func() { state.batches, state.improvements = bat, imp }()
-			if bat != state.batches {
-				// New batch. Calculate new values.
-				state.improvementWeight *= improvementDecay
-				if imp != state.improvements {
-					// New batch, and we improved last time.
-					state.improvementWeight += 1.0
-				}
-				// The improvement factor is used to select between M0 and M1. If
-				// lots of improvement is happening, we favor M0, otherwise we push
-				// toward M1.
-				impFactor := (1 - improvementDecay) * state.improvementWeight // normalize to [0, 1]
-				state.momentum = impFactor*config.Momentum0 + (1.0-impFactor)*config.Momentum1
-			}
-			return state.momentum
+		recorder = metrics.NewRecorder(updater, fitfunc)
+		observers = append(observers, recorder)
+	}
+	if len(observers) > 0 {
+		ou, ok := updater.(observed)
+		if !ok {
+			log.Fatalf("Algorithm %s does not support --progress or --metrics.", s.Algorithm)
 		}
+		ou.Observe(observers)
 	}
 
-	updater := pso.NewStandardPSO(topo, fitfunc, config)
-
 	outputBest := func(evals int) {
 		best := updater.BestParticle()
 		fmt.Println(evals, "evals")
-		fmt.Println(best, "momentum:", config.Momentum(updater, evals, best.Id))
+		fmt.Println(best, "momentum:", config.Momentum(updater, evals-len(updater.Swarm()), best.Id))
 	}
 
 	outputAll := func(evals int) {
 		fmt.Println(evals, "evals")
 		for i, p := range updater.Swarm() {
-			fmt.Println(i, p, "momentum:", config.Momentum(updater, evals, p.Id))
+			fmt.Println(i, p, "momentum:", config.Momentum(updater, evals-len(updater.Swarm()), p.Id))
 		}
 	}
 
@@ -470,15 +503,91 @@ func main() {
 		outFn = outputAll
 	}
 
-	evals := updater.Update()
-	nextOutput := 0
-	outFn(evals)
-	for evals < *iterFlag {
-		evals += updater.Update()
-		if evals >= nextOutput {
+	if *resumeFlag != "" {
+		if err := cp.LoadCheckpoint(*resumeFlag); err != nil {
+			log.Fatalf("Failed to resume from checkpoint: %v", err)
+		}
+		fmt.Printf("# resumed from %s at %d evals\n", *resumeFlag, updater.Evals())
+	}
+
+	saveCheckpoint := func() {
+		if *checkpointFlag == "" || !updater.Initialized() {
+			return
+		}
+		if err := cp.SaveCheckpoint(*checkpointFlag); err != nil {
+			log.Fatalf("Failed to save checkpoint: %v", err)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	// Output (and checkpointing) is produced as a side effect of a criterion
+	// that never stops. Evals are counted from the start of the whole run,
+	// which might have been resumed.
+	nextOutput := updater.Evals()
+	output := func(s *pso.Status) pso.StopReason {
+		if evals := updater.Evals(); evals >= nextOutput {
 			nextOutput += outputevery
 			outFn(evals)
+			saveCheckpoint()
 		}
+		return pso.NotStopped
+	}
+
+	stops := []pso.StopCriterion{output, pso.MaxEvals(s.Evals - updater.Evals())}
+	if maxTime > 0 {
+		stops = append(stops, pso.MaxTime(maxTime))
+	}
+	if s.Stagnation > 0 {
+		stops = append(stops, pso.Stagnation(fitfunc, s.Stagnation))
+	}
+
+	result := pso.Run(ctx, updater, stops...)
+	if c, ok := updater.(closer); ok {
+		c.Close()
+	}
+	outFn(updater.Evals())
+	if result.Reason == pso.StopCanceled {
+		saveCheckpoint()
+	}
+	if archive != nil {
+		writeFront(archive, *frontFlag)
+	}
+	if recorder != nil {
+		writeMetrics(recorder, *metricsFlag)
+	}
+	if apso != nil {
+		fmt.Printf("# apso: %v f=%f %+v\n", apso.State, apso.Factor, apso.Coeffs)
+	}
+	if restarter != nil {
+		fmt.Printf("# restarts: %d\n", restarter.Restarts())
+		for i, e := range restarter.Fame.Entries() {
+			viol := ""
+			if e.Violation > 0 {
+				viol = fmt.Sprintf(" violation=%f", e.Violation)
+			}
+			fmt.Printf("# fame %d: restart %d f=%f%s x=%s\n", i, e.Restart, e.Val, viol, fitfunc.VecInterpreter(e.Pos))
+		}
+	}
+	if niching != nil {
+		for i, o := range niching.Optima() {
+			fmt.Printf("# optimum %d: f=%f x=%s radius=%f members=%d\n", i, o.Val, fitfunc.VecInterpreter(o.Pos), o.Radius, o.Members)
+		}
+	}
+	if t, ok := updater.(evalTimer); ok {
+		fmt.Printf("# eval timing: %v\n", t.EvalStats())
+	}
+	if cache != nil {
+		fmt.Printf("# cache: %v\n", cache.Stats())
+		if *cacheFileFlag != "" {
+			if err := cache.SaveFile(*cacheFileFlag); err != nil {
+				log.Fatalf("Failed to save cache: %v", err)
+			}
+		}
+	}
+	fmt.Printf("# stopped: %s after %d evals, %d batches, %v\n", result.Reason, result.Evals, result.Batches, result.Elapsed)
+	if result.Reason == pso.StopCanceled {
+		os.Exit(1)
 	}
-	outFn(evals)
 }
@@ -0,0 +1,202 @@
+// Package metrics computes diagnostics of a swarm, such as how spread out and
+// how fast it is, which help to tell premature convergence from progress.
+// Measure looks at a swarm once, and a Recorder observes an updater and
+// measures it after every batch.
+package metrics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// Distribution summarizes a set of values.
+type Distribution struct {
+	Min, Q1, Median, Q3, Max float64
+	Mean                     float64
+}
+
+// NewDistribution summarizes the values, which it sorts in place.
+func NewDistribution(vals []float64) Distribution {
+	if len(vals) == 0 {
+		return Distribution{}
+	}
+	sort.Float64s(vals)
+	quantile := func(q float64) float64 {
+		return vals[int(q*float64(len(vals)-1)+0.5)]
+	}
+	sum := 0.0
+	for _, v := range vals {
+		sum += v
+	}
+	return Distribution{
+		Min:    vals[0],
+		Q1:     quantile(0.25),
+		Median: quantile(0.5),
+		Q3:     quantile(0.75),
+		Max:    vals[len(vals)-1],
+		Mean:   sum / float64(len(vals)),
+	}
+}
+
+func (d Distribution) String() string {
+	return fmt.Sprintf("min=%g q1=%g median=%g q3=%g max=%g mean=%g", d.Min, d.Q1, d.Median, d.Q3, d.Max, d.Mean)
+}
+
+// Snapshot holds the diagnostics of a swarm at one point in a run.
+type Snapshot struct {
+	Batch int // batches before this one
+	Evals int // evaluations so far, including this batch
+
+	// Diversity is the mean distance of current positions from their
+	// centroid, as a fraction of the domain diameter.
+	Diversity float64
+
+	// MeanVelocity and MaxVelocity are velocity magnitudes, with each
+	// component taken as a fraction of the domain side length, and scaled so
+	// that crossing the whole domain along its diagonal is 1.
+	MeanVelocity float64
+	MaxVelocity  float64
+
+	// Improving is the fraction of particles whose personal best improved in
+	// the batch, and BounceRate the fraction of particles that bounced. Only
+	// a Recorder fills these in.
+	Improving  float64
+	BounceRate float64
+
+	// Staleness is the distribution of T - BestT, the time since each
+	// particle last improved its personal best.
+	Staleness Distribution
+
+	// NonConvexity is the estimate used by the BackwardAdapt option.
+	NonConvexity float64
+}
+
+// Measure computes the diagnostics that depend only on the state of the swarm.
+func Measure(swarm []*particle.Particle, f fitness.Function) Snapshot {
+	s := Snapshot{}
+	if len(swarm) == 0 {
+		return s
+	}
+	n := float64(len(swarm))
+
+	centroid := vec.New(len(swarm[0].Pos))
+	for _, p := range swarm {
+		centroid.AddBy(p.Pos)
+	}
+	centroid.SMulBy(1 / n)
+	for _, p := range swarm {
+		s.Diversity += p.Pos.Dist(centroid)
+	}
+	s.Diversity /= n * f.Diameter()
+
+	sides := f.SideLengths()
+	scale := math.Sqrt(float64(len(sides)))
+	for _, p := range swarm {
+		v := p.Vel.Copy().MapBy(func(i int, x float64) float64 { return x / sides[i] }).Mag() / scale
+		s.MeanVelocity += v
+		s.MaxVelocity = math.Max(s.MaxVelocity, v)
+	}
+	s.MeanVelocity /= n
+
+	stale := make([]float64, len(swarm))
+	for i, p := range swarm {
+		stale[i] = float64(p.T - p.BestT)
+	}
+	s.Staleness = NewDistribution(stale)
+
+	s.NonConvexity = pso.NonConvexity(swarm, f.LessFit)
+	return s
+}
+
+// Recorder is an observer that measures the swarm of an updater after every
+// batch.
+type Recorder struct {
+	pso.NopObserver
+
+	Snapshots []Snapshot
+
+	updater  pso.Updater
+	fit      fitness.Function
+	evals    int
+	improved map[int]bool
+	bounced  map[int]bool
+}
+
+// NewRecorder creates a recorder for the updater, which optimizes f. It must
+// still be given to the updater's Observe method.
+func NewRecorder(u pso.Updater, f fitness.Function) *Recorder {
+	return &Recorder{
+		updater:  u,
+		fit:      f,
+		improved: make(map[int]bool),
+		bounced:  make(map[int]bool),
+	}
+}
+
+func (r *Recorder) BatchStart(batch int) {
+	r.improved = make(map[int]bool)
+	r.bounced = make(map[int]bool)
+}
+
+func (r *Recorder) PersonalBest(p *particle.Particle) {
+	r.improved[p.Id] = true
+}
+
+func (r *Recorder) Bounced(p *particle.Particle) {
+	r.bounced[p.Id] = true
+}
+
+func (r *Recorder) BatchEnd(batch, evals int) {
+	r.evals += evals
+	swarm := r.updater.Swarm()
+	s := Measure(swarm, r.fit)
+	s.Batch = batch
+	s.Evals = r.evals
+	if len(swarm) > 0 {
+		s.Improving = float64(len(r.improved)) / float64(len(swarm))
+		s.BounceRate = float64(len(r.bounced)) / float64(len(swarm))
+	}
+	r.Snapshots = append(r.Snapshots, s)
+}
+
+// Last returns the most recent snapshot, or false if there are none.
+func (r *Recorder) Last() (Snapshot, bool) {
+	if len(r.Snapshots) == 0 {
+		return Snapshot{}, false
+	}
+	return r.Snapshots[len(r.Snapshots)-1], true
+}
+
+// WriteCSV writes one row per snapshot, with a header.
+func (r *Recorder) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{
+		"batch", "evals", "diversity", "mean_velocity", "max_velocity", "improving", "bounce_rate",
+		"staleness_min", "staleness_median", "staleness_mean", "staleness_max", "nonconvexity",
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	g := func(x float64) string { return strconv.FormatFloat(x, 'g', -1, 64) }
+	for _, s := range r.Snapshots {
+		row := []string{
+			strconv.Itoa(s.Batch), strconv.Itoa(s.Evals), g(s.Diversity), g(s.MeanVelocity), g(s.MaxVelocity),
+			g(s.Improving), g(s.BounceRate), g(s.Staleness.Min), g(s.Staleness.Median), g(s.Staleness.Mean),
+			g(s.Staleness.Max), g(s.NonConvexity),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
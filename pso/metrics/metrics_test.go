@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+func TestDistribution(t *testing.T) {
+	d := NewDistribution([]float64{4, 0, 2, 1, 3})
+	want := Distribution{Min: 0, Q1: 1, Median: 2, Q3: 3, Max: 4, Mean: 2}
+	if d != want {
+		t.Errorf("expected %v, got %v", want, d)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	// A square domain of side 100, with particles on a line from the origin,
+	// each one further away and worse than the last.
+	f := fitness.NewParabola(2, 0)
+	var swarm []*particle.Particle
+	for i := 0; i < 4; i++ {
+		pos := vec.Vec{float64(10 * i), 0}
+		p := particle.NewRandomParticle(rng.New(int64(i)), i, f)
+		p.Init(pos, vec.Vec{float64(10 * i), 0}, f.Query(pos))
+		p.T, p.BestT = 10, 10-i
+		swarm = append(swarm, p)
+	}
+
+	s := Measure(swarm, f)
+	if want := 10 / f.Diameter(); math.Abs(s.Diversity-want) > 1e-12 {
+		t.Errorf("expected diversity %v, got %v", want, s.Diversity)
+	}
+	// Velocities are 0, 0.1, 0.2 and 0.3 of a side in one dimension.
+	if want := 0.15 / math.Sqrt(2); math.Abs(s.MeanVelocity-want) > 1e-12 {
+		t.Errorf("expected mean velocity %v, got %v", want, s.MeanVelocity)
+	}
+	if want := 0.3 / math.Sqrt(2); math.Abs(s.MaxVelocity-want) > 1e-12 {
+		t.Errorf("expected max velocity %v, got %v", want, s.MaxVelocity)
+	}
+	if s.Staleness.Min != 0 || s.Staleness.Max != 3 || s.Staleness.Mean != 1.5 {
+		t.Errorf("unexpected staleness %v", s.Staleness)
+	}
+	if s.NonConvexity != 0 {
+		t.Errorf("expected no non-convexity, got %v", s.NonConvexity)
+	}
+
+	// Moving the worst particle back next to the best one is a surprise.
+	swarm[3].BestPos = vec.Vec{-10, 0}
+	if got := Measure(swarm, f).NonConvexity; got != 0.5 {
+		t.Errorf("expected non-convexity 0.5, got %v", got)
+	}
+}
+
+func TestRecorderSeesConvergence(t *testing.T) {
+	f := fitness.NewParabola(4, 0.25)
+	conf := pso.NewBasicConfig(rng.Streams(8))
+	conf.Momentum0 = 0.7298
+	conf.SocConst, conf.CogConst = 1.49618, 1.49618
+	conf.RadiusMultiplier = 0.02
+	u := pso.NewStandardPSO(topology.NewRing(20), f, conf)
+	rec := NewRecorder(u, f)
+	u.Observe(rec)
+	pso.Run(context.Background(), u, pso.MaxEvals(4000))
+
+	if _, total := u.Batches(); len(rec.Snapshots) != total {
+		t.Fatalf("expected %d snapshots, got %d", total, len(rec.Snapshots))
+	}
+	first := rec.Snapshots[0]
+	last, _ := rec.Last()
+	if first.Improving != 1 {
+		t.Errorf("expected every particle to improve on initialization, got %v", first.Improving)
+	}
+	if last.Evals != u.Evals() {
+		t.Errorf("expected %d evals in the last snapshot, got %d", u.Evals(), last.Evals)
+	}
+	if last.Diversity >= first.Diversity/2 {
+		t.Errorf("expected diversity to shrink, went from %v to %v", first.Diversity, last.Diversity)
+	}
+	bounced := false
+	for _, s := range rec.Snapshots {
+		if s.Improving < 0 || s.Improving > 1 || s.BounceRate < 0 || s.BounceRate > 1 {
+			t.Errorf("batch %d has rates out of range: %+v", s.Batch, s)
+		}
+		bounced = bounced || s.BounceRate > 0
+	}
+	if !bounced {
+		t.Errorf("expected some bounces")
+	}
+
+	var buf bytes.Buffer
+	if err := rec.WriteCSV(&buf); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(rec.Snapshots)+1 {
+		t.Errorf("expected %d CSV lines, got %d", len(rec.Snapshots)+1, lines)
+	}
+}
@@ -0,0 +1,300 @@
+// Package momentum provides schedules for the momentum (inertia weight) of the
+// standard PSO update, for use as pso.Config.Momentum. Most of them move
+// between a starting momentum m0, which is usually high to explore, and m1,
+// which is usually low to let the swarm converge, either on a fixed schedule
+// or in response to how the swarm is doing.
+//
+// The schedules are safe to call from concurrent particle updates. Random ones
+// draw from the sources they are given, so runs with the same seeds can be
+// reproduced: schedules for the whole swarm change once per batch, whichever
+// particle asks first, and per-particle schedules give each particle its own
+// source.
+//
+// Schedules that adapt to the swarm, or draw random numbers, keep their state
+// inside the returned function, where swarm checkpoints cannot reach it.
+package momentum
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/shiblon/entrogo/pso"
+)
+
+// uniform returns a function that draws uniformly between m0 and m1.
+func uniform(m0, m1 float64, rsrc rand.Source) func() float64 {
+	rgen := rand.New(rsrc)
+	return func() float64 {
+		return rgen.Float64()*(m1-m0) + m0
+	}
+}
+
+// batchTracker notices when an updater starts a new batch, and whether the
+// last one improved its best particle.
+type batchTracker struct {
+	batches      int
+	improvements int
+}
+
+// next returns whether u has started a new batch since the last call, and if
+// so, whether the best particle improved in between.
+func (b *batchTracker) next(u pso.Updater) (newBatch, improved bool) {
+	imp, bat := u.Batches()
+	newBatch, improved = bat != b.batches, imp != b.improvements
+	b.batches, b.improvements = bat, imp
+	return newBatch, improved
+}
+
+// Linear moves momentum from m0 to m1 in a straight line over n evaluations,
+// and then stays near m1.
+func Linear(m0, m1 float64, n int) pso.MomentumFunc {
+	return func(u pso.Updater, iter int, particle int) float64 {
+		if iter >= n {
+			iter = n - 1
+		}
+		factor := float64(iter) / float64(n)
+		return (1-factor)*m0 + factor*m1
+	}
+}
+
+// NonlinearDecreasing moves momentum from m0 to m1 over n evaluations along
+// m1 + (m0-m1)((n-iter)/n)^exponent, as in Chatterjee and Siarry, "Nonlinear
+// inertia weight variation for dynamic adaptation in particle swarm
+// optimization" (2006). Exponents above 1 leave the high momentum phase
+// early, and those below 1 stay in it longer.
+func NonlinearDecreasing(m0, m1 float64, n int, exponent float64) pso.MomentumFunc {
+	return func(u pso.Updater, iter int, particle int) float64 {
+		left := math.Max(0, float64(n-iter)/float64(n))
+		return m1 + (m0-m1)*math.Pow(left, exponent)
+	}
+}
+
+// Sigmoid moves momentum from m0 to m1 along a sigmoid in the fraction of n
+// evaluations done, centered at center and with the given steepness, as in
+// Malik et al., "Sigmoid increasing inertia weight in particle swarm
+// optimization" (2007), which uses a center of 0.25 and a nearly sudden
+// change. Passing m0 < m1 gives the increasing version.
+func Sigmoid(m0, m1 float64, n int, center, steepness float64) pso.MomentumFunc {
+	return func(u pso.Updater, iter int, particle int) float64 {
+		x := float64(iter) / float64(n)
+		return (m0-m1)/(1+math.Exp(steepness*(x-center))) + m1
+	}
+}
+
+// ChaoticLogistic moves momentum from m0 to m1 over n evaluations like Linear,
+// but with the m1 term scaled by a logistic map z <- 4z(1-z) that takes one
+// step per batch, as in the chaotic decreasing inertia weight of Feng et al.,
+// "Chaotic inertia weight in particle swarm optimization" (2007). The map
+// starts from a random point in (0, 1), avoiding its fixed and periodic
+// points.
+func ChaoticLogistic(m0, m1 float64, n int, rsrc rand.Source) pso.MomentumFunc {
+	rgen := rand.New(rsrc)
+	z := 0.0
+	for z == 0 || z == 0.25 || z == 0.5 || z == 0.75 {
+		z = rgen.Float64()
+	}
+	var (
+		mu      sync.Mutex
+		batches batchTracker
+	)
+	return func(u pso.Updater, iter int, particle int) float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		if newBatch, _ := batches.next(u); newBatch {
+			z = 4 * z * (1 - z)
+		}
+		left := math.Max(0, float64(n-iter)/float64(n))
+		return (m0-m1)*left + m1*z
+	}
+}
+
+// RandExplore picks a new momentum for the swarm in each batch, somewhere
+// between a random value in [m0, m1] and a smoothed average of the momenta
+// that came before improvements. The more often the swarm has improved
+// recently (with decay weighing the history), the closer it stays to the
+// smoothed average.
+func RandExplore(m0, m1, decay float64, rsrc rand.Source) pso.MomentumFunc {
+	draw := uniform(m0, m1, rsrc)
+	var (
+		mu                sync.Mutex
+		batches           batchTracker
+		improvementWeight float64
+	)
+	smoothedMomentum, momentum := m0, m0
+	return func(u pso.Updater, iter int, particle int) float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		newBatch, improved := batches.next(u)
+		if !newBatch {
+			return momentum
+		}
+		improvementWeight *= decay
+		if improved {
+			improvementWeight += 1.0
+			smoothedMomentum += (1.0 - decay) * (momentum - smoothedMomentum)
+		}
+		// Weigh things between exploring randomly and exploiting the smoothed
+		// (only on improvements) value.
+		impFactor := (1 - decay) * improvementWeight // normalize to [0, 1]
+		momentum = smoothedMomentum + (1.0-impFactor)*(draw()-smoothedMomentum)
+		return momentum
+	}
+}
+
+// RandExplore2 is like RandExplore, but measures recent improvement with an
+// exponential moving average of whether each batch improved.
+func RandExplore2(m0, m1, decay float64, rsrc rand.Source) pso.MomentumFunc {
+	draw := uniform(m0, m1, rsrc)
+	var (
+		mu                  sync.Mutex
+		batches             batchTracker
+		smoothedImprovement float64
+	)
+	smoothedMomentum, momentum := m0, m0
+	return func(u pso.Updater, iter int, particle int) float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		newBatch, improved := batches.next(u)
+		if !newBatch {
+			return momentum
+		}
+		imp := 0.0
+		if improved {
+			imp = 1.0
+			smoothedMomentum += (1.0 - decay) * (momentum - smoothedMomentum)
+		}
+		smoothedImprovement += (1.0 - decay) * (imp - smoothedImprovement)
+		momentum = smoothedMomentum + (1.0-smoothedImprovement)*(draw()-smoothedMomentum)
+		return momentum
+	}
+}
+
+// particleState is the momentum state of one particle, for the per-particle
+// schedules.
+type particleState struct {
+	t                   int
+	smoothedImprovement float64
+	smoothedMomentum    float64
+	momentum            float64
+	draw                func() float64
+}
+
+// particleStates keeps the state of each particle of a per-particle schedule.
+type particleStates struct {
+	sync.Mutex
+	m0, m1 float64
+	newRNG func(id int) rand.Source
+	states map[int]*particleState
+}
+
+// get returns the particle's state, creating it if needed, and whether the
+// particle has moved since the last call. The caller must hold the lock.
+func (ps *particleStates) get(u pso.Updater, pidx int) (p *particleState, moved bool) {
+	particle := u.Swarm()[pidx]
+	p, ok := ps.states[pidx]
+	if !ok {
+		p = &particleState{
+			t:                particle.T,
+			smoothedMomentum: ps.m0,
+			momentum:         ps.m0,
+			draw:             uniform(ps.m0, ps.m1, ps.newRNG(pidx)),
+		}
+		ps.states[pidx] = p
+	}
+	moved = p.t != particle.T
+	p.t = particle.T
+	return p, moved
+}
+
+// ParticleRandExplore is RandExplore2 for each particle on its own, using
+// whether its personal best improved. Particle i draws from newRNG(i).
+func ParticleRandExplore(m0, m1, decay float64, newRNG func(id int) rand.Source) pso.MomentumFunc {
+	states := &particleStates{m0: m0, m1: m1, newRNG: newRNG, states: make(map[int]*particleState)}
+	return func(u pso.Updater, iter int, pidx int) float64 {
+		states.Lock()
+		defer states.Unlock()
+		p, moved := states.get(u, pidx)
+		if moved {
+			particle := u.Swarm()[pidx]
+			improved := 0.0
+			if particle.BestT == particle.T { // improved last time
+				improved = 1.0
+				p.smoothedMomentum += (1.0 - decay) * (p.momentum - p.smoothedMomentum)
+			}
+			p.smoothedImprovement += (1.0 - decay) * (improved - p.smoothedImprovement)
+			p.momentum = p.smoothedMomentum + (1.0-p.smoothedImprovement)*(p.draw()-p.smoothedMomentum)
+		}
+		return p.momentum
+	}
+}
+
+// RecencyExplore blends the swarm's momentum toward a random value in [m0, m1]
+// in each batch, by more the longer it has been since the best particle
+// improved: the old momentum keeps a weight of decay^(batches since).
+func RecencyExplore(m0, m1, decay float64, rsrc rand.Source) pso.MomentumFunc {
+	draw := uniform(m0, m1, rsrc)
+	var (
+		mu      sync.Mutex
+		batches batchTracker
+		bestT   int
+	)
+	momentum := m0
+	return func(u pso.Updater, iter int, particle int) float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		newBatch, improved := batches.next(u)
+		if newBatch {
+			if improved {
+				bestT = batches.batches
+			}
+			factor := math.Pow(decay, float64(batches.batches-bestT))
+			momentum = factor*momentum + (1-factor)*draw()
+		}
+		return momentum
+	}
+}
+
+// ParticleRecencyExplore is RecencyExplore for each particle on its own,
+// using the time since its personal best improved. Particle i draws from
+// newRNG(i).
+func ParticleRecencyExplore(m0, m1, decay float64, newRNG func(id int) rand.Source) pso.MomentumFunc {
+	states := &particleStates{m0: m0, m1: m1, newRNG: newRNG, states: make(map[int]*particleState)}
+	return func(u pso.Updater, iter int, pidx int) float64 {
+		states.Lock()
+		defer states.Unlock()
+		p, moved := states.get(u, pidx)
+		if moved {
+			particle := u.Swarm()[pidx]
+			factor := math.Pow(decay, float64(particle.T-particle.BestT))
+			p.momentum = factor*p.momentum + (1-factor)*p.draw()
+		}
+		return p.momentum
+	}
+}
+
+// HistoryWeight sets the swarm's momentum between m0 and m1 by how often the
+// best particle has improved recently, weighing each batch by decay^age: m0
+// when it improves all of the time, and m1 when it does not improve at all.
+func HistoryWeight(m0, m1, decay float64) pso.MomentumFunc {
+	var (
+		mu                sync.Mutex
+		batches           batchTracker
+		improvementWeight float64
+	)
+	momentum := m0
+	return func(u pso.Updater, iter int, particle int) float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		if newBatch, improved := batches.next(u); newBatch {
+			improvementWeight *= decay
+			if improved {
+				improvementWeight += 1.0
+			}
+			// Lots of improvement favors m0, otherwise we push toward m1.
+			impFactor := (1 - decay) * improvementWeight // normalize to [0, 1]
+			momentum = impFactor*m0 + (1.0-impFactor)*m1
+		}
+		return momentum
+	}
+}
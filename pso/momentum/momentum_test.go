@@ -0,0 +1,309 @@
+package momentum
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+)
+
+const (
+	m0    = 0.9
+	m1    = 0.4
+	decay = 0.8
+)
+
+// stubUpdater is an updater whose batches and particle times are set
+// directly.
+type stubUpdater struct {
+	improved, batches int
+	swarm             []*particle.Particle
+}
+
+func newStub(size int) *stubUpdater {
+	u := &stubUpdater{}
+	for i := 0; i < size; i++ {
+		u.swarm = append(u.swarm, &particle.Particle{Id: i})
+	}
+	return u
+}
+
+func (u *stubUpdater) Initialized() bool                { return true }
+func (u *stubUpdater) Swarm() []*particle.Particle      { return u.swarm }
+func (u *stubUpdater) Update() int                      { return 0 }
+func (u *stubUpdater) BestParticle() *particle.Particle { return u.swarm[0] }
+func (u *stubUpdater) Batches() (improved, total int)   { return u.improved, u.batches }
+
+var _ pso.Updater = (*stubUpdater)(nil)
+
+// batch starts a new batch, in which the best particle and each particle's
+// personal best improved or not.
+func (u *stubUpdater) batch(improved bool) {
+	u.batches++
+	if improved {
+		u.improved++
+	}
+	for _, p := range u.swarm {
+		p.T++
+		if improved {
+			p.BestT = p.T
+		}
+	}
+}
+
+// draws returns the first n values that a schedule gets from rsrc.
+func draws(rsrc rand.Source, n int) []float64 {
+	draw := uniform(m0, m1, rsrc)
+	vals := make([]float64, n)
+	for i := range vals {
+		vals[i] = draw()
+	}
+	return vals
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-12
+}
+
+func TestLinear(t *testing.T) {
+	f := Linear(m0, m1, 100)
+	cases := []struct {
+		iter int
+		want float64
+	}{
+		{0, m0},
+		{50, (m0 + m1) / 2},
+		{99, 0.01*m0 + 0.99*m1},
+		{1000, 0.01*m0 + 0.99*m1},
+	}
+	for _, c := range cases {
+		if got := f(nil, c.iter, 0); !near(got, c.want) {
+			t.Errorf("at %d: expected %v, got %v", c.iter, c.want, got)
+		}
+	}
+}
+
+func TestNonlinearDecreasing(t *testing.T) {
+	f := NonlinearDecreasing(m0, m1, 100, 2)
+	cases := []struct {
+		iter int
+		want float64
+	}{
+		{0, m0},
+		{50, m1 + 0.25*(m0-m1)},
+		{90, m1 + 0.01*(m0-m1)},
+		{100, m1},
+		{1000, m1},
+	}
+	for _, c := range cases {
+		if got := f(nil, c.iter, 0); !near(got, c.want) {
+			t.Errorf("at %d: expected %v, got %v", c.iter, c.want, got)
+		}
+	}
+	// An exponent of 1 is a straight line.
+	if got, want := NonlinearDecreasing(m0, m1, 100, 1)(nil, 30, 0), 0.7*m0+0.3*m1; !near(got, want) {
+		t.Errorf("expected %v with exponent 1, got %v", want, got)
+	}
+}
+
+func TestSigmoid(t *testing.T) {
+	f := Sigmoid(m0, m1, 1000, 0.25, 20)
+	if got := f(nil, 250, 0); !near(got, (m0+m1)/2) {
+		t.Errorf("expected the midpoint at the center, got %v", got)
+	}
+	if got := f(nil, 0, 0); math.Abs(got-m0) > 0.01 {
+		t.Errorf("expected about %v at the start, got %v", m0, got)
+	}
+	if got := f(nil, 1000, 0); math.Abs(got-m1) > 1e-6 {
+		t.Errorf("expected about %v at the end, got %v", m1, got)
+	}
+	last := math.Inf(1)
+	for iter := 0; iter <= 1000; iter += 10 {
+		got := f(nil, iter, 0)
+		if got > last {
+			t.Fatalf("expected momentum to decrease, went from %v to %v at %d", last, got, iter)
+		}
+		last = got
+	}
+}
+
+func TestChaoticLogistic(t *testing.T) {
+	const n = 100
+	u := newStub(3)
+	f := ChaoticLogistic(m0, m1, n, rng.New(3))
+	z := rand.New(rng.New(3)).Float64()
+	for b := 0; b < 20; b++ {
+		u.batch(false)
+		z = 4 * z * (1 - z)
+		iter := 5 * b
+		want := (m0-m1)*float64(n-iter)/n + m1*z
+		for i := range u.swarm {
+			if got := f(u, iter, i); !near(got, want) {
+				t.Fatalf("batch %d, particle %d: expected %v, got %v", b, i, want, got)
+			}
+		}
+	}
+}
+
+func TestRandExplore(t *testing.T) {
+	for name, newf := range map[string]func(rand.Source) pso.MomentumFunc{
+		"randexplore":  func(r rand.Source) pso.MomentumFunc { return RandExplore(m0, m1, decay, r) },
+		"randexplore2": func(r rand.Source) pso.MomentumFunc { return RandExplore2(m0, m1, decay, r) },
+	} {
+		// Without improvements, each batch gets a fresh random value.
+		u := newStub(2)
+		f := newf(rng.New(5))
+		if got := f(u, 0, 0); got != m0 {
+			t.Errorf("%s: expected %v before the first batch, got %v", name, m0, got)
+		}
+		for b, want := range draws(rng.New(5), 10) {
+			u.batch(false)
+			for i := range u.swarm {
+				if got := f(u, b, i); !near(got, want) {
+					t.Errorf("%s: batch %d: expected %v, got %v", name, b, want, got)
+				}
+			}
+		}
+
+		// With steady improvement, it settles down.
+		u = newStub(1)
+		f = newf(rng.New(5))
+		var last float64
+		for b := 0; b < 200; b++ {
+			u.batch(true)
+			got := f(u, b, 0)
+			if got < m1 || got > m0 {
+				t.Fatalf("%s: batch %d: %v is out of range", name, b, got)
+			}
+			if b == 199 && math.Abs(got-last) > 1e-6 {
+				t.Errorf("%s: expected momentum to settle, still moving from %v to %v", name, last, got)
+			}
+			last = got
+		}
+	}
+}
+
+func TestParticleRandExplore(t *testing.T) {
+	// Without improvements, each particle's momentum is drawn from its own
+	// stream, no matter how the others are asked.
+	u := newStub(3)
+	f := ParticleRandExplore(m0, m1, decay, rng.Streams(7))
+	want := [][]float64{
+		draws(rng.Derive(7, 0), 10),
+		draws(rng.Derive(7, 1), 10),
+		draws(rng.Derive(7, 2), 10),
+	}
+	for i := range u.swarm {
+		if got := f(u, 0, i); got != m0 {
+			t.Errorf("particle %d: expected %v before moving, got %v", i, m0, got)
+		}
+	}
+	for b := 0; b < 10; b++ {
+		u.batch(false)
+		for _, i := range []int{2, 0, 1, 0} {
+			if got := f(u, b, i); !near(got, want[i][b]) {
+				t.Errorf("batch %d, particle %d: expected %v, got %v", b, i, want[i][b], got)
+			}
+		}
+	}
+}
+
+func TestRecencyExplore(t *testing.T) {
+	// While improving, momentum stays put.
+	u := newStub(1)
+	f := RecencyExplore(m0, m1, decay, rng.New(9))
+	for b := 0; b < 5; b++ {
+		u.batch(true)
+		if got := f(u, b, 0); got != m0 {
+			t.Fatalf("batch %d: expected %v while improving, got %v", b, m0, got)
+		}
+	}
+	// Then it moves toward random values faster the longer it goes without.
+	// Every batch draws one, even while improving.
+	want := m0
+	for k, r := range draws(rng.New(9), 15)[5:] {
+		u.batch(false)
+		factor := math.Pow(decay, float64(k+1))
+		want = factor*want + (1-factor)*r
+		if got := f(u, k, 0); !near(got, want) {
+			t.Errorf("%d batches since improving: expected %v, got %v", k+1, want, got)
+		}
+	}
+}
+
+func TestParticleRecencyExplore(t *testing.T) {
+	u := newStub(2)
+	f := ParticleRecencyExplore(m0, m1, decay, rng.Streams(11))
+	for i := range u.swarm {
+		f(u, 0, i)
+	}
+	u.batch(true)
+	for i := range u.swarm {
+		if got := f(u, 0, i); got != m0 {
+			t.Errorf("particle %d: expected %v after improving, got %v", i, m0, got)
+		}
+	}
+	for i := range u.swarm {
+		want := m0
+		for k, r := range draws(rng.Derive(11, i), 11)[1:] {
+			u.swarm[i].T++
+			factor := math.Pow(decay, float64(k+1))
+			want = factor*want + (1-factor)*r
+			if got := f(u, k, i); !near(got, want) {
+				t.Errorf("particle %d, %d steps since improving: expected %v, got %v", i, k+1, want, got)
+			}
+		}
+	}
+}
+
+func TestHistoryWeight(t *testing.T) {
+	u := newStub(1)
+	f := HistoryWeight(m0, m1, decay)
+	for k := 1; k <= 10; k++ {
+		u.batch(true)
+		// The improvement weight is the sum of decay^j for j < k.
+		d := math.Pow(decay, float64(k))
+		if got, want := f(u, k, 0), (1-d)*m0+d*m1; !near(got, want) {
+			t.Errorf("after %d improvements: expected %v, got %v", k, want, got)
+		}
+	}
+	u = newStub(1)
+	f = HistoryWeight(m0, m1, decay)
+	u.batch(false)
+	if got := f(u, 0, 0); got != m1 {
+		t.Errorf("expected %v without improvement, got %v", m1, got)
+	}
+}
+
+func TestSchedulesRun(t *testing.T) {
+	const evals = 5000
+	schedules := map[string]pso.MomentumFunc{
+		"linear":          Linear(m0, m1, evals),
+		"nonlinear":       NonlinearDecreasing(m0, m1, evals, 1.2),
+		"sigmoid":         Sigmoid(m0, m1, evals, 0.25, 10),
+		"chaotic":         ChaoticLogistic(m0, m1, evals, rng.New(1)),
+		"randexplore":     RandExplore(m0, m1, decay, rng.New(1)),
+		"randexplore2":    RandExplore2(m0, m1, decay, rng.New(1)),
+		"prandexplore":    ParticleRandExplore(m0, m1, decay, rng.Streams(1)),
+		"recencyexplore":  RecencyExplore(m0, m1, decay, rng.New(1)),
+		"precencyexplore": ParticleRecencyExplore(m0, m1, decay, rng.Streams(1)),
+		"histweight":      HistoryWeight(m0, m1, decay),
+	}
+	for name, f := range schedules {
+		conf := pso.NewBasicConfig(rng.Streams(2))
+		conf.Momentum = f
+		conf.SocConst, conf.CogConst = 1.49618, 1.49618
+		conf.RadiusMultiplier = 0
+		u := pso.NewStandardPSO(topology.NewRing(20), fitness.NewParabola(3, 0.25), conf)
+		res := pso.Run(context.Background(), u, pso.MaxEvals(evals))
+		if math.IsNaN(res.BestVal) || res.BestVal > 1 {
+			t.Errorf("%s: expected progress, got best value %v", name, res.BestVal)
+		}
+	}
+}
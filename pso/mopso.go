@@ -0,0 +1,192 @@
+package pso
+
+import (
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/pareto"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// MOPSOUpdater is a multi-objective PSO in the style of Coello Coello et al.
+// ("Handling Multiple Objectives With Particle Swarm Optimization", 2004), with
+// crowding-distance archive pruning and leader selection as in Sierra and
+// Coello Coello (2005). Non-dominated solutions are kept in an external,
+// bounded archive. Each particle follows a leader picked from the archive by
+// binary tournament on crowding distance, which favors sparse regions of the
+// front.
+//
+// Since particles carry scalar values, Val and BestVal hold the first
+// objective, and BestParticle is the particle with the smallest first
+// objective among personal bests: one end of the current front. The whole
+// front is available from Archive. Observers are told of global bests by
+// first objective too, and a batch counts as improved if it changed the
+// archive.
+//
+// From Conf, it uses NewRNG, VelCapMultiplier, Boundary and Concurrency.
+type MOPSOUpdater struct {
+	swarmCore
+
+	Fitness fitness.MultiFunction
+	Conf    *Config
+	Archive *pareto.Archive
+	Size    int
+	W       float64 // inertia weight
+	C1, C2  float64 // cognitive and social constants
+
+	vals     []vec.Vec // current objective values
+	bestVals []vec.Vec // personal best objective values
+	leaders  []int     // archive index of each particle's leader
+}
+
+// NewMOPSO creates a multi-objective updater with a swarm of the given size
+// and an archive that holds up to archiveSize solutions, which must be at
+// least 1.
+func NewMOPSO(f fitness.MultiFunction, size, archiveSize int, c *Config) *MOPSOUpdater {
+	return &MOPSOUpdater{
+		swarmCore: newSwarmCore(f),
+		Fitness:   f,
+		Conf:      c,
+		Archive:   pareto.NewArchive(archiveSize),
+		Size:      size,
+		W:         0.4,
+		C1:        1.5,
+		C2:        1.5,
+	}
+}
+
+// BestParticle returns the particle with the smallest first objective.
+func (u *MOPSOUpdater) BestParticle() *particle.Particle {
+	best := u.swarm[0]
+	for _, p := range u.swarm[1:] {
+		if p.BestVal < best.BestVal {
+			best = p
+		}
+	}
+	return best
+}
+
+// BestVals returns the personal best objective values of the particle at
+// index i.
+func (u *MOPSOUpdater) BestVals(i int) vec.Vec {
+	return u.bestVals[i]
+}
+
+func (u *MOPSOUpdater) init() int {
+	u.swarm = make([]*particle.Particle, u.Size)
+	u.vals = make([]vec.Vec, u.Size)
+	u.bestVals = make([]vec.Vec, u.Size)
+	u.leaders = make([]int, u.Size)
+	for i := range u.swarm {
+		u.swarm[i] = particle.NewRandomParticle(u.Conf.NewRNG(i), i, u.Fitness)
+	}
+	u.evaluate(func(p *particle.Particle) vec.Vec { return p.Pos })
+	for i, p := range u.swarm {
+		u.bestVals[i] = u.vals[i].Copy()
+		p.ResetVal(u.vals[i][0])
+		u.reportEval(p, true, u.lessFit)
+	}
+	u.initialized = true
+	return len(u.swarm)
+}
+
+// evaluate queries the function at pos(p) for every particle using the
+// evaluation pool, storing results in u.vals.
+func (u *MOPSOUpdater) evaluate(pos func(p *particle.Particle) vec.Vec) {
+	u.pool.run(len(u.swarm), func(i int) {
+		u.pool.timed(1, func() { u.vals[i] = u.Fitness.QueryMulti(pos(u.swarm[i])) })
+	})
+	u.totalEvals += len(u.swarm)
+}
+
+// Update moves the swarm from one time slice to another. The first call moves
+// the swarm to t[0] by initializing it. After that it ticks the clock with each call.
+// Returns the number of function evaluations performed.
+func (u *MOPSOUpdater) Update() (evals int) {
+	u.beginBatch()
+	defer func() { u.endBatch(evals) }()
+	u.limitConcurrency(u.Conf.Concurrency)
+	if !u.Initialized() {
+		evals = u.init()
+	} else {
+		u.selectLeaders()
+		u.moveAll(u.moveOneParticle)
+		u.evaluate(func(p *particle.Particle) vec.Vec { return p.Scratch().Pos })
+		evals = len(u.swarm)
+		for i, p := range u.swarm {
+			u.reportEval(p, u.updateBest(i, p), u.lessFit)
+		}
+	}
+
+	// Archive updates happen in index order, which keeps them reproducible.
+	changed := false
+	for i, p := range u.swarm {
+		if u.Archive.Add(p.Pos, u.vals[i]) {
+			changed = true
+		}
+	}
+	u.countBatch(changed)
+	return evals
+}
+
+// lessFit compares first objectives, as BestParticle does.
+func (u *MOPSOUpdater) lessFit(a, aViol, b, bViol float64) bool {
+	return b < a
+}
+
+// selectLeaders picks an archive leader for every particle by binary
+// tournament on crowding distance, using each particle's own random source.
+func (u *MOPSOUpdater) selectLeaders() {
+	crowding := u.Archive.Crowding()
+	n := len(crowding)
+	for i, p := range u.swarm {
+		a, b := p.Rand().Intn(n), p.Rand().Intn(n)
+		if crowding[b] > crowding[a] {
+			a = b
+		}
+		u.leaders[i] = a
+	}
+}
+
+func (u *MOPSOUpdater) moveOneParticle(pidx int) {
+	p := u.swarm[pidx]
+	dims := len(p.Pos)
+	leader := u.Archive.Entries()[u.leaders[pidx]].Pos
+
+	rCog := vec.NewFFilled(dims, p.Rand().Float64).SMulBy(u.C1)
+	rSoc := vec.NewFFilled(dims, p.Rand().Float64).SMulBy(u.C2)
+	acc := p.BestPos.Sub(p.Pos).MulBy(rCog).AddBy(leader.Sub(p.Pos).MulBy(rSoc))
+
+	scratch := p.Scratch()
+	scratch.Vel.Replace(p.Vel).SMulBy(u.W).AddBy(acc)
+	capVelocity(scratch.Vel, u.Fitness.SideLengths(), u.Conf.VelCapMultiplier)
+	scratch.Pos.Replace(p.Pos).AddBy(scratch.Vel)
+	scratch.Bounced = false
+	scratch.WallHit = false
+
+	// Objectives are often undefined outside of the domain, so positions are
+	// always clamped, after applying any other boundary policy.
+	if u.Conf.Boundary != nil {
+		hits, _ := u.Conf.Boundary(scratch, u.minCorner, u.maxCorner, p.Rand())
+		scratch.WallHit = hits > 0
+	}
+	if hits, _ := ClampBoundary(scratch, u.minCorner, u.maxCorner, nil); hits > 0 {
+		scratch.WallHit = true
+	}
+}
+
+// updateBest makes the scratch state current, and replaces the personal best
+// if the new position dominates it. If neither dominates the other, a coin
+// flip decides. Returns whether the personal best was replaced.
+func (u *MOPSOUpdater) updateBest(i int, p *particle.Particle) bool {
+	p.Scratch().Val = u.vals[i][0]
+	p.UpdateCur()
+	switch {
+	case pareto.Dominates(u.bestVals[i], u.vals[i]):
+		return false
+	case pareto.Dominates(u.vals[i], u.bestVals[i]) || p.Rand().Float64() < 0.5:
+		u.bestVals[i].Replace(u.vals[i])
+		p.UpdateBest()
+		return true
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package pso
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/pareto"
+	"github.com/shiblon/entrogo/pso/rng"
+)
+
+func TestMOPSOApproachesZDT1Front(t *testing.T) {
+	f := fitness.NewZDT1(10)
+	u := NewMOPSO(f, 50, 100, NewBasicConfig(rng.Streams(8)))
+	Run(context.Background(), u, MaxEvals(25000))
+
+	entries := u.Archive.Entries()
+	if len(entries) < 20 || len(entries) > 100 {
+		t.Fatalf("expected between 20 and 100 archive entries, got %d", len(entries))
+	}
+	// The true front is f2 = 1 - sqrt(f1), reached when g == 1.
+	worst := 0.0
+	minF1, maxF1 := math.Inf(1), math.Inf(-1)
+	for i, e := range entries {
+		worst = math.Max(worst, e.Vals[1]-(1-math.Sqrt(e.Vals[0])))
+		minF1, maxF1 = math.Min(minF1, e.Vals[0]), math.Max(maxF1, e.Vals[0])
+		for j, other := range entries {
+			if i != j && pareto.Dominates(other.Vals, e.Vals) {
+				t.Fatalf("archive entry %v is dominated by %v", e.Vals, other.Vals)
+			}
+		}
+	}
+	if worst > 0.1 {
+		t.Errorf("expected all entries within 0.1 of the true front, worst is %v", worst)
+	}
+	if maxF1-minF1 < 0.8 {
+		t.Errorf("expected the front to span most of f1 in [0, 1], got [%v, %v]", minF1, maxF1)
+	}
+
+	var buf bytes.Buffer
+	if err := u.Archive.WriteCSV(&buf); err != nil {
+		t.Fatalf("Failed to write CSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "f1,f2,x1,x2,x3,x4,x5,x6,x7,x8,x9,x10" || len(lines) != len(entries)+1 {
+		t.Errorf("unexpected CSV header or length: %q, %d lines", lines[0], len(lines))
+	}
+}
@@ -0,0 +1,70 @@
+package pso
+
+import (
+	"math"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// Optimum is one of several distinct optima found by a NichingUpdater.
+type Optimum struct {
+	Pos     vec.Vec
+	Val     float64
+	Radius  float64 // estimated basin radius
+	Members int     // number of particles in the species around this optimum
+}
+
+// NichingUpdater is a StandardUpdater whose topology is a set of species
+// (see topology.Species), so that subswarms settle on several distinct optima
+// at once. Everything else, including configuration, comes from the standard
+// updater.
+type NichingUpdater struct {
+	*StandardUpdater
+
+	Species *topology.Species
+}
+
+// NewNiching creates a niching updater with a swarm of the given size. Species
+// seeds are at least radius apart, so radius should be somewhat less than the
+// expected distance between optima.
+func NewNiching(f fitness.Function, size int, radius float64, c *Config) *NichingUpdater {
+	u := &NichingUpdater{}
+	u.Species = topology.NewSpecies(size, radius, func(i int) vec.Vec {
+		return u.swarm[i].BestPos
+	})
+	u.StandardUpdater = NewStandardPSO(u.Species, f, c)
+	return u
+}
+
+// Optima returns the current species seeds, from most to least fit, as
+// distinct optima. The basin radius of each is estimated as half of the
+// distance to the nearest other optimum, but no more than the species radius.
+func (u *NichingUpdater) Optima() []Optimum {
+	seeds, species := u.Species.Seeds(u.topoLessFit)
+	optima := make([]Optimum, len(seeds))
+	for i, s := range seeds {
+		p := u.swarm[s]
+		optima[i] = Optimum{
+			Pos:    p.BestPos.Copy(),
+			Val:    p.BestVal,
+			Radius: u.Species.Radius,
+		}
+		if len(seeds) > 1 {
+			nearest := math.Inf(1)
+			for _, o := range seeds {
+				if o != s {
+					nearest = math.Min(nearest, p.BestPos.Dist(u.swarm[o].BestPos))
+				}
+			}
+			optima[i].Radius = math.Min(nearest/2, u.Species.Radius)
+		}
+		for _, seed := range species {
+			if seed == s {
+				optima[i].Members++
+			}
+		}
+	}
+	return optima
+}
@@ -0,0 +1,51 @@
+package pso
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/rng"
+)
+
+func TestNichingFindsAllOptima(t *testing.T) {
+	problems := []struct {
+		name   string
+		f      *fitness.Multimodal
+		size   int
+		radius float64
+		evals  int
+		want   int // how many of f.NumOptima must be found
+	}{
+		{"equalmaxima", fitness.NewEqualMaxima(), 30, 0.05, 10000, 5},
+		{"himmelblau", fitness.NewHimmelblau(), 50, 1.0, 20000, 4},
+		{"sixhumpcamel", fitness.NewSixHumpCamel(), 50, 0.5, 20000, 2},
+		{"shubert", fitness.NewShubert(), 200, 0.3, 100000, 14},
+	}
+	for _, prob := range problems {
+		conf := NewBasicConfig(rng.Streams(6))
+		conf.RadiusMultiplier = 0
+		conf.Momentum0 = 0.7298
+		conf.SocConst, conf.CogConst = 1.49618, 1.49618
+		conf.Boundary = ReflectBoundary
+		u := NewNiching(prob.f, prob.size, prob.radius, conf)
+		Run(context.Background(), u, MaxEvals(prob.evals))
+
+		found := 0
+		optima := u.Optima()
+		for i, o := range optima {
+			if o.Val-prob.f.OptimumVal < 1e-4 {
+				found++
+			}
+			if i > 0 && u.Fitness.LessFit(optima[i-1].Val, o.Val) {
+				t.Errorf("%s: optima out of order: %v before %v", prob.name, optima[i-1].Val, o.Val)
+			}
+			if o.Radius < prob.radius/2 || o.Radius > prob.radius {
+				t.Errorf("%s: optimum %v has basin radius %v, outside of [%v, %v]", prob.name, o.Pos, o.Radius, prob.radius/2, prob.radius)
+			}
+		}
+		if found < prob.want {
+			t.Errorf("%s: expected to find at least %d of %d optima, found %d: %v", prob.name, prob.want, prob.f.NumOptima, found, optima)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package pso
+
+import (
+	"sync"
+
+	"github.com/shiblon/entrogo/pso/particle"
+)
+
+// Observer receives events from an updater as it runs, so that tools can log
+// or plot progress without changing the updater. Calls are serialized, and
+// particles must not be changed or kept beyond the call (use Clone). An
+// observer can stop a run by canceling the context given to Run.
+//
+// Embed NopObserver to implement only some of the methods.
+type Observer interface {
+	// BatchStart is called at the start of each Update, with the number of
+	// batches before it.
+	BatchStart(batch int)
+
+	// Evaluated is called after the particle has been evaluated at a new
+	// current position.
+	Evaluated(p *particle.Particle)
+
+	// PersonalBest is called after the particle's personal best has improved.
+	PersonalBest(p *particle.Particle)
+
+	// GlobalBest is called after a personal best becomes the best seen in the
+	// swarm so far.
+	GlobalBest(p *particle.Particle)
+
+	// Bounced is called when the particle's new position is pushed away from
+	// another particle, before it is evaluated.
+	Bounced(p *particle.Particle)
+
+	// BatchEnd is called at the end of each Update, with the number of
+	// evaluations it performed.
+	BatchEnd(batch, evals int)
+
+	// Terminated is called by Run when the run stops.
+	Terminated(res *Result)
+}
+
+// NopObserver ignores all events.
+type NopObserver struct{}
+
+func (NopObserver) BatchStart(batch int)              {}
+func (NopObserver) Evaluated(p *particle.Particle)    {}
+func (NopObserver) PersonalBest(p *particle.Particle) {}
+func (NopObserver) GlobalBest(p *particle.Particle)   {}
+func (NopObserver) Bounced(p *particle.Particle)      {}
+func (NopObserver) BatchEnd(batch, evals int)         {}
+func (NopObserver) Terminated(res *Result)            {}
+
+// Observers sends every event to each of its observers in turn.
+type Observers []Observer
+
+func (obs Observers) BatchStart(batch int) {
+	for _, o := range obs {
+		o.BatchStart(batch)
+	}
+}
+
+func (obs Observers) Evaluated(p *particle.Particle) {
+	for _, o := range obs {
+		o.Evaluated(p)
+	}
+}
+
+func (obs Observers) PersonalBest(p *particle.Particle) {
+	for _, o := range obs {
+		o.PersonalBest(p)
+	}
+}
+
+func (obs Observers) GlobalBest(p *particle.Particle) {
+	for _, o := range obs {
+		o.GlobalBest(p)
+	}
+}
+
+func (obs Observers) Bounced(p *particle.Particle) {
+	for _, o := range obs {
+		o.Bounced(p)
+	}
+}
+
+func (obs Observers) BatchEnd(batch, evals int) {
+	for _, o := range obs {
+		o.BatchEnd(batch, evals)
+	}
+}
+
+func (obs Observers) Terminated(res *Result) {
+	for _, o := range obs {
+		o.Terminated(res)
+	}
+}
+
+// observable is an updater that notifies an observer.
+type observable interface {
+	notify(event func(o Observer))
+}
+
+// withObserver is an updater outside of this package, such as a
+// restart.Restarter, that reports to an observer of its own.
+type withObserver interface {
+	Observer() Observer
+}
+
+// observation is the observer of an updater, and what it has been told.
+type observation struct {
+	sync.Mutex
+	observer      Observer
+	batches       int
+	seenBest      bool
+	bestVal       float64
+	bestViolation float64
+}
+
+// Observe sets the observer of the updater's events, replacing any previous
+// one. A nil observer turns events off.
+func (b *swarmCore) Observe(o Observer) {
+	b.obs.Lock()
+	defer b.obs.Unlock()
+	b.obs.observer = o
+}
+
+// Observer returns the current observer, or nil.
+func (b *swarmCore) Observer() Observer {
+	b.obs.Lock()
+	defer b.obs.Unlock()
+	return b.obs.observer
+}
+
+// notify calls event with the observer, if there is one, holding the lock so
+// that calls are serialized.
+func (b *swarmCore) notify(event func(o Observer)) {
+	b.obs.Lock()
+	defer b.obs.Unlock()
+	if b.obs.observer != nil {
+		event(b.obs.observer)
+	}
+}
+
+// beginBatch tells the observer that an Update has started.
+func (b *swarmCore) beginBatch() {
+	b.notify(func(o Observer) { o.BatchStart(b.obs.batches) })
+}
+
+// endBatch tells the observer that an Update has finished.
+func (b *swarmCore) endBatch(evals int) {
+	b.obs.Lock()
+	defer b.obs.Unlock()
+	if b.obs.observer != nil {
+		b.obs.observer.BatchEnd(b.obs.batches, evals)
+	}
+	b.obs.batches++
+}
+
+// observeEval tells the observer that the particle was evaluated, and whether
+// that improved its personal best and the global best.
+func (b *swarmBase) observeEval(p *particle.Particle, improved bool) {
+	b.reportEval(p, improved, b.LessFit)
+}
+
+// reportEval tells the observer that the particle was evaluated, and whether
+// that improved its personal best. Global bests are judged with lessFit.
+func (b *swarmCore) reportEval(p *particle.Particle, improved bool, lessFit func(a, aViol, c, cViol float64) bool) {
+	b.notify(func(o Observer) {
+		o.Evaluated(p)
+		if !improved {
+			return
+		}
+		o.PersonalBest(p)
+		if !b.obs.seenBest || lessFit(b.obs.bestVal, b.obs.bestViolation, p.BestVal, p.BestViolation) {
+			b.obs.seenBest = true
+			b.obs.bestVal, b.obs.bestViolation = p.BestVal, p.BestViolation
+			o.GlobalBest(p)
+		}
+	})
+}
+
+// observeBounce tells the observer that the particle bounced.
+func (b *swarmCore) observeBounce(p *particle.Particle) {
+	b.notify(func(o Observer) { o.Bounced(p) })
+}
+
+// forgetBest makes the next personal best improvement a global best as well,
+// since the best value seen so far means nothing once the function changes.
+func (b *swarmCore) forgetBest() {
+	b.obs.Lock()
+	defer b.obs.Unlock()
+	b.obs.seenBest = false
+}
@@ -0,0 +1,176 @@
+package pso
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+)
+
+// countingObserver counts events, and checks that batches nest properly.
+type countingObserver struct {
+	t *testing.T
+	f fitness.Function
+
+	inBatch    bool
+	batches    int
+	evals      int
+	evaluated  int
+	personal   int
+	bounced    int
+	globalVals []float64
+	result     *Result
+}
+
+func (o *countingObserver) BatchStart(batch int) {
+	if o.inBatch || batch != o.batches {
+		o.t.Errorf("batch %d started after %d batches (in batch: %v)", batch, o.batches, o.inBatch)
+	}
+	o.inBatch = true
+}
+
+func (o *countingObserver) BatchEnd(batch, evals int) {
+	if !o.inBatch || batch != o.batches {
+		o.t.Errorf("batch %d ended after %d batches (in batch: %v)", batch, o.batches, o.inBatch)
+	}
+	o.inBatch = false
+	o.batches++
+	o.evals += evals
+}
+
+func (o *countingObserver) Evaluated(p *particle.Particle) { o.evaluated++ }
+func (o *countingObserver) PersonalBest(p *particle.Particle) {
+	o.personal++
+	if p.BestVal != p.Val {
+		o.t.Errorf("personal best %v is not the current value %v", p.BestVal, p.Val)
+	}
+}
+func (o *countingObserver) Bounced(p *particle.Particle) { o.bounced++ }
+
+func (o *countingObserver) GlobalBest(p *particle.Particle) {
+	if n := len(o.globalVals); n > 0 && !o.f.LessFit(o.globalVals[n-1], p.BestVal) {
+		o.t.Errorf("global best went from %v to %v", o.globalVals[n-1], p.BestVal)
+	}
+	o.globalVals = append(o.globalVals, p.BestVal)
+}
+
+func (o *countingObserver) Terminated(res *Result) {
+	if o.result != nil {
+		o.t.Errorf("terminated twice")
+	}
+	o.result = res
+}
+
+func TestObserverEvents(t *testing.T) {
+	f := fitness.NewRastrigin(5, 0.25)
+	conf := NewBasicConfig(rng.Streams(3))
+	conf.Boundary = InfinityBoundary
+	u := NewStandardPSO(topology.NewRing(15), f, conf)
+	obs := &countingObserver{t: t, f: f}
+	u.Observe(obs)
+	res := Run(context.Background(), u, MaxEvals(3000))
+
+	if obs.result != res {
+		t.Errorf("expected the result of the run on termination")
+	}
+	if _, total := u.Batches(); obs.batches != total || obs.inBatch {
+		t.Errorf("expected %d finished batches, got %d", total, obs.batches)
+	}
+	if obs.evals != u.Evals() || obs.evaluated != u.Evals() {
+		t.Errorf("expected %d evaluations, got %d in batches and %d events", u.Evals(), obs.evals, obs.evaluated)
+	}
+	if obs.personal < len(u.Swarm()) || obs.personal > obs.evaluated {
+		t.Errorf("expected between %d and %d personal bests, got %d", len(u.Swarm()), obs.evaluated, obs.personal)
+	}
+	bounces := 0
+	for _, p := range u.Swarm() {
+		bounces += int(p.Bounces)
+	}
+	if bounces == 0 || obs.bounced != bounces {
+		t.Errorf("expected %d bounces, got %d", bounces, obs.bounced)
+	}
+	if n := len(obs.globalVals); n == 0 || obs.globalVals[n-1] != res.BestVal {
+		t.Errorf("expected the last global best to be %v, got %v", res.BestVal, obs.globalVals)
+	}
+}
+
+func TestObserverResume(t *testing.T) {
+	f := fitness.NewRastrigin(5, 0.25)
+	newUpdater := func() *StandardUpdater {
+		return NewStandardPSO(topology.NewRing(15), f, NewBasicConfig(rng.Streams(5)))
+	}
+	first := newUpdater()
+	before := &countingObserver{t: t, f: f}
+	first.Observe(before)
+	for i := 0; i < 20; i++ {
+		first.Update()
+	}
+	var buf bytes.Buffer
+	if err := first.WriteCheckpoint(&buf); err != nil {
+		t.Fatalf("Failed to write checkpoint: %v", err)
+	}
+
+	// Batch numbers carry on, and only real improvements are global bests.
+	resumed := newUpdater()
+	if err := resumed.ReadCheckpoint(&buf); err != nil {
+		t.Fatalf("Failed to read checkpoint: %v", err)
+	}
+	after := &countingObserver{t: t, f: f, batches: before.batches, globalVals: before.globalVals}
+	resumed.Observe(after)
+	for i := 0; i < 20; i++ {
+		resumed.Update()
+	}
+	if _, total := resumed.Batches(); after.batches != total {
+		t.Errorf("expected %d batches after resuming, got %d", total, after.batches)
+	}
+}
+
+func TestObserverAsync(t *testing.T) {
+	f := fitness.NewParabola(3, 0.25)
+	u := NewAsync(topology.NewRing(10), f, NewBasicConfig(rng.Streams(4)), 3)
+	obs := &countingObserver{t: t, f: f}
+	u.Observe(obs)
+	res := Run(context.Background(), u, MaxEvals(2000))
+
+	if obs.result != res {
+		t.Errorf("expected the result of the run on termination")
+	}
+	if obs.evals != res.Evals || obs.evaluated != res.Evals {
+		t.Errorf("expected %d evaluations, got %d in batches and %d events", res.Evals, obs.evals, obs.evaluated)
+	}
+	u.Close()
+	if obs.evaluated != u.Evals() {
+		t.Errorf("expected %d evaluation events after closing, got %d", u.Evals(), obs.evaluated)
+	}
+	if n := len(obs.globalVals); n == 0 || obs.globalVals[n-1] != u.BestParticle().BestVal {
+		t.Errorf("expected the last global best to be %v, got %v", u.BestParticle().BestVal, obs.globalVals)
+	}
+}
+
+func TestObserverMOPSO(t *testing.T) {
+	u := NewMOPSO(fitness.NewZDT1(5), 20, 50, NewBasicConfig(rng.Streams(6)))
+	// Global bests are judged by the first objective, which is minimized.
+	obs := &countingObserver{t: t, f: fitness.NewParabola(1, 0)}
+	u.Observe(obs)
+	res := Run(context.Background(), u, MaxEvals(2000))
+
+	if obs.result != res {
+		t.Errorf("expected the result of the run on termination")
+	}
+	if _, total := u.Batches(); obs.batches != total || obs.inBatch {
+		t.Errorf("expected %d finished batches, got %d", total, obs.batches)
+	}
+	if obs.evals != u.Evals() || obs.evaluated != u.Evals() {
+		t.Errorf("expected %d evaluations, got %d in batches and %d events", u.Evals(), obs.evals, obs.evaluated)
+	}
+	if obs.personal < len(u.Swarm()) || obs.personal > obs.evaluated {
+		t.Errorf("expected between %d and %d personal bests, got %d", len(u.Swarm()), obs.evaluated, obs.personal)
+	}
+	if n := len(obs.globalVals); n == 0 || obs.globalVals[n-1] != res.BestVal {
+		t.Errorf("expected the last global best to be %v, got %v", res.BestVal, obs.globalVals)
+	}
+}
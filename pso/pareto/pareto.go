@@ -0,0 +1,174 @@
+// Package pareto contains tools for multi-objective optimization: dominance
+// checks and a bounded archive of non-dominated solutions. All objectives are
+// minimized.
+package pareto
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+// Dominates returns true if a is at least as good as b in every objective, and
+// strictly better in at least one.
+func Dominates(a, b vec.Vec) bool {
+	better := false
+	for i, v := range a {
+		if v > b[i] {
+			return false
+		}
+		if v < b[i] {
+			better = true
+		}
+	}
+	return better
+}
+
+// Entry is a single solution in the archive.
+type Entry struct {
+	Pos  vec.Vec
+	Vals vec.Vec
+}
+
+// Archive holds mutually non-dominated solutions. When it grows past its
+// capacity, it is pruned by repeatedly removing the most crowded entry, which
+// keeps the front spread out. It is not safe for concurrent modification.
+type Archive struct {
+	capacity int
+	entries  []Entry
+	crowding []float64 // crowding distance of each entry, computed lazily
+}
+
+// NewArchive creates an archive that holds at most capacity entries. The
+// capacity must be at least 1, since an empty front has no leaders.
+func NewArchive(capacity int) *Archive {
+	if capacity < 1 {
+		panic(fmt.Sprintf("archive capacity must be at least 1, got %d", capacity))
+	}
+	return &Archive{capacity: capacity}
+}
+
+// Len returns the number of entries.
+func (a *Archive) Len() int {
+	return len(a.entries)
+}
+
+// Entries returns the current entries. The slice must not be modified.
+func (a *Archive) Entries() []Entry {
+	return a.entries
+}
+
+// Add offers a solution to the archive. It is rejected if any entry dominates
+// it or has identical objective values; otherwise it is added and every entry
+// that it dominates is removed. Returns whether the solution was added (it
+// could still be pruned right away if the archive is over capacity).
+func (a *Archive) Add(pos, vals vec.Vec) bool {
+	kept := a.entries[:0]
+	for _, e := range a.entries {
+		if Dominates(e.Vals, vals) || e.Vals.Sub(vals).Mag() == 0 {
+			return false
+		}
+	}
+	for _, e := range a.entries {
+		if !Dominates(vals, e.Vals) {
+			kept = append(kept, e)
+		}
+	}
+	a.entries = append(kept, Entry{Pos: pos.Copy(), Vals: vals.Copy()})
+	a.crowding = nil
+	for len(a.entries) > a.capacity {
+		a.removeMostCrowded()
+	}
+	return true
+}
+
+// Crowding returns the crowding distance of every entry (in the same order as
+// Entries): the sum over objectives of the normalized gap between each
+// entry's neighbors on the front. Extreme entries get +Inf.
+func (a *Archive) Crowding() []float64 {
+	if a.crowding != nil {
+		return a.crowding
+	}
+	n := len(a.entries)
+	a.crowding = make([]float64, n)
+	if n == 0 {
+		return a.crowding
+	}
+	order := make([]int, n)
+	for obj := range a.entries[0].Vals {
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return a.entries[order[i]].Vals[obj] < a.entries[order[j]].Vals[obj]
+		})
+		lo := a.entries[order[0]].Vals[obj]
+		hi := a.entries[order[n-1]].Vals[obj]
+		a.crowding[order[0]] = math.Inf(1)
+		a.crowding[order[n-1]] = math.Inf(1)
+		if hi == lo {
+			continue
+		}
+		for k := 1; k < n-1; k++ {
+			gap := a.entries[order[k+1]].Vals[obj] - a.entries[order[k-1]].Vals[obj]
+			a.crowding[order[k]] += gap / (hi - lo)
+		}
+	}
+	return a.crowding
+}
+
+// removeMostCrowded drops the entry with the smallest crowding distance.
+func (a *Archive) removeMostCrowded() {
+	crowding := a.Crowding()
+	worst := 0
+	for i, c := range crowding {
+		if c < crowding[worst] {
+			worst = i
+		}
+	}
+	a.entries = append(a.entries[:worst], a.entries[worst+1:]...)
+	a.crowding = nil
+}
+
+// WriteCSV writes the archive as CSV, one entry per row: objective values
+// (f1, f2, ...) followed by position coordinates (x1, x2, ...), sorted by the
+// first objective.
+func (a *Archive) WriteCSV(w io.Writer) error {
+	entries := append([]Entry(nil), a.entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Vals[0] < entries[j].Vals[0]
+	})
+
+	cw := csv.NewWriter(w)
+	if len(entries) > 0 {
+		var header []string
+		for i := range entries[0].Vals {
+			header = append(header, fmt.Sprintf("f%d", i+1))
+		}
+		for i := range entries[0].Pos {
+			header = append(header, fmt.Sprintf("x%d", i+1))
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+	for _, e := range entries {
+		var row []string
+		for _, v := range e.Vals {
+			row = append(row, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		for _, v := range e.Pos {
+			row = append(row, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
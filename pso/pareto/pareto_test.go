@@ -0,0 +1,82 @@
+package pareto
+
+import (
+	"math"
+	"testing"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+func TestDominates(t *testing.T) {
+	tests := []struct {
+		a, b vec.Vec
+		want bool
+	}{
+		{vec.Vec{1, 2}, vec.Vec{2, 3}, true},
+		{vec.Vec{1, 3}, vec.Vec{2, 3}, true},
+		{vec.Vec{2, 3}, vec.Vec{2, 3}, false},
+		{vec.Vec{1, 4}, vec.Vec{2, 3}, false},
+		{vec.Vec{2, 3}, vec.Vec{1, 2}, false},
+	}
+	for _, test := range tests {
+		if got := Dominates(test.a, test.b); got != test.want {
+			t.Errorf("Dominates(%v, %v): expected %v, got %v", test.a, test.b, test.want, got)
+		}
+	}
+}
+
+func TestArchive(t *testing.T) {
+	a := NewArchive(4)
+	pos := vec.Vec{0}
+	if !a.Add(pos, vec.Vec{5, 5}) {
+		t.Fatal("expected first entry to be added")
+	}
+	if a.Add(pos, vec.Vec{6, 6}) || a.Add(pos, vec.Vec{5, 5}) {
+		t.Error("expected dominated and duplicate entries to be rejected")
+	}
+	if !a.Add(pos, vec.Vec{4, 4}) || a.Len() != 1 {
+		t.Errorf("expected dominating entry to replace the old one, have %d entries", a.Len())
+	}
+	for _, v := range []vec.Vec{{0, 4}, {1, 3}, {1.1, 2.9}, {3, 1}, {4, 0}} {
+		a.Add(pos, v)
+	}
+	if a.Len() != 4 {
+		t.Fatalf("expected archive to be pruned to 4 entries, have %d", a.Len())
+	}
+	// The most crowded entries are (1, 3) and (1.1, 2.9); one of them goes.
+	// Extremes are always kept.
+	crowding := a.Crowding()
+	extremes := 0
+	for i, e := range a.Entries() {
+		if math.IsInf(crowding[i], 1) {
+			extremes++
+		}
+		if e.Vals[0] == 1 && e.Vals[1] == 3 {
+			for _, o := range a.Entries() {
+				if o.Vals[0] == 1.1 {
+					t.Errorf("expected one of the two crowded entries to be pruned: %v", a.Entries())
+				}
+			}
+		}
+	}
+	if extremes != 2 {
+		t.Errorf("expected 2 extreme entries, got %d", extremes)
+	}
+}
+
+func TestArchiveCapacity(t *testing.T) {
+	a := NewArchive(1)
+	for _, v := range []vec.Vec{{0, 4}, {2, 2}, {4, 0}} {
+		a.Add(vec.Vec{0}, v)
+	}
+	if a.Len() != 1 {
+		t.Errorf("expected a single entry to be kept, have %d", a.Len())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected an empty archive to be refused")
+		}
+	}()
+	NewArchive(0)
+}
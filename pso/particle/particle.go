@@ -2,6 +2,7 @@
 package particle
 
 import (
+	"encoding"
 	"fmt"
 	"math/rand"
 
@@ -11,38 +12,44 @@ import (
 
 // TempParticleState contains the things that change between evaluations. Used for batching.
 type TempParticleState struct {
-	Pos, Vel vec.Vec
-	Val      float64
-	Bounced  bool
+	Pos, Vel  vec.Vec
+	Val       float64
+	Violation float64
+	Bounced   bool
+	WallHit   bool
 }
 
 // Particle is a single PSO particle, containing all current and history (and scratch) state.
 type Particle struct {
 	Id int
 	// Current state
-	Pos, Vel vec.Vec
-	Val      float64
-	T        int // time
+	Pos, Vel  vec.Vec
+	Val       float64
+	Violation float64 // total constraint violation, zero if feasible
+	T         int     // time
 
 	// Current best state
-	BestPos vec.Vec
-	BestVal float64
+	BestPos       vec.Vec
+	BestVal       float64
+	BestViolation float64
 
 	// Additional state
-	BestT   int // time
-	Bounces int32
+	BestT    int // time
+	Bounces  int32
+	WallHits int32 // number of updates that ran into the domain boundary
 
 	scratch *TempParticleState
 
 	// Random number generator. Each particle gets its own.
+	rsrc rand.Source
 	rgen *rand.Rand
 
-	f fitness.Function
+	f fitness.Domain
 }
 
 // NewRandomParticle gets its values by sampling from the fitness domain. It
 // also evaluates the function if "evaluate" is true.
-func NewRandomParticle(rsrc rand.Source, idx int, f fitness.Function) (par *Particle) {
+func NewRandomParticle(rsrc rand.Source, idx int, f fitness.Domain) (par *Particle) {
 	r := rand.New(rsrc)
 	pos := f.RandomPos(r)
 	vel := f.RandomVel(r)
@@ -55,6 +62,7 @@ func NewRandomParticle(rsrc rand.Source, idx int, f fitness.Function) (par *Part
 			Pos: pos.Copy(),
 			Vel: vel.Copy(),
 		},
+		rsrc: rsrc,
 		rgen: r,
 		f:    f,
 	}
@@ -66,6 +74,14 @@ func (p *Particle) ResetVal(val float64) {
 	p.BestVal = val
 }
 
+// ResetViolation sets the current, best and scratch constraint violation,
+// like ResetVal does for values.
+func (p *Particle) ResetViolation(violation float64) {
+	p.scratch.Violation = violation
+	p.Violation = violation
+	p.BestViolation = violation
+}
+
 func (p *Particle) Init(pos, vel vec.Vec, val float64) {
 	if len(pos) != len(vel) {
 		panic(fmt.Sprintf("Position and velocity vecs have different lengths: %d != %d", len(pos), len(vel)))
@@ -73,14 +89,18 @@ func (p *Particle) Init(pos, vel vec.Vec, val float64) {
 	p.Pos = pos.Copy()
 	p.Vel = vel.Copy()
 	p.Val = val
+	p.Violation = 0
 	p.T = 0
 	p.BestPos = pos.Copy()
 	p.BestVal = val
+	p.BestViolation = 0
 	p.BestT = 0
 	p.Bounces = 0
+	p.WallHits = 0
 	p.scratch.Pos = pos.Copy()
 	p.scratch.Vel = vel.Copy()
 	p.scratch.Val = val
+	p.scratch.Violation = 0
 }
 
 func (p *Particle) Rand() *rand.Rand {
@@ -91,6 +111,125 @@ func (p *Particle) Scratch() *TempParticleState {
 	return p.scratch
 }
 
+// Clone returns a deep copy of the particle's current and best state, for
+// looking at while the original keeps changing. The original's scratch state
+// is not read, since it may be in the middle of an update: the copy's scratch
+// state matches its current state. The copy has no random source of its own,
+// so it cannot be moved.
+func (p *Particle) Clone() *Particle {
+	c := *p
+	c.Pos = p.Pos.Copy()
+	c.Vel = p.Vel.Copy()
+	c.BestPos = p.BestPos.Copy()
+	c.scratch = &TempParticleState{
+		Pos:       p.Pos.Copy(),
+		Vel:       p.Vel.Copy(),
+		Val:       p.Val,
+		Violation: p.Violation,
+	}
+	c.rsrc = nil
+	c.rgen = nil
+	return &c
+}
+
+// Snapshot holds the complete state of a particle, including its scratch
+// state and the state of its random source, in a form that can be encoded.
+type Snapshot struct {
+	Id            int
+	Pos, Vel      vec.Vec
+	Val           float64
+	Violation     float64
+	T             int
+	BestPos       vec.Vec
+	BestVal       float64
+	BestViolation float64
+	BestT         int
+	Bounces       int32
+	WallHits      int32
+	Scratch       TempParticleState
+	RNG           []byte
+}
+
+// Snapshot captures the particle state. It fails if the particle's random
+// source cannot be marshaled.
+func (p *Particle) Snapshot() (*Snapshot, error) {
+	m, ok := p.rsrc.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("particle %d: random source %T cannot be marshaled", p.Id, p.rsrc)
+	}
+	rs, err := m.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("particle %d: marshal random source: %v", p.Id, err)
+	}
+	return &Snapshot{
+		Id:            p.Id,
+		Pos:           p.Pos.Copy(),
+		Vel:           p.Vel.Copy(),
+		Val:           p.Val,
+		Violation:     p.Violation,
+		T:             p.T,
+		BestPos:       p.BestPos.Copy(),
+		BestVal:       p.BestVal,
+		BestViolation: p.BestViolation,
+		BestT:         p.BestT,
+		Bounces:       p.Bounces,
+		WallHits:      p.WallHits,
+		Scratch: TempParticleState{
+			Pos:       p.scratch.Pos.Copy(),
+			Vel:       p.scratch.Vel.Copy(),
+			Val:       p.scratch.Val,
+			Violation: p.scratch.Violation,
+			Bounced:   p.scratch.Bounced,
+			WallHit:   p.scratch.WallHit,
+		},
+		RNG: rs,
+	}, nil
+}
+
+// NewParticleFromSnapshot recreates a particle from a snapshot. The random
+// source must be of the same kind as the one the snapshot was taken from; its
+// state is overwritten with the saved state.
+func NewParticleFromSnapshot(s *Snapshot, rsrc rand.Source, f fitness.Domain) (*Particle, error) {
+	u, ok := rsrc.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return nil, fmt.Errorf("particle %d: random source %T cannot be unmarshaled", s.Id, rsrc)
+	}
+	if err := u.UnmarshalBinary(s.RNG); err != nil {
+		return nil, fmt.Errorf("particle %d: unmarshal random source: %v", s.Id, err)
+	}
+	dims := f.Dims()
+	for _, v := range []vec.Vec{s.Pos, s.Vel, s.BestPos, s.Scratch.Pos, s.Scratch.Vel} {
+		if len(v) != dims {
+			return nil, fmt.Errorf("particle %d: snapshot has %d dimensions, function has %d", s.Id, len(v), dims)
+		}
+	}
+	return &Particle{
+		Id:            s.Id,
+		Pos:           s.Pos.Copy(),
+		Vel:           s.Vel.Copy(),
+		Val:           s.Val,
+		Violation:     s.Violation,
+		T:             s.T,
+		BestPos:       s.BestPos.Copy(),
+		BestVal:       s.BestVal,
+		BestViolation: s.BestViolation,
+		BestT:         s.BestT,
+		Bounces:       s.Bounces,
+		WallHits:      s.WallHits,
+		scratch: &TempParticleState{
+			Pos:       s.Scratch.Pos.Copy(),
+			Vel:       s.Scratch.Vel.Copy(),
+			Val:       s.Scratch.Val,
+			Violation: s.Scratch.Violation,
+			Bounced:   s.Scratch.Bounced,
+			WallHit:   s.Scratch.WallHit,
+		},
+		rsrc: rsrc,
+		rgen: rand.New(rsrc),
+		f:    f,
+	}, nil
+}
+
 // Update the current state with the scratch state. This is useful if we are
 // doing batch updates and need to compute other particle values based on a
 // consistent time slice.
@@ -98,9 +237,13 @@ func (par *Particle) UpdateCur() {
 	par.Pos.Replace(par.scratch.Pos)
 	par.Vel.Replace(par.scratch.Vel)
 	par.Val = par.scratch.Val
+	par.Violation = par.scratch.Violation
 	if par.scratch.Bounced {
 		par.Bounces++
 	}
+	if par.scratch.WallHit {
+		par.WallHits++
+	}
 	par.T++
 }
 
@@ -109,6 +252,7 @@ func (par *Particle) UpdateCur() {
 func (par *Particle) UpdateBest() {
 	par.BestPos.Replace(par.Pos)
 	par.BestVal = par.Val
+	par.BestViolation = par.Violation
 	par.BestT = par.T
 }
 
@@ -121,7 +265,11 @@ func (par *Particle) String() string {
 	pos := par.f.VecInterpreter(par.Pos)
 	vel := par.f.VecInterpreter(par.Vel)
 	bpos := par.f.VecInterpreter(par.BestPos)
-	return fmt.Sprintf(
+	s := fmt.Sprintf(
 		"Particle T=%d (%d):\n  f=%f x=%s\n  x'=%s\n  bf=%f bx=%s\n  bounces=%d",
 		par.T, par.BestT, par.Val, pos, vel, par.BestVal, bpos, par.Bounces)
+	if par.Violation != 0 || par.BestViolation != 0 {
+		s += fmt.Sprintf(" violation=%g bviolation=%g", par.Violation, par.BestViolation)
+	}
+	return s
 }
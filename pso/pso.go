@@ -3,7 +3,6 @@
 package pso
 
 import (
-	"fmt"
 	"math"
 	"math/rand"
 	"sort"
@@ -20,32 +19,43 @@ type MomentumFunc func(u Updater, iter int, particle int) float64
 // This generates a multiplier for the momentum based on 'tug', which is the
 // dot product of the previous velocity vector with the acceleration. This
 // tells us if we are trying to move away from the evidence, for example, if
-// tug in [-1, 0).
-type TugFunc func(dot float64) float64
+// tug in [-1, 0). It is called during the particle's own update, so it may
+// draw from the particle's Rand(). See the tug package for implementations.
+type TugFunc func(p *particle.Particle, dot float64) float64
 
 // Config holds all of the basic configuration for a full particle swarm run.
 type Config struct {
-	NewRNG           func() rand.Source // creates a new random source
-	DecayAdapt       float64            // multiplier applied to soc/cog constants during non-improvement
-	DecayRadius      float64            // multiplier applied to radius after each bounce
-	Momentum0        float64            // momentum starting point (also used for constant momentum)
-	Momentum1        float64            // momentum "endpoint" (e.g., for linear momentum)
-	Momentum         MomentumFunc       // produce the current momentum
-	Tug              TugFunc            // produce a momentum multiplier based on degree of "tug" toward information.
-	SocConst         float64            // initial social constant.
-	CogConst         float64            // initial cognitive constant.
-	SocLower         float64            // lower bound for social constant.
-	CogLower         float64            // lower bound for cognitive constant.
-	BackwardAdapt    bool               // allow adaptation of negative lower bounds based on whole-swarm surprises.
-	VelCapMultiplier float64            // maximum velocity to allow as a function of the function's domain diagonal.
-	RadiusMultiplier float64            // how much to decay the radius when bouncing.
-	BounceMultiplier float64            // how much further to bounce out than usual.
+	NewRNG           func(id int) rand.Source // creates the random source for the particle with the given id
+	DecayAdapt       float64                  // multiplier applied to soc/cog constants during non-improvement
+	DecayRadius      float64                  // multiplier applied to radius after each bounce
+	Momentum0        float64                  // momentum starting point (also used for constant momentum)
+	Momentum1        float64                  // momentum "endpoint" (e.g., for linear momentum)
+	Momentum         MomentumFunc             // produce the current momentum
+	Tug              TugFunc                  // produce a momentum multiplier based on degree of "tug" toward information.
+	SocConst         float64                  // initial social constant.
+	CogConst         float64                  // initial cognitive constant.
+	SocLower         float64                  // lower bound for social constant.
+	CogLower         float64                  // lower bound for cognitive constant.
+	BackwardAdapt    bool                     // allow adaptation of negative lower bounds based on whole-swarm surprises.
+	VelCapMultiplier float64                  // maximum velocity to allow as a function of the function's domain diagonal.
+	RadiusMultiplier float64                  // how much to decay the radius when bouncing.
+	BounceMultiplier float64                  // how much further to bounce out than usual.
+	Collisions       CollisionPolicy          // responds to particles that come too close together (nil means ReflectCollisions).
+	Boundary         BoundaryPolicy           // keeps particles inside the domain (nil lets them roam).
+	Constraints      ConstraintHandler        // compares values of constrained functions (nil means FeasibilityRules).
+	Discrete         []Discretization         // per-dimension rounding of positions (nil or missing entries are continuous).
+	Sentinels        int                      // particles whose bests are re-evaluated each batch to detect change (0 for none).
+	ChangeResponse   ChangeResponse           // what to do when a change is detected (nil means ReevaluateBests).
+	QuantumFraction  float64                  // fraction of particles that are quantum (sampled around their informer's best).
+	QuantumRadius    float64                  // radius of the quantum cloud, as a fraction of the domain diameter.
+	Concurrency      int                      // maximum concurrent fitness evaluations (0 for no limit, 1 if Query is not thread safe).
+	Strategy         Strategy                 // adapts momentum and soc/cog constants between batches (nil keeps them fixed).
 }
 
 // NewBasicConfig creates a basic PSO configuration with fairly useful
 // parameters (decaying soc/cog adaptation for non-improvement, bouncing with
 // radius decay and distance adjustment, and constant momentum).
-func NewBasicConfig(newRNG func() rand.Source) *Config {
+func NewBasicConfig(newRNG func(id int) rand.Source) *Config {
 	c := &Config{
 		NewRNG:           newRNG,
 		DecayAdapt:       0.999,
@@ -59,13 +69,14 @@ func NewBasicConfig(newRNG func() rand.Source) *Config {
 		VelCapMultiplier: 0.5,
 		RadiusMultiplier: 0.1,
 		BounceMultiplier: 1.0,
+		QuantumRadius:    0.05,
 	}
 	// Default momentum is constant.
 	c.Momentum = func(u Updater, iter int, particle int) float64 {
 		return c.Momentum0
 	}
 	// Default tug function just leaves momentum alone (multiplier of 1.0).
-	c.Tug = func(dot float64) float64 {
+	c.Tug = func(p *particle.Particle, dot float64) float64 {
 		return 1.0
 	}
 	return c
@@ -92,20 +103,22 @@ type Updater interface {
 
 // StandardUpdater is a PSO updater that uses (essentially) standard update
 // equations (momentum-based), a fixed topology and number of particles, and a
-// single-objective static fitness function.
+// single-objective fitness function. If the function implements
+// fitness.ConstrainedFunction, best positions are chosen with Conf.Constraints.
+// If it implements fitness.DynamicFunction, it is advanced after every batch,
+// and Conf.Sentinels and Conf.ChangeResponse can be used to notice and react
+// when it changes. If it implements fitness.BatchFunction, each batch of
+// particles is evaluated in one call, and Conf.Concurrency does not apply.
+// If Conf.Strategy is set, it chooses the momentum and soc/cog constants
+// before each batch.
 type StandardUpdater struct {
+	swarmBase
+
 	Topology topology.Topology
-	Fitness  fitness.Function
 	Conf     *Config
 
-	swarm          []*particle.Particle
-	initialized    bool
-	domainDiameter float64
-	totalEvals     int
-	totalBatches   int
-	totalImproved  int
-
-	printChan chan string
+	changes int
+	coeffs  *Coefficients // from Conf.Strategy for the current batch
 }
 
 // NewStandardPSO creates an updater that performs the "standard" optimization.
@@ -117,143 +130,115 @@ type StandardUpdater struct {
 // Note that this begins life without a swarm. The swarm springs into existence
 // on the first call to Update.
 func NewStandardPSO(t topology.Topology, f fitness.Function, c *Config) *StandardUpdater {
-	updater := &StandardUpdater{
-		Topology:       t,
-		Fitness:        f,
-		Conf:           c,
-		domainDiameter: f.Diameter(),
-		printChan:      make(chan string),
-	}
-
-	go func() {
-		for {
-			fmt.Println(<-updater.printChan)
-		}
-	}()
-
-	return updater
-}
-
-// Initialized returns true if the swarm has reached t0 and the initial states
-// have been evaluated for fitness.
-func (u *StandardUpdater) Initialized() bool {
-	return u.initialized
-}
-
-// Swarm returns a list of particles.
-func (u *StandardUpdater) Swarm() []*particle.Particle {
-	return u.swarm
-}
-
-// BestParticle returns the particle with the fittest BestVal.
-func (u *StandardUpdater) BestParticle() *particle.Particle {
-	best := u.swarm[0]
-	for _, p := range u.swarm[1:] {
-		if u.Fitness.LessFit(best.BestVal, p.BestVal) {
-			best = p
-		}
+	return &StandardUpdater{
+		swarmBase: newSwarmBase(f),
+		Topology:  t,
+		Conf:      c,
 	}
-	return best
-}
-
-// Batches returns the number of improved batches and the total batches.
-func (u *StandardUpdater) Batches() (improved, total int) {
-	return u.totalImproved, u.totalBatches
 }
 
 // init creates all of the particles in the swarm and evaluates the fitness function
 // for all of them. Returns the number of function evaluations needed.
 func (u *StandardUpdater) init() int {
-	u.swarm = make([]*particle.Particle, u.Topology.Size())
-
-	// Evaluate the function concurrently.
-	particles := make(chan *particle.Particle)
-	for i := range u.swarm {
-		go func() {
-			par := particle.NewRandomParticle(u.Conf.NewRNG(), i, u.Fitness)
-			par.ResetVal(u.Fitness.Query(par.Pos))
-			particles <- par
-		}()
-	}
+	u.useConstraints(u.Conf.Constraints)
+	return u.initSwarm(u.Topology.Size(), func(i int) *particle.Particle {
+		return discretizeParticle(particle.NewRandomParticle(u.Conf.NewRNG(i), i, u.Fitness), u.Conf.Discrete)
+	})
+}
 
-	// Set up the particles.
-	for i := range u.swarm {
-		u.swarm[i] = <-particles
+// Reinitialize moves every particle except the best one to a random position
+// and forgets its memory, as at the start of a run, to get a stagnated swarm
+// moving again. Returns the number of function evaluations performed.
+func (u *StandardUpdater) Reinitialize() int {
+	best := u.BestParticle()
+	var moved []*particle.Particle
+	var pos, vel []vec.Vec
+	for _, p := range u.swarm {
+		if p == best {
+			continue
+		}
+		moved = append(moved, p)
+		pos = append(pos, u.Fitness.RandomPos(p.Rand()))
+		vel = append(vel, u.Fitness.RandomVel(p.Rand()))
+		discretize(pos[len(pos)-1], u.Conf.Discrete, p.Rand())
 	}
-
-	u.initialized = true
-	return len(u.swarm)
+	vals := u.queryAll(pos)
+	u.pool.run(len(moved), func(i int) {
+		moved[i].Init(pos[i], vel[i], vals[i])
+		moved[i].ResetViolation(u.violation(pos[i]))
+	})
+	for _, p := range u.swarm {
+		if p != best {
+			u.observeEval(p, true)
+		}
+	}
+	u.totalEvals += len(u.swarm) - 1
+	return len(u.swarm) - 1
 }
 
 // Update moves the swarm from one time slice to another. The first call moves
 // the swarm to t[0] by initializing it. After that it ticks the clock with each call.
 // Returns the number of function evaluations performed.
-func (u *StandardUpdater) Update() int {
+func (u *StandardUpdater) Update() (evals int) {
+	u.beginBatch()
+	defer func() { u.endBatch(evals) }()
+
 	bestUpdated := false
 	defer func() {
 		u.Topology.Tick()
-		u.totalBatches++
-		if bestUpdated {
-			u.totalImproved++
-		}
+		u.updateConstraints()
+		u.advanceFitness()
+		u.countBatch(bestUpdated)
 	}()
 
+	u.limitConcurrency(u.Conf.Concurrency)
 	if !u.Initialized() {
-		u.totalImproved++
 		bestUpdated = true
 		return u.init()
 	}
 
-	// First let all particles move based on their favorite neighbor.
-	done := make(chan bool, len(u.swarm))
-	for pidx := range u.swarm {
-		pidx := pidx
-		go func() {
-			u.moveOneParticle(pidx)
-			done <- true
-		}()
-	}
+	// Make sure the swarm's memory is still valid in a changing landscape.
+	changeEvals := u.detectChange()
 
-	// Wait for them to finish.
-	for _ = range u.swarm {
-		<-done
-	}
+	// Let the strategy, if any, choose the coefficients for this batch.
+	adaptEvals := u.adapt()
+
+	// First let all particles move based on their favorite neighbor.
+	u.moveAll(u.moveOneParticle)
 
 	// TODO: perhaps just return markers indicating what needs to happen next, then
 	// update all of the states after the fact.
 	u.bounceAll()
 
 	// Evaluate the function and update current and best states.
-	evaluated := make(chan int)
-	for i := range u.swarm {
-		go func(pidx int) {
-			p := u.swarm[pidx]
-			p.Scratch().Val = u.Fitness.Query(p.Scratch().Pos)
-			p.UpdateCur()
-			if u.Fitness.LessFit(p.BestVal, p.Val) {
-				bestUpdated = true
-				p.UpdateBest()
-			}
-			evaluated <- 1
-		}(i)
+	num_evaluations, improved := u.evaluateAll(u.confiner(u.Conf.Boundary, u.Conf.Discrete))
+	if improved {
+		bestUpdated = true
 	}
+	return num_evaluations + changeEvals + adaptEvals
+}
 
-	// Wait for it to finish.
-	num_evaluations := 0
-	for _ = range u.swarm {
-		num_evaluations += <-evaluated
+// adapt asks Conf.Strategy for the coefficients of the coming batch. Returns
+// the number of function evaluations it needed.
+func (u *StandardUpdater) adapt() int {
+	if u.Conf.Strategy == nil {
+		u.coeffs = nil
+		return 0
 	}
-
-	u.totalEvals += num_evaluations
-	return num_evaluations
+	c, evals := u.Conf.Strategy.Adapt(u)
+	u.coeffs = &c
+	return evals
 }
 
 func (u *StandardUpdater) momentum(particle *particle.Particle, dot float64) float64 {
-	return u.Conf.Momentum(u, u.totalEvals, particle.Id) * u.Conf.Tug(dot)
+	if u.coeffs != nil {
+		return u.coeffs.Momentum * u.Conf.Tug(particle, dot)
+	}
+	return u.Conf.Momentum(u, u.iterations(), particle.Id) * u.Conf.Tug(particle, dot)
 }
 
 func (u *StandardUpdater) topoLessFit(a, b int) bool {
-	return u.Fitness.LessFit(u.swarm[a].BestVal, u.swarm[b].BestVal)
+	return u.lessFitBest(u.swarm[a], u.swarm[b])
 }
 
 func (u *StandardUpdater) moveOneParticle(pidx int) {
@@ -263,10 +248,17 @@ func (u *StandardUpdater) moveOneParticle(pidx int) {
 	socLower := u.Conf.SocLower
 	cogLower := u.Conf.CogLower
 	maxvel_fraction := u.Conf.VelCapMultiplier
+	if u.coeffs != nil {
+		soc, cog = u.coeffs.SocConst, u.coeffs.CogConst
+	}
 
 	p := u.swarm[pidx]
 
 	informer := u.swarm[u.Topology.BestNeighbor(pidx, u.topoLessFit)]
+	if u.isQuantum(pidx) {
+		u.moveQuantumParticle(p, informer)
+		return
+	}
 	dims := len(p.Pos)
 
 	if adapt != 1.0 {
@@ -279,27 +271,7 @@ func (u *StandardUpdater) moveOneParticle(pidx int) {
 	}
 
 	if u.Conf.BackwardAdapt {
-		var particles []*particle.Particle
-		for i := 0; i < len(u.swarm); i++ {
-			particles = append(particles, u.swarm[i])
-		}
-		// Sort by descending fitness.
-		sort.Slice(particles, func(a, b int) bool {
-			return u.Fitness.LessFit(particles[b].BestVal, particles[a].BestVal)
-		})
-		// Best is now on top. Compute distances and look for surprises (descending distance).
-		numDescents := 0
-		best := particles[0]
-		curr := 0.0
-		for _, p := range particles {
-			dist := p.BestPos.Sub(best.BestPos).Norm(2)
-			if dist < curr {
-				numDescents++
-			}
-			curr = dist
-		}
-		// Surprises / (P-2) gives the estimated amount of non-convexity:
-		nonConvexity := float64(numDescents) / float64(len(u.swarm)-2)
+		nonConvexity := NonConvexity(u.swarm, u.Fitness.LessFit)
 
 		// TODO: determine whether to *also* slide the top down
 
@@ -322,24 +294,50 @@ func (u *StandardUpdater) moveOneParticle(pidx int) {
 	scratch := p.Scratch()
 
 	dot := p.Vel.Normalized().Dot(acc.Normalized())
-	// u.printChan <- fmt.Sprintf("tug=%v", dot)
 
 	scratch.Vel.Replace(p.Vel).SMulBy(u.momentum(p, dot)).AddBy(acc)
 
-	sl := u.Fitness.SideLengths()
-	for i, v := range scratch.Vel {
-		mv := maxvel_fraction * sl[i]
-		if v > mv {
-			scratch.Vel[i] = mv
-		} else if v < -mv {
-			scratch.Vel[i] = -mv
-		}
-	}
+	u.capVelocity(scratch.Vel, maxvel_fraction)
 
 	scratch.Pos.Replace(p.Pos).AddBy(scratch.Vel)
 	scratch.Bounced = false
+	scratch.WallHit = false
 }
 
+// NonConvexity estimates how far from convex the function looks around the
+// swarm's personal bests, from 0 to 1. Sorted from best to worst, bests should
+// get steadily further from the best one on a convex function, so the
+// estimate is the fraction of "surprises" where the distance goes down
+// instead.
+func NonConvexity(swarm []*particle.Particle, lessFit func(a, b float64) bool) float64 {
+	if len(swarm) <= 2 {
+		return 0
+	}
+	particles := make([]*particle.Particle, len(swarm))
+	copy(particles, swarm)
+	// Sort by descending fitness.
+	sort.Slice(particles, func(a, b int) bool {
+		return lessFit(particles[b].BestVal, particles[a].BestVal)
+	})
+	// Best is now on top. Compute distances and look for surprises (descending distance).
+	numDescents := 0
+	best := particles[0]
+	curr := 0.0
+	for _, p := range particles {
+		dist := p.BestPos.Dist(best.BestPos)
+		if dist < curr {
+			numDescents++
+		}
+		curr = dist
+	}
+	// Surprises / (P-2) gives the estimated amount of non-convexity:
+	return float64(numDescents) / float64(len(swarm)-2)
+}
+
+// bounceAll lets Conf.Collisions respond to particles that have come too
+// close to each other. Each particle's collision radius starts at
+// RadiusMultiplier times the domain diameter, and shrinks by DecayRadius with
+// every bounce it has made.
 func (u *StandardUpdater) bounceAll() {
 	radius := u.Conf.RadiusMultiplier * u.domainDiameter
 	bounce_factor := u.Conf.DecayRadius
@@ -352,37 +350,14 @@ func (u *StandardUpdater) bounceAll() {
 		func(i int, _ float64) float64 {
 			return math.Pow(bounce_factor, float64(u.swarm[i].Bounces))
 		})
-	// TODO: implement in go routines. Note that we'd need to protect the
-	// "other" particle if we try to bounce it, because we may have a race
-	// condition with it.
-	for i, p := range u.swarm {
+	policy := u.Conf.Collisions
+	if policy == nil {
+		policy = ReflectCollisions{}
+	}
+	policy.Collide(u, radius, factors)
+	for _, p := range u.swarm {
 		if p.Scratch().Bounced {
-			// TODO: determine whether we want to do it this way.
-			// This approach means that a particle only ever collides with
-			// one other, which means that all overlaps are not detected.
-			// That is probably the best approach, because that means that
-			// some particles can still occupy that space.
-			continue
-		}
-		for n := i + 1; n < len(u.swarm); n++ {
-			other := u.swarm[n]
-			test_dist := (factors[i] + factors[n]) * radius
-			real_dist := other.Scratch().Pos.Sub(p.Scratch().Pos).Mag()
-			if real_dist < test_dist {
-				if !p.Scratch().Bounced {
-					u.doBounce(p, 1.0/factors[i])
-				}
-				if !other.Scratch().Bounced {
-					u.doBounce(other, 1.0/factors[n])
-				}
-			}
+			u.observeBounce(p)
 		}
 	}
 }
-
-func (u *StandardUpdater) doBounce(particle *particle.Particle, springiness float64) {
-	bounceBy := springiness * u.Conf.BounceMultiplier
-	particle.Scratch().Vel.Negate()
-	particle.Scratch().Pos.SMulBy(bounceBy).Negate().AddBy(particle.Pos.SMul(1 + bounceBy))
-	particle.Scratch().Bounced = true
-}
@@ -0,0 +1,215 @@
+package pso
+
+import (
+	"bytes"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+func seededSwarm(t *testing.T, seed int64, procs int) []*particle.Particle {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+	topo, err := topology.NewRandomExpander(rng.Derive(seed, -1), 20, 3)
+	if err != nil {
+		t.Fatalf("Failed to create topology: %v", err)
+	}
+	conf := NewBasicConfig(rng.Streams(seed))
+	conf.BackwardAdapt = true
+	conf.SocLower = -0.5
+	u := NewStandardPSO(topo, fitness.NewRastrigin(10, 0.25), conf)
+	for i := 0; i < 50; i++ {
+		u.Update()
+	}
+	return u.Swarm()
+}
+
+func TestSeededRunsAreReproducible(t *testing.T) {
+	a := seededSwarm(t, 1234, 1)
+	b := seededSwarm(t, 1234, 8)
+	for i := range a {
+		if a[i].Id != i {
+			t.Errorf("particle at index %d has Id %d", i, a[i].Id)
+		}
+		if a[i].BestVal != b[i].BestVal || a[i].Bounces != b[i].Bounces {
+			t.Fatalf("particle %d differs between runs:\n%v\n%v", i, a[i], b[i])
+		}
+		for d := range a[i].Pos {
+			if a[i].Pos[d] != b[i].Pos[d] || a[i].Vel[d] != b[i].Vel[d] {
+				t.Fatalf("particle %d differs between runs:\n%v\n%v", i, a[i], b[i])
+			}
+		}
+	}
+}
+
+func TestMomentumIter(t *testing.T) {
+	conf := NewBasicConfig(rng.Streams(1))
+	var iters []int
+	conf.Momentum = func(u Updater, iter int, particle int) float64 {
+		if particle == 0 {
+			iters = append(iters, iter)
+		}
+		return conf.Momentum0
+	}
+	u := NewStandardPSO(topology.NewRing(10), fitness.NewParabola(2, 0), conf)
+	for i := 0; i < 3; i++ {
+		u.Update()
+	}
+	// Schedules count evaluations from the end of initialization.
+	if len(iters) != 2 || iters[0] != 0 || iters[1] != 10 {
+		t.Errorf("expected momentum at iterations [0 10], got %v", iters)
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	newUpdater := func() *StandardUpdater {
+		topo, err := topology.NewRandomExpander(rng.Derive(99, -1), 15, 3)
+		if err != nil {
+			t.Fatalf("Failed to create topology: %v", err)
+		}
+		return NewStandardPSO(topo, fitness.NewAckley(8, 0.25), NewBasicConfig(rng.Streams(99)))
+	}
+
+	straight := newUpdater()
+	for i := 0; i < 40; i++ {
+		straight.Update()
+	}
+
+	first := newUpdater()
+	for i := 0; i < 20; i++ {
+		first.Update()
+	}
+	var buf bytes.Buffer
+	if err := first.WriteCheckpoint(&buf); err != nil {
+		t.Fatalf("Failed to write checkpoint: %v", err)
+	}
+
+	resumed := newUpdater()
+	if err := resumed.ReadCheckpoint(&buf); err != nil {
+		t.Fatalf("Failed to read checkpoint: %v", err)
+	}
+	if resumed.Evals() != first.Evals() {
+		t.Errorf("expected %d evals after resume, got %d", first.Evals(), resumed.Evals())
+	}
+	for i := 0; i < 20; i++ {
+		resumed.Update()
+	}
+
+	a, b := straight.Batches()
+	c, d := resumed.Batches()
+	if a != c || b != d {
+		t.Errorf("batches differ: straight (%d, %d), resumed (%d, %d)", a, b, c, d)
+	}
+	for i, p := range straight.Swarm() {
+		q := resumed.Swarm()[i]
+		if p.String() != q.String() || p.Pos.Sub(q.Pos).Mag() != 0 {
+			t.Fatalf("particle %d differs after resume:\n%v\n%v", i, p, q)
+		}
+	}
+}
+
+func TestBatchEvaluationMatchesQuery(t *testing.T) {
+	var calls, evaluated int64
+	rastrigin := fitness.NewRastrigin(6, 0.25)
+	batched := fitness.NewBatchFitness(6, vec.NewFilled(6, -5.12), vec.NewFilled(6, 5.12), 0.25, func(f *fitness.Fitness, pos []vec.Vec) []float64 {
+		atomic.AddInt64(&calls, 1)
+		atomic.AddInt64(&evaluated, int64(len(pos)))
+		vals := make([]float64, len(pos))
+		for i, p := range pos {
+			vals[i] = rastrigin.Query(p)
+		}
+		return vals
+	})
+
+	run := func(f fitness.Function) *StandardUpdater {
+		conf := NewBasicConfig(rng.Streams(5))
+		// Particles that leave the domain are not evaluated.
+		conf.Boundary = InfinityBoundary
+		u := NewStandardPSO(topology.NewRing(12), f, conf)
+		for i := 0; i < 40; i++ {
+			u.Update()
+		}
+		return u
+	}
+	single, batch := run(rastrigin), run(batched)
+
+	if calls > 40 || int(evaluated) != batch.Evals() {
+		t.Errorf("expected at most one call per batch for %d evals, got %d calls for %d", batch.Evals(), calls, evaluated)
+	}
+	if single.Evals() == 40*12 {
+		t.Errorf("expected some particles to leave the domain")
+	}
+	if single.Evals() != batch.Evals() || batch.EvalStats().Count != batch.Evals() {
+		t.Errorf("expected %d evals with batches, got %d (%d timed)", single.Evals(), batch.Evals(), batch.EvalStats().Count)
+	}
+	for i, p := range single.Swarm() {
+		q := batch.Swarm()[i]
+		if p.String() != q.String() || p.Pos.Sub(q.Pos).Mag() != 0 {
+			t.Fatalf("particle %d differs with batches:\n%v\n%v", i, p, q)
+		}
+	}
+}
+
+// batchRecorder records the number of finished batches that its updater
+// reports whenever it is queried.
+type batchRecorder struct {
+	fitness.Function
+	u Updater
+
+	lock sync.Mutex
+	seen map[int]bool
+}
+
+func (f *batchRecorder) Query(pos vec.Vec) float64 {
+	_, batches := f.u.Batches()
+	f.lock.Lock()
+	f.seen[batches] = true
+	f.lock.Unlock()
+	return f.Function.Query(pos)
+}
+
+func TestBatchesCountedAfterUpdate(t *testing.T) {
+	// Every updater counts a batch once it is over, so that anything looking
+	// at Batches() during batch k sees k.
+	newUpdaters := map[string]func(f fitness.Function) Updater{
+		"standard": func(f fitness.Function) Updater {
+			return NewStandardPSO(topology.NewRing(10), f, NewBasicConfig(rng.Streams(1)))
+		},
+		"barebones": func(f fitness.Function) Updater {
+			return NewBareBones(topology.NewRing(10), f, NewBasicConfig(rng.Streams(1)), BareBonesGaussian)
+		},
+		"fips": func(f fitness.Function) Updater {
+			return NewFIPS(topology.NewRing(10), f, NewBasicConfig(rng.Streams(1)), FIPSUniform)
+		},
+		"binary": func(f fitness.Function) Updater {
+			return NewBinary(topology.NewRing(10), f, NewBasicConfig(rng.Streams(1)))
+		},
+		"spso2011": func(f fitness.Function) Updater {
+			u, err := NewSPSO2011(f, 10, rng.Streams(1))
+			if err != nil {
+				t.Fatalf("NewSPSO2011: %v", err)
+			}
+			return u
+		},
+	}
+	for name, newUpdater := range newUpdaters {
+		f := &batchRecorder{Function: fitness.NewParabola(4, 0)}
+		f.u = newUpdater(f)
+		for batch := 0; batch < 5; batch++ {
+			f.seen = map[int]bool{}
+			f.u.Update()
+			if len(f.seen) != 1 || !f.seen[batch] {
+				t.Errorf("%s: expected batch %d to see %d finished batches, saw %v", name, batch, batch, f.seen)
+			}
+			if _, total := f.u.Batches(); total != batch+1 {
+				t.Errorf("%s: expected %d batches after update, got %d", name, batch+1, total)
+			}
+		}
+	}
+}
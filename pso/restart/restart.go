@@ -0,0 +1,393 @@
+// Package restart detects when a swarm has stagnated and restarts it, so that
+// the rest of the evaluation budget goes toward new regions instead of
+// polishing a collapsed swarm. A Restarter wraps an updater, and is itself an
+// updater that can be given to pso.Run.
+package restart
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso"
+	"github.com/shiblon/entrogo/pso/metrics"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// Status describes the current attempt, that is, the swarm since its last
+// restart. It is handed to the Detector after each batch.
+type Status struct {
+	Updater       pso.Updater
+	Restart       int     // number of restarts before this attempt
+	Batches       int     // batches in this attempt
+	Stale         int     // batches since this attempt's best last improved
+	BestVal       float64 // best value of this attempt
+	BestViolation float64 // total constraint violation of that best
+}
+
+// Detector decides whether the current attempt has stagnated.
+type Detector func(s *Status) bool
+
+// NoImprovement detects stagnation when the best of the attempt has not
+// improved for n batches.
+func NoImprovement(n int) Detector {
+	return func(s *Status) bool {
+		return s.Stale >= n
+	}
+}
+
+// LowDiversity detects stagnation when the swarm's diversity (see
+// metrics.Snapshot) falls below threshold.
+func LowDiversity(f fitness.Function, threshold float64) Detector {
+	return func(s *Status) bool {
+		return metrics.Measure(s.Updater.Swarm(), f).Diversity < threshold
+	}
+}
+
+// Any detects stagnation when any of the detectors does.
+func Any(detectors ...Detector) Detector {
+	return func(s *Status) bool {
+		for _, d := range detectors {
+			if d(s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Policy starts the next attempt after the current one stagnates, given the
+// number of restarts so far (including this one). It returns the updater to
+// continue with, which may be the same one, and the number of function
+// evaluations it used. A new updater may be returned uninitialized, in which
+// case the Restarter initializes it, once it is observed like the last one.
+type Policy func(u pso.Updater, restart int) (next pso.Updater, evals int)
+
+// reinitializer is an updater that can scatter its swarm again, such as
+// pso.StandardUpdater.
+type reinitializer interface {
+	Reinitialize() int
+}
+
+// ReinitExceptBest returns a policy that keeps the updater u, but moves every
+// particle except the best one to a random position with no memory. It is an
+// error if u has no Reinitialize method, like pso.StandardUpdater's.
+func ReinitExceptBest(u pso.Updater) (Policy, error) {
+	if _, ok := u.(reinitializer); !ok {
+		return nil, fmt.Errorf("updater %T cannot be reinitialized", u)
+	}
+	return func(u pso.Updater, restart int) (pso.Updater, int) {
+		return u, u.(reinitializer).Reinitialize()
+	}, nil
+}
+
+// Factory creates a new, uninitialized updater with the given swarm size and
+// master seed.
+type Factory func(size int, seed int64) pso.Updater
+
+// IPOP starts every attempt with a new updater, as in the IPOP restart
+// strategy of Auger and Hansen (2005): the swarm size starts at size and is
+// multiplied by growth at every restart, which trades speed for a more global
+// search as the run goes on. Seeds for the new updaters are derived from seed.
+// The new swarm is left for the Restarter to initialize.
+func IPOP(newUpdater Factory, size int, growth float64, seed int64) Policy {
+	return func(u pso.Updater, restart int) (pso.Updater, int) {
+		n := int(math.Floor(float64(size)*math.Pow(growth, float64(restart)) + 0.5))
+		return newUpdater(n, rng.Derive(seed, restart).Int63()), 0
+	}
+}
+
+// RandomSeeds starts every attempt with a new updater of the same size and a
+// new seed derived from seed.
+func RandomSeeds(newUpdater Factory, size int, seed int64) Policy {
+	return IPOP(newUpdater, size, 1, seed)
+}
+
+// LessFit returns true if value a with total constraint violation aViol is
+// less fit than value b with violation bViol.
+type LessFit func(a, aViol, b, bViol float64) bool
+
+// byValue compares values with f, ignoring violations.
+func byValue(f fitness.Function) LessFit {
+	return func(a, aViol, b, bViol float64) bool {
+		return f.LessFit(a, b)
+	}
+}
+
+// Entry is the best solution found in one attempt.
+type Entry struct {
+	Pos       vec.Vec
+	Val       float64
+	Violation float64 // total constraint violation, zero if feasible
+	Restart   int     // restarts before the attempt
+}
+
+func (e Entry) String() string {
+	if e.Violation > 0 {
+		return fmt.Sprintf("restart %d: f=%f violation=%f x=%f", e.Restart, e.Val, e.Violation, []float64(e.Pos))
+	}
+	return fmt.Sprintf("restart %d: f=%f x=%f", e.Restart, e.Val, []float64(e.Pos))
+}
+
+// HallOfFame keeps the best solutions of the fittest attempts, best first.
+type HallOfFame struct {
+	size    int
+	lessFit LessFit
+	entries []Entry
+}
+
+// NewHallOfFame creates a hall of fame that holds the best solutions of up to
+// size attempts, ranked by lessFit.
+func NewHallOfFame(lessFit LessFit, size int) *HallOfFame {
+	return &HallOfFame{size: size, lessFit: lessFit}
+}
+
+// Entries returns the solutions, best first.
+func (h *HallOfFame) Entries() []Entry {
+	return h.entries
+}
+
+// Offer records the best solution of an attempt so far, replacing the one
+// recorded before for the same attempt if it is fitter. A solution that is
+// already recorded for an earlier attempt (as when the best particle survives
+// a restart) is ignored.
+func (h *HallOfFame) Offer(restart int, pos vec.Vec, val, viol float64) {
+	found := false
+	for i := range h.entries {
+		e := &h.entries[i]
+		if e.Restart != restart {
+			if e.Pos.Dist(pos) == 0 {
+				return
+			}
+			continue
+		}
+		found = true
+		if !h.lessFit(e.Val, e.Violation, val, viol) {
+			return
+		}
+		e.Pos, e.Val, e.Violation = pos.Copy(), val, viol
+		break
+	}
+	if !found {
+		h.entries = append(h.entries, Entry{Pos: pos.Copy(), Val: val, Violation: viol, Restart: restart})
+	}
+	sort.SliceStable(h.entries, func(i, j int) bool {
+		a, b := h.entries[j], h.entries[i]
+		return h.lessFit(a.Val, a.Violation, b.Val, b.Violation)
+	})
+	if len(h.entries) > h.size {
+		h.entries = h.entries[:h.size]
+	}
+}
+
+// Restarter is an updater that runs another one until Detect says that it has
+// stagnated, and then uses Policy to start the next attempt. The best
+// particle is the best found across all attempts. Solutions are compared the
+// way the current attempt's updater compares them (see LessFit), so that
+// constraint violations count across attempts too.
+//
+// An observer given to Observe sees a single run: batches are numbered across
+// attempts and include the evaluations used to restart, and global bests are
+// only reported when they beat every attempt so far. Every attempt's updater
+// must have an Observe method, like pso.StandardUpdater, for its particle
+// events to be passed on.
+type Restarter struct {
+	Detect Detector
+	Policy Policy
+	Fame   *HallOfFame
+
+	fit           fitness.Function
+	current       pso.Updater
+	status        Status
+	best          *particle.Particle // best across attempts
+	totalEvals    int
+	totalBatches  int
+	totalImproved int
+
+	observer      pso.Observer
+	seenBest      bool    // whether the observer has seen a global best
+	bestVal       float64 // the last global best the observer has seen
+	bestViolation float64
+}
+
+// observable is an updater that can be observed, like pso.StandardUpdater.
+type observable interface {
+	Observe(o pso.Observer)
+}
+
+// closer is an updater with goroutines to stop once it is done, like
+// pso.StandardUpdater.
+type closer interface {
+	Close()
+}
+
+// comparer is an updater that compares values together with their constraint
+// violations, like pso.StandardUpdater.
+type comparer interface {
+	LessFit(a, aViol, b, bViol float64) bool
+}
+
+// New creates a restarter that starts with the updater u, which optimizes f,
+// and keeps the best solutions of up to fameSize attempts.
+func New(u pso.Updater, f fitness.Function, detect Detector, policy Policy, fameSize int) *Restarter {
+	r := &Restarter{
+		Detect:  detect,
+		Policy:  policy,
+		fit:     f,
+		current: u,
+		status:  Status{Updater: u},
+	}
+	r.Fame = NewHallOfFame(r.LessFit, fameSize)
+	return r
+}
+
+// LessFit returns true if value a with total violation aViol is less fit
+// than value b with violation bViol, using the constraint handling of the
+// current attempt's updater. Violations are ignored if it has none.
+func (r *Restarter) LessFit(a, aViol, b, bViol float64) bool {
+	if c, ok := r.current.(comparer); ok {
+		return c.LessFit(a, aViol, b, bViol)
+	}
+	return byValue(r.fit)(a, aViol, b, bViol)
+}
+
+// Observe sets the observer of the restarter's events, replacing any previous
+// one. A nil observer turns events off.
+func (r *Restarter) Observe(o pso.Observer) {
+	r.observer = o
+	r.relayTo(r.current)
+}
+
+// Observer returns the current observer, or nil.
+func (r *Restarter) Observer() pso.Observer {
+	return r.observer
+}
+
+// relayTo passes u's particle events on to the observer, if there is one.
+func (r *Restarter) relayTo(u pso.Updater) {
+	ou, ok := u.(observable)
+	if !ok {
+		return
+	}
+	if r.observer == nil {
+		ou.Observe(nil)
+		return
+	}
+	ou.Observe(relay{r})
+}
+
+// relay passes the particle events of an attempt's updater on to the
+// restarter's observer. The restarter reports batches itself.
+type relay struct {
+	r *Restarter
+}
+
+func (rl relay) BatchStart(batch int)              {}
+func (rl relay) BatchEnd(batch, evals int)         {}
+func (rl relay) Terminated(res *pso.Result)        {}
+func (rl relay) Evaluated(p *particle.Particle)    { rl.r.observer.Evaluated(p) }
+func (rl relay) PersonalBest(p *particle.Particle) { rl.r.observer.PersonalBest(p) }
+func (rl relay) Bounced(p *particle.Particle)      { rl.r.observer.Bounced(p) }
+func (rl relay) GlobalBest(p *particle.Particle) {
+	r := rl.r
+	if r.seenBest && !r.LessFit(r.bestVal, r.bestViolation, p.BestVal, p.BestViolation) {
+		return
+	}
+	r.seenBest, r.bestVal, r.bestViolation = true, p.BestVal, p.BestViolation
+	r.observer.GlobalBest(p)
+}
+
+// Close closes the updater of the current attempt, if it has a Close method.
+// The updaters of earlier attempts are closed as they are replaced.
+func (r *Restarter) Close() {
+	if c, ok := r.current.(closer); ok {
+		c.Close()
+	}
+}
+
+// Current returns the updater of the current attempt.
+func (r *Restarter) Current() pso.Updater {
+	return r.current
+}
+
+// Restarts returns the number of restarts so far.
+func (r *Restarter) Restarts() int {
+	return r.status.Restart
+}
+
+// Initialized returns true once the first attempt has started.
+func (r *Restarter) Initialized() bool {
+	return r.current.Initialized()
+}
+
+// Swarm returns the swarm of the current attempt.
+func (r *Restarter) Swarm() []*particle.Particle {
+	return r.current.Swarm()
+}
+
+// BestParticle returns a copy of the best particle found in any attempt.
+func (r *Restarter) BestParticle() *particle.Particle {
+	return r.best
+}
+
+// Batches returns the number of batches that improved the best particle
+// across attempts, and the total batches.
+func (r *Restarter) Batches() (improved, total int) {
+	return r.totalImproved, r.totalBatches
+}
+
+// Evals returns the total number of function evaluations so far.
+func (r *Restarter) Evals() int {
+	return r.totalEvals
+}
+
+// Update runs a batch of the current attempt, and restarts if it has
+// stagnated. Returns the number of function evaluations performed, including
+// those used to restart.
+func (r *Restarter) Update() (evals int) {
+	batch := r.totalBatches
+	if r.observer != nil {
+		r.observer.BatchStart(batch)
+	}
+	evals = r.current.Update()
+
+	s := &r.status
+	cur := r.current.BestParticle()
+	if s.Batches == 0 || r.LessFit(s.BestVal, s.BestViolation, cur.BestVal, cur.BestViolation) {
+		s.BestVal, s.BestViolation = cur.BestVal, cur.BestViolation
+		s.Stale = 0
+	} else {
+		s.Stale++
+	}
+	s.Batches++
+	r.Fame.Offer(s.Restart, cur.BestPos, cur.BestVal, cur.BestViolation)
+	if r.best == nil || r.LessFit(r.best.BestVal, r.best.BestViolation, cur.BestVal, cur.BestViolation) {
+		r.best = cur.Clone()
+		r.totalImproved++
+	}
+
+	if r.Detect(s) {
+		next, restartEvals := r.Policy(r.current, s.Restart+1)
+		evals += restartEvals
+		if next != r.current {
+			r.relayTo(next)
+			if c, ok := r.current.(closer); ok {
+				c.Close()
+			}
+		}
+		if !next.Initialized() {
+			evals += next.Update()
+		}
+		r.current = next
+		r.status = Status{Updater: next, Restart: s.Restart + 1}
+	}
+	r.totalEvals += evals
+	r.totalBatches++
+	if r.observer != nil {
+		r.observer.BatchEnd(batch, evals)
+	}
+	return evals
+}
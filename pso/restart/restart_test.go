@@ -0,0 +1,250 @@
+package restart
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// newStandard creates a quickly converging updater.
+func newStandard(f fitness.Function, size int, seed int64) *pso.StandardUpdater {
+	conf := pso.NewBasicConfig(rng.Streams(seed))
+	conf.Momentum0 = 0.7298
+	conf.SocConst, conf.CogConst = 1.49618, 1.49618
+	conf.RadiusMultiplier = 0
+	return pso.NewStandardPSO(topology.NewRing(size), f, conf)
+}
+
+func TestDetectors(t *testing.T) {
+	s := &Status{Stale: 4}
+	if NoImprovement(5)(s) {
+		t.Errorf("detected stagnation after 4 stale batches")
+	}
+	s.Stale = 5
+	if !NoImprovement(5)(s) {
+		t.Errorf("missed stagnation after 5 stale batches")
+	}
+	if !Any(NoImprovement(10), NoImprovement(5))(s) || Any(NoImprovement(10))(s) {
+		t.Errorf("Any did not combine detectors")
+	}
+
+	f := fitness.NewParabola(3, 0.25)
+	u := newStandard(f, 10, 1)
+	u.Update()
+	s.Updater = u
+	if LowDiversity(f, 0.01)(s) {
+		t.Errorf("detected low diversity in a new swarm")
+	}
+	for i := 0; i < 300; i++ {
+		u.Update()
+	}
+	if !LowDiversity(f, 0.01)(s) {
+		t.Errorf("missed low diversity in a converged swarm")
+	}
+}
+
+func TestHallOfFame(t *testing.T) {
+	h := NewHallOfFame(byValue(fitness.NewParabola(1, 0)), 2)
+	h.Offer(0, vec.Vec{3}, 9, 0)
+	h.Offer(0, vec.Vec{2}, 4, 0)
+	h.Offer(0, vec.Vec{5}, 25, 0) // worse than what attempt 0 already found
+	h.Offer(1, vec.Vec{1}, 1, 0)
+	h.Offer(2, vec.Vec{4}, 16, 0) // not good enough to be kept
+	h.Offer(3, vec.Vec{1}, 1, 0)  // already found by attempt 1
+	got := h.Entries()
+	if len(got) != 2 || got[0].Restart != 1 || got[0].Val != 1 || got[1].Restart != 0 || got[1].Val != 4 {
+		t.Errorf("unexpected hall of fame %v", got)
+	}
+
+	// With feasibility rules, feasible solutions come first whatever their
+	// values.
+	f := fitness.NewG06()
+	h = NewHallOfFame(func(a, aViol, b, bViol float64) bool {
+		return pso.FeasibilityRules{}.LessFit(f, a, aViol, b, bViol)
+	}, 3)
+	h.Offer(0, vec.Vec{14, 1}, -6000, 0)
+	h.Offer(0, vec.Vec{14, 0}, -8000, 2) // infeasible, so no better
+	h.Offer(1, vec.Vec{20, 0}, -7000, 1)
+	h.Offer(2, vec.Vec{20, 1}, -5000, 0)
+	got = h.Entries()
+	if len(got) != 3 || got[0].Val != -6000 || got[1].Val != -5000 || got[2].Val != -7000 {
+		t.Errorf("unexpected constrained hall of fame %v", got)
+	}
+}
+
+func TestRestartConstrained(t *testing.T) {
+	f := fitness.NewG06()
+	newUpdater := func(size int, seed int64) pso.Updater { return newStandard(f, size, seed) }
+	r := New(newUpdater(10, 1), f, NoImprovement(30), RandomSeeds(newUpdater, 10, 2), 5)
+	res := pso.Run(context.Background(), r, pso.MaxEvals(40000))
+	if r.Restarts() == 0 {
+		t.Fatalf("expected restarts")
+	}
+	if res.BestViolation > 0 {
+		t.Errorf("expected a feasible best across attempts, got f=%v with violation %v", res.BestVal, res.BestViolation)
+	}
+	fame := r.Fame.Entries()
+	if fame[0].Violation > 0 || fame[0].Val != res.BestVal {
+		t.Errorf("expected the best result %v to lead the hall of fame %v", res.BestVal, fame)
+	}
+	for i := 1; i < len(fame); i++ {
+		if fame[i-1].Violation > 0 && fame[i].Violation == 0 {
+			t.Errorf("infeasible entry ranked above a feasible one: %v", fame)
+		}
+	}
+}
+
+func TestReinitExceptBest(t *testing.T) {
+	f := fitness.NewRastrigin(4, 0.25)
+	u := newStandard(f, 10, 2)
+	policy, err := ReinitExceptBest(u)
+	if err != nil {
+		t.Fatalf("ReinitExceptBest: %v", err)
+	}
+	r := New(u, f, NoImprovement(20), policy, 5)
+	var bests []float64
+	r.Update()
+	for r.Evals() < 20000 {
+		r.Update()
+		bests = append(bests, r.BestParticle().BestVal)
+	}
+
+	if r.Restarts() == 0 {
+		t.Fatalf("expected restarts")
+	}
+	if r.Current() == nil || r.Evals() != r.Current().(*pso.StandardUpdater).Evals() {
+		t.Errorf("expected one updater with all %d evals", r.Evals())
+	}
+	for i := 1; i < len(bests); i++ {
+		if f.LessFit(bests[i], bests[i-1]) {
+			t.Fatalf("best value got worse: %v to %v", bests[i-1], bests[i])
+		}
+	}
+	fame := r.Fame.Entries()
+	if len(fame) == 0 || fame[0].Val != r.BestParticle().BestVal {
+		t.Errorf("expected the best value %v at the top of the hall of fame, got %v", r.BestParticle().BestVal, fame)
+	}
+}
+
+func TestIPOP(t *testing.T) {
+	f := fitness.NewRastrigin(4, 0.25)
+	var sizes []int
+	factory := func(size int, seed int64) pso.Updater {
+		sizes = append(sizes, size)
+		return newStandard(f, size, seed)
+	}
+	r := New(factory(10, 3), f, NoImprovement(30), IPOP(factory, 10, 2, 3), 10)
+	res := pso.Run(context.Background(), r, pso.MaxEvals(30000))
+
+	if len(sizes) < 3 {
+		t.Fatalf("expected at least 2 restarts, got sizes %v", sizes)
+	}
+	for i, size := range sizes {
+		if want := 10 << uint(i); size != want {
+			t.Errorf("expected attempt %d to have size %d, got %d", i, want, size)
+		}
+	}
+	if len(r.Swarm()) != sizes[len(sizes)-1] {
+		t.Errorf("expected the current swarm to have size %d, got %d", sizes[len(sizes)-1], len(r.Swarm()))
+	}
+	if res.Evals != r.Evals() || res.BestVal != r.Fame.Entries()[0].Val {
+		t.Errorf("result %+v disagrees with restarter (evals %d, fame %v)", res, r.Evals(), r.Fame.Entries())
+	}
+}
+
+func TestReinitNeedsReinitialize(t *testing.T) {
+	f := fitness.NewRastrigin(4, 0.25)
+	u := pso.NewBareBones(topology.NewRing(10), f, pso.NewBasicConfig(rng.Streams(1)), pso.BareBonesGaussian)
+	if _, err := ReinitExceptBest(u); err == nil {
+		t.Errorf("expected an error for an updater without Reinitialize")
+	}
+}
+
+// runObserver checks that a restarter reports a single run.
+type runObserver struct {
+	t *testing.T
+	f fitness.Function
+
+	inBatch    bool
+	batches    int
+	evals      int
+	evaluated  int
+	globalVals []float64
+	result     *pso.Result
+}
+
+func (o *runObserver) BatchStart(batch int) {
+	if o.inBatch || batch != o.batches {
+		o.t.Errorf("batch %d started after %d batches (in batch: %v)", batch, o.batches, o.inBatch)
+	}
+	o.inBatch = true
+}
+
+func (o *runObserver) BatchEnd(batch, evals int) {
+	if !o.inBatch || batch != o.batches {
+		o.t.Errorf("batch %d ended after %d batches (in batch: %v)", batch, o.batches, o.inBatch)
+	}
+	o.inBatch = false
+	o.batches++
+	o.evals += evals
+}
+
+func (o *runObserver) Evaluated(p *particle.Particle) {
+	if !o.inBatch {
+		o.t.Errorf("particle %d evaluated outside of a batch", p.Id)
+	}
+	o.evaluated++
+}
+
+func (o *runObserver) GlobalBest(p *particle.Particle) {
+	if n := len(o.globalVals); n > 0 && !o.f.LessFit(o.globalVals[n-1], p.BestVal) {
+		o.t.Errorf("global best went from %v to %v", o.globalVals[n-1], p.BestVal)
+	}
+	o.globalVals = append(o.globalVals, p.BestVal)
+}
+
+func (o *runObserver) PersonalBest(p *particle.Particle) {}
+func (o *runObserver) Bounced(p *particle.Particle)      {}
+func (o *runObserver) Terminated(res *pso.Result)        { o.result = res }
+
+func TestObserveRestarts(t *testing.T) {
+	f := fitness.NewRastrigin(4, 0.25)
+	factory := func(size int, seed int64) pso.Updater {
+		return newStandard(f, size, seed)
+	}
+	first := newStandard(f, 10, 4)
+	reinit, err := ReinitExceptBest(first)
+	if err != nil {
+		t.Fatalf("ReinitExceptBest: %v", err)
+	}
+	for name, r := range map[string]*Restarter{
+		"reinit": New(first, f, NoImprovement(20), reinit, 5),
+		"ipop":   New(factory(10, 4), f, NoImprovement(20), IPOP(factory, 10, 2, 4), 5),
+	} {
+		obs := &runObserver{t: t, f: f}
+		r.Observe(obs)
+		res := pso.Run(context.Background(), r, pso.MaxEvals(20000))
+
+		if r.Restarts() == 0 {
+			t.Errorf("%s: expected restarts", name)
+		}
+		if obs.result != res {
+			t.Errorf("%s: expected the result of the run on termination", name)
+		}
+		if _, total := r.Batches(); obs.batches != total || obs.inBatch {
+			t.Errorf("%s: expected %d finished batches, got %d", name, total, obs.batches)
+		}
+		if obs.evals != r.Evals() || obs.evaluated != r.Evals() {
+			t.Errorf("%s: expected %d evaluations, got %d in batches and %d events", name, r.Evals(), obs.evals, obs.evaluated)
+		}
+		if n := len(obs.globalVals); n == 0 || obs.globalVals[n-1] != res.BestVal {
+			t.Errorf("%s: expected the last global best to be %v, got %v", name, res.BestVal, obs.globalVals)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Package rng contains a small, fast random source that can be split into many
+// independent streams from a single master seed. This is what makes seeded
+// runs reproducible: every particle (and anything else that needs randomness)
+// gets its own stream, so results do not depend on goroutine scheduling.
+package rng
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+)
+
+const golden = 0x9e3779b97f4a7c15
+
+// mix is the SplitMix64 finalizer.
+func mix(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Source is a SplitMix64 generator. It implements rand.Source64. Like the
+// sources in math/rand, it is not safe for concurrent use.
+type Source struct {
+	state uint64
+}
+
+// New creates a source seeded with the given value.
+func New(seed int64) *Source {
+	s := &Source{}
+	s.Seed(seed)
+	return s
+}
+
+// Derive creates a source for the given stream of a master seed. Different
+// streams of the same seed produce unrelated sequences.
+func Derive(seed int64, stream int) *Source {
+	return &Source{state: mix(uint64(seed)+golden) ^ mix(uint64(stream)*golden+1)}
+}
+
+// Streams returns a function that derives a stream from seed for each id
+// it is given. It is suitable for use as pso.Config.NewRNG.
+func Streams(seed int64) func(id int) rand.Source {
+	return func(id int) rand.Source {
+		return Derive(seed, id)
+	}
+}
+
+// Seed resets the source to a state determined by seed.
+func (s *Source) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+// Uint64 returns the next pseudo-random 64-bit value.
+func (s *Source) Uint64() uint64 {
+	s.state += golden
+	return mix(s.state)
+}
+
+// Int63 returns the next pseudo-random non-negative 63-bit value.
+func (s *Source) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// MarshalBinary encodes the state of the source.
+func (s *Source) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, s.state)
+	return b, nil
+}
+
+// UnmarshalBinary restores state encoded by MarshalBinary.
+func (s *Source) UnmarshalBinary(b []byte) error {
+	if len(b) != 8 {
+		return fmt.Errorf("rng: invalid state length %d", len(b))
+	}
+	s.state = binary.BigEndian.Uint64(b)
+	return nil
+}
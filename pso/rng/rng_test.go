@@ -0,0 +1,30 @@
+package rng
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestStreamsAreReproducible(t *testing.T) {
+	a := rand.New(Derive(42, 3))
+	b := rand.New(Streams(42)(3))
+	for i := 0; i < 100; i++ {
+		if x, y := a.Float64(), b.Float64(); x != y {
+			t.Fatalf("draw %d differs between identical streams: %v != %v", i, x, y)
+		}
+	}
+}
+
+func TestStreamsDiffer(t *testing.T) {
+	seen := make(map[int64]int)
+	for stream := -1; stream < 100; stream++ {
+		v := Derive(42, stream).Int63()
+		if other, ok := seen[v]; ok {
+			t.Fatalf("streams %d and %d start with the same value %d", other, stream, v)
+		}
+		seen[v] = stream
+	}
+	if Derive(1, 0).Int63() == Derive(2, 0).Int63() {
+		t.Error("different seeds produced the same first value for stream 0")
+	}
+}
@@ -0,0 +1,172 @@
+package pso
+
+import (
+	"context"
+	"time"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// StopReason describes why a run terminated. The empty reason means that the
+// run should keep going.
+type StopReason string
+
+const (
+	NotStopped     StopReason = ""
+	StopEvals      StopReason = "evals"      // evaluation budget exhausted
+	StopDeadline   StopReason = "deadline"   // wall-clock time limit reached
+	StopTarget     StopReason = "target"     // target fitness reached
+	StopStagnation StopReason = "stagnation" // no global improvement for too long
+	StopCanceled   StopReason = "canceled"   // context canceled or expired
+)
+
+// Status is a snapshot of a run in progress. It is handed to every
+// StopCriterion after each batch.
+type Status struct {
+	Updater       Updater
+	BestVal       float64
+	BestViolation float64 // total constraint violation of the best, zero if feasible
+	Evals         int
+	Batches       int
+	Elapsed       time.Duration
+}
+
+// StopCriterion decides whether a run should end, given its current status.
+// It returns NotStopped to let the run continue. Criteria may keep state
+// between calls, so a fresh one should be created for each run.
+type StopCriterion func(s *Status) StopReason
+
+// Result summarizes a finished run.
+type Result struct {
+	BestPos       vec.Vec
+	BestVal       float64
+	BestViolation float64
+	Evals         int
+	Batches       int
+	Elapsed       time.Duration
+	Reason        StopReason
+}
+
+// Run drives the updater until one of the stopping criteria fires or the
+// context is done, whichever happens first. The context is checked between
+// batches, so a single Update is never interrupted. With no criteria, the run
+// only ends when the context does. If the updater has an Observer, it is told
+// when the run ends.
+func Run(ctx context.Context, u Updater, stops ...StopCriterion) *Result {
+	start := time.Now()
+	status := &Status{Updater: u}
+	reason := NotStopped
+	for reason == NotStopped {
+		select {
+		case <-ctx.Done():
+			reason = StopCanceled
+			if ctx.Err() == context.DeadlineExceeded {
+				reason = StopDeadline
+			}
+			continue
+		default:
+		}
+		status.Evals += u.Update()
+		status.Batches++
+		best := u.BestParticle()
+		status.BestVal, status.BestViolation = best.BestVal, best.BestViolation
+		status.Elapsed = time.Since(start)
+		for _, stop := range stops {
+			if reason = stop(status); reason != NotStopped {
+				break
+			}
+		}
+	}
+
+	res := &Result{
+		Evals:   status.Evals,
+		Batches: status.Batches,
+		Elapsed: time.Since(start),
+		Reason:  reason,
+	}
+	if u.Initialized() {
+		best := u.BestParticle()
+		res.BestPos = best.BestPos.Copy()
+		res.BestVal, res.BestViolation = best.BestVal, best.BestViolation
+	}
+	if o, ok := u.(observable); ok {
+		o.notify(func(o Observer) { o.Terminated(res) })
+	} else if o, ok := u.(withObserver); ok && o.Observer() != nil {
+		o.Observer().Terminated(res)
+	}
+	return res
+}
+
+// MaxEvals stops a run once at least n function evaluations have been used.
+func MaxEvals(n int) StopCriterion {
+	return func(s *Status) StopReason {
+		if s.Evals >= n {
+			return StopEvals
+		}
+		return NotStopped
+	}
+}
+
+// MaxTime stops a run once it has been going for at least d.
+func MaxTime(d time.Duration) StopCriterion {
+	return func(s *Status) StopReason {
+		if s.Elapsed >= d {
+			return StopDeadline
+		}
+		return NotStopped
+	}
+}
+
+// comparer is an updater that compares values together with their constraint
+// violations, as all of the updaters in this package do.
+type comparer interface {
+	LessFit(a, aViol, b, bViol float64) bool
+}
+
+// lessFit compares values the way the status's updater does, or by value
+// alone using f if the updater cannot compare violations.
+func (s *Status) lessFit(f fitness.Function, a, aViol, b, bViol float64) bool {
+	if c, ok := s.Updater.(comparer); ok {
+		return c.LessFit(a, aViol, b, bViol)
+	}
+	return f.LessFit(a, b)
+}
+
+// TargetVal stops a run once the best is at least as fit as a feasible
+// solution with the target value. Constraint violations are weighed the way
+// the updater weighs them, so that an infeasible best with a good value does
+// not stop the run under the feasibility rules.
+func TargetVal(f fitness.Function, target float64) StopCriterion {
+	return func(s *Status) StopReason {
+		if !s.lessFit(f, s.BestVal, s.BestViolation, target, 0) {
+			return StopTarget
+		}
+		return NotStopped
+	}
+}
+
+// Stagnation stops a run when the best has not improved for n consecutive
+// batches. Improvements are judged the way the updater judges them, so that
+// becoming feasible (or less infeasible) counts as progress even if the value
+// gets worse.
+func Stagnation(f fitness.Function, n int) StopCriterion {
+	var (
+		started   bool
+		best      float64
+		bestViol  float64
+		lastBatch int
+	)
+	return func(s *Status) StopReason {
+		if !started || s.lessFit(f, best, bestViol, s.BestVal, s.BestViolation) {
+			started = true
+			best, bestViol = s.BestVal, s.BestViolation
+			lastBatch = s.Batches
+			return NotStopped
+		}
+		if s.Batches-lastBatch >= n {
+			return StopStagnation
+		}
+		return NotStopped
+	}
+}
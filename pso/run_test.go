@@ -0,0 +1,122 @@
+package pso
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+)
+
+func newTestUpdater(f fitness.Function) *StandardUpdater {
+	conf := NewBasicConfig(rng.Streams(11))
+	return NewStandardPSO(topology.NewRing(10), f, conf)
+}
+
+func TestRunMaxEvals(t *testing.T) {
+	f := fitness.NewParabola(5, 0.25)
+	res := Run(context.Background(), newTestUpdater(f), MaxEvals(1000))
+	if res.Reason != StopEvals {
+		t.Errorf("expected reason %q, got %q", StopEvals, res.Reason)
+	}
+	if res.Evals < 1000 || res.Evals >= 1010 {
+		t.Errorf("expected between 1000 and 1010 evals, got %d", res.Evals)
+	}
+	if res.Batches != res.Evals/10 {
+		t.Errorf("expected %d batches, got %d", res.Evals/10, res.Batches)
+	}
+	if got := f.Query(res.BestPos); got != res.BestVal {
+		t.Errorf("best value %v does not match best position value %v", res.BestVal, got)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := fitness.NewParabola(5, 0.25)
+	batches := 0
+	res := Run(ctx, newTestUpdater(f), func(s *Status) StopReason {
+		if batches++; batches == 3 {
+			cancel()
+		}
+		return NotStopped
+	})
+	if res.Reason != StopCanceled {
+		t.Errorf("expected reason %q, got %q", StopCanceled, res.Reason)
+	}
+	if res.Batches != 3 {
+		t.Errorf("expected 3 batches, got %d", res.Batches)
+	}
+}
+
+func TestRunTarget(t *testing.T) {
+	f := fitness.NewParabola(2, 0.25)
+	res := Run(context.Background(), newTestUpdater(f), TargetVal(f, 1e-3), MaxEvals(100000))
+	if res.Reason != StopTarget {
+		t.Errorf("expected reason %q, got %q with best %v", StopTarget, res.Reason, res.BestVal)
+	}
+	if res.BestVal > 1e-3 {
+		t.Errorf("expected best value <= 1e-3, got %v", res.BestVal)
+	}
+}
+
+func TestConstrainedTarget(t *testing.T) {
+	f := fitness.NewG06()
+	u := NewStandardPSO(topology.NewRing(10), f, NewBasicConfig(rng.Streams(1)))
+	u.Update()
+
+	stop := TargetVal(f, -6961)
+	for _, test := range []struct {
+		val, viol float64
+		want      StopReason
+	}{
+		{-8000, 1, NotStopped},
+		{-6900, 0, NotStopped},
+		{-6962, 0, StopTarget},
+	} {
+		if got := stop(&Status{Updater: u, BestVal: test.val, BestViolation: test.viol}); got != test.want {
+			t.Errorf("f=%v with violation %v: expected %q, got %q", test.val, test.viol, test.want, got)
+		}
+	}
+}
+
+func TestStagnation(t *testing.T) {
+	parabola := fitness.NewParabola(1, 0)
+	g06 := fitness.NewG06()
+	constrained := NewStandardPSO(topology.NewRing(10), g06, NewBasicConfig(rng.Streams(1)))
+	constrained.Update()
+
+	type best struct{ val, viol float64 }
+	tests := []struct {
+		name  string
+		f     fitness.Function
+		u     Updater
+		bests []best // one per batch
+		stop  int    // batch at which Stagnation(f, 3) stops, or 0 for never
+	}{
+		{"improving", parabola, nil, []best{{5, 0}, {4, 0}, {3, 0}, {2, 0}, {1, 0}, {0.5, 0}}, 0},
+		{"stuck", parabola, nil, []best{{5, 0}, {4, 0}, {4, 0}, {4, 0}, {4, 0}, {3, 0}}, 5},
+		{"recovers", parabola, nil, []best{{5, 0}, {5, 0}, {5, 0}, {4, 0}, {4, 0}, {4, 0}}, 0},
+		// The best value gets worse as the swarm becomes feasible, which must
+		// count as progress.
+		{"feasible", g06, constrained, []best{{-8000, 5}, {-8000, 5}, {-7000, 1}, {-3000, 0}, {-3000, 0}, {-3000, 0}, {-3000, 0}}, 7},
+		{"infeasible", g06, constrained, []best{{-3000, 0}, {-8000, 5}, {-9000, 1}, {-9000, 1}}, 4},
+	}
+	for _, test := range tests {
+		stop := Stagnation(test.f, 3)
+		got := 0
+		for i, b := range test.bests {
+			s := &Status{Updater: test.u, BestVal: b.val, BestViolation: b.viol, Batches: i + 1}
+			if reason := stop(s); reason != NotStopped {
+				if reason != StopStagnation {
+					t.Errorf("%s: unexpected reason %q", test.name, reason)
+				}
+				got = s.Batches
+				break
+			}
+		}
+		if got != test.stop {
+			t.Errorf("%s: expected to stop at batch %d, stopped at %d", test.name, test.stop, got)
+		}
+	}
+}
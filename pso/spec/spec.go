@@ -0,0 +1,526 @@
+// Package spec describes a whole run in a form that can be saved, shared and
+// loaded again. A pso.Config holds functions and live state, so it cannot be
+// written out, but a Spec names them instead, with the same "name:arg:arg"
+// strings that the command line uses (e.g. "rastrigin:100:0.25" or
+// "repulsion:0.5:3"), and holds all of the numeric parameters as they are. It
+// can then create the fitness function, topology and Config that it
+// describes.
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso"
+	"github.com/shiblon/entrogo/pso/momentum"
+	"github.com/shiblon/entrogo/pso/rng"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/pso/tug"
+)
+
+// Random streams for everything that isn't a particle. Particles use their own
+// indices as stream numbers, so these are all negative.
+const (
+	TopologyStream = -1 - iota
+	// Tug functions draw from the particles' sources now, but this keeps the
+	// numbers of the streams after it.
+	TugStream
+	MomentumStream
+	RestartStream
+	StrategyStream
+)
+
+// Spec describes a run. Fields that a file leaves out keep their values from
+// New.
+type Spec struct {
+	Fitness   string `json:"fitness"`   // fitness function, e.g. "rastrigin:100:0.25"
+	Algorithm string `json:"algorithm"` // update algorithm, e.g. "standard" (interpreted by the caller)
+	Topology  string `json:"topology"`  // topology and swarm size, e.g. "ring:30" or "expander:30:2"
+	Evals     int    `json:"evals"`     // number of function evaluations
+	Seed      int64  `json:"seed"`      // master random seed
+
+	Momentum      string  `json:"momentum"`      // momentum schedule, e.g. "linear" or "sigmoid:0.25:10"
+	MomentumDecay float64 `json:"momentumDecay"` // decay rate for the adaptive momentum schedules
+	Tug           string  `json:"tug"`           // tug function: none, dtrunc, rtrunc, dflip, rflip, rdflip or dweight
+	Strategy      string  `json:"strategy"`      // adaptation of the coefficients: none or apso
+
+	Collisions     string `json:"collisions"`     // reflect, elastic, repulsion:strength:perception or nooverlap
+	Boundary       string `json:"boundary"`       // none, clamp, absorb, reflect, wrap, random or infinity
+	Constraints    string `json:"constraints"`    // feasibility, penalty or epsilon
+	ChangeResponse string `json:"changeResponse"` // reevaluate or rerandomize:fraction
+	Discrete       string `json:"discrete"`       // none, round or probround
+
+	// Evaluation and the other algorithms (all interpreted by the caller).
+	Workers      int     `json:"workers"`      // concurrent evaluations for the async algorithm
+	Batch        bool    `json:"batch"`        // evaluate each batch in one call
+	Cache        int     `json:"cache"`        // evaluations to remember, if positive
+	CacheQuantum float64 `json:"cacheQuantum"` // grid spacing for cache keys, or 0 for exact positions
+	Species      float64 `json:"species"`      // niching species radius, as a fraction of the domain diameter
+	Archive      int     `json:"archive"`      // maximum Pareto archive size for mopso
+
+	// Stopping and restarting (interpreted by the caller).
+	MaxTime          string  `json:"maxTime"`          // wall-clock limit, e.g. "90s", if positive
+	Stagnation       int     `json:"stagnation"`       // batches without improvement before stopping, if positive
+	Restart          string  `json:"restart"`          // restart policy: none, reinit, ipop:growth or seeds
+	RestartAfter     int     `json:"restartAfter"`     // batches without improvement that count as stagnation
+	RestartDiversity float64 `json:"restartDiversity"` // diversity below which to restart, if positive
+	Fame             int     `json:"fame"`             // number of best solutions from different restarts to report
+
+	// The numeric parameters of pso.Config.
+	DecayAdapt       float64 `json:"decayAdapt"`
+	DecayRadius      float64 `json:"decayRadius"`
+	Momentum0        float64 `json:"momentum0"`
+	Momentum1        float64 `json:"momentum1"`
+	SocConst         float64 `json:"socConst"`
+	CogConst         float64 `json:"cogConst"`
+	SocLower         float64 `json:"socLower"`
+	CogLower         float64 `json:"cogLower"`
+	BackwardAdapt    bool    `json:"backwardAdapt"`
+	VelCapMultiplier float64 `json:"velCapMultiplier"`
+	RadiusMultiplier float64 `json:"radiusMultiplier"`
+	BounceMultiplier float64 `json:"bounceMultiplier"`
+	Sentinels        int     `json:"sentinels"`
+	QuantumFraction  float64 `json:"quantumFraction"`
+	QuantumRadius    float64 `json:"quantumRadius"`
+	Concurrency      int     `json:"concurrency"`
+}
+
+// New creates a spec with the same defaults as the command line, and the
+// parameters of pso.NewBasicConfig.
+func New() *Spec {
+	s := &Spec{
+		Fitness:        "parabola:100:0.25",
+		Algorithm:      "standard",
+		Topology:       "star:5",
+		Evals:          250000,
+		Momentum:       "linear",
+		MomentumDecay:  0.9,
+		Tug:            "none",
+		Strategy:       "none",
+		Collisions:     "reflect",
+		Boundary:       "none",
+		Constraints:    "feasibility",
+		ChangeResponse: "reevaluate",
+		Discrete:       "none",
+		Workers:        runtime.NumCPU(),
+		Species:        0.1,
+		Archive:        100,
+		MaxTime:        "0s",
+		Restart:        "none",
+		RestartAfter:   50,
+		Fame:           5,
+	}
+	s.SetParams(pso.NewBasicConfig(nil))
+	return s
+}
+
+// Load reads a spec from a JSON file, on top of the defaults from New.
+func Load(path string) (*Spec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read reads a spec in JSON, on top of the defaults from New. Unknown fields
+// are an error, so that misspelled ones are not silently ignored.
+func Read(r io.Reader) (*Spec, error) {
+	s := New()
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(s); err != nil {
+		return nil, fmt.Errorf("failed to read run spec: %w", err)
+	}
+	return s, nil
+}
+
+// Save writes the spec to a JSON file.
+func (s *Spec) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := s.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write writes the spec as indented JSON.
+func (s *Spec) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// String returns the spec as JSON on a single line.
+func (s *Spec) String() string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return string(b)
+}
+
+// SetParams copies the numeric parameters of c into the spec.
+func (s *Spec) SetParams(c *pso.Config) {
+	s.DecayAdapt = c.DecayAdapt
+	s.DecayRadius = c.DecayRadius
+	s.Momentum0 = c.Momentum0
+	s.Momentum1 = c.Momentum1
+	s.SocConst = c.SocConst
+	s.CogConst = c.CogConst
+	s.SocLower = c.SocLower
+	s.CogLower = c.CogLower
+	s.BackwardAdapt = c.BackwardAdapt
+	s.VelCapMultiplier = c.VelCapMultiplier
+	s.RadiusMultiplier = c.RadiusMultiplier
+	s.BounceMultiplier = c.BounceMultiplier
+	s.Sentinels = c.Sentinels
+	s.QuantumFraction = c.QuantumFraction
+	s.QuantumRadius = c.QuantumRadius
+	s.Concurrency = c.Concurrency
+}
+
+// params copies the numeric parameters of the spec into c.
+func (s *Spec) params(c *pso.Config) {
+	c.DecayAdapt = s.DecayAdapt
+	c.DecayRadius = s.DecayRadius
+	c.Momentum0 = s.Momentum0
+	c.Momentum1 = s.Momentum1
+	c.SocConst = s.SocConst
+	c.CogConst = s.CogConst
+	c.SocLower = s.SocLower
+	c.CogLower = s.CogLower
+	c.BackwardAdapt = s.BackwardAdapt
+	c.VelCapMultiplier = s.VelCapMultiplier
+	c.RadiusMultiplier = s.RadiusMultiplier
+	c.BounceMultiplier = s.BounceMultiplier
+	c.Sentinels = s.Sentinels
+	c.QuantumFraction = s.QuantumFraction
+	c.QuantumRadius = s.QuantumRadius
+	c.Concurrency = s.Concurrency
+}
+
+// args holds the arguments of a "name:arg:arg" string, and the first error
+// from converting them, so that a whole group of them can be checked at once.
+type args struct {
+	field string
+	str   string
+	name  string
+	vals  []string
+	err   error
+}
+
+func parse(field, str string) *args {
+	parts := strings.Split(strings.TrimSpace(str), ":")
+	return &args{field: field, str: str, name: parts[0], vals: parts[1:]}
+}
+
+// has returns whether argument i is present.
+func (a *args) has(i int) bool {
+	return i < len(a.vals) && a.vals[i] != ""
+}
+
+func (a *args) get(i int) string {
+	if !a.has(i) && a.err == nil {
+		a.err = fmt.Errorf("%s %q is missing argument %d", a.field, a.str, i+1)
+	}
+	if i < len(a.vals) {
+		return a.vals[i]
+	}
+	return ""
+}
+
+func (a *args) int(i int) int {
+	v, err := strconv.Atoi(a.get(i))
+	if err != nil && a.err == nil {
+		a.err = fmt.Errorf("%s %q: argument %d: %w", a.field, a.str, i+1, err)
+	}
+	return v
+}
+
+func (a *args) float(i int) float64 {
+	v, err := strconv.ParseFloat(a.get(i), 64)
+	if err != nil && a.err == nil {
+		a.err = fmt.Errorf("%s %q: argument %d: %w", a.field, a.str, i+1, err)
+	}
+	return v
+}
+
+// unknown returns the error for a name that is not recognized.
+func (a *args) unknown() error {
+	return fmt.Errorf("unknown %s %q", a.field, a.name)
+}
+
+// Function creates the fitness function. Multi-objective functions are
+// returned as multi, and the others as f.
+func (s *Spec) Function() (f fitness.Function, multi fitness.MultiFunction, err error) {
+	a := parse("fitness function", s.Fitness)
+	switch a.name {
+	case "parabola", "sphere":
+		f = fitness.NewParabola(a.int(0), a.float(1))
+	case "rastrigin":
+		f = fitness.NewRastrigin(a.int(0), a.float(1))
+	case "rosenbrock":
+		f = fitness.NewRosenbrock(a.int(0), a.float(1))
+	case "ackley":
+		f = fitness.NewAckley(a.int(0), a.float(1))
+	case "easom":
+		f = fitness.NewEasom(a.int(0), a.float(1))
+	case "schwefel":
+		f = fitness.NewSchwefel(a.int(0), a.float(1))
+	case "dejongf4":
+		f = fitness.NewDeJongF4(a.int(0), a.float(1))
+	case "g06":
+		f = fitness.NewG06()
+	case "g08":
+		f = fitness.NewG08()
+	case "g11":
+		f = fitness.NewG11()
+	case "onemax":
+		f = fitness.NewOneMax(a.int(0))
+	case "trap":
+		f = fitness.NewTrap(a.int(0), a.int(1))
+	case "knapsack":
+		n, seed := a.int(0), a.int(1)
+		if a.err == nil {
+			f = fitness.NewRandomKnapsack(n, rand.New(rng.New(int64(seed))))
+		}
+	case "movingpeaks":
+		dims, peaks, freq, seed := a.int(0), a.int(1), a.int(2), a.int(3)
+		if a.err == nil {
+			f = fitness.NewMovingPeaks(dims, peaks, freq, rng.New(int64(seed)))
+		}
+	case "himmelblau":
+		f = fitness.NewHimmelblau()
+	case "equalmaxima":
+		f = fitness.NewEqualMaxima()
+	case "sixhumpcamel":
+		f = fitness.NewSixHumpCamel()
+	case "shubert":
+		f = fitness.NewShubert()
+	case "zdt1":
+		multi = fitness.NewZDT1(a.int(0))
+	case "zdt2":
+		multi = fitness.NewZDT2(a.int(0))
+	case "zdt3":
+		multi = fitness.NewZDT3(a.int(0))
+	default:
+		return nil, nil, a.unknown()
+	}
+	if a.err != nil {
+		return nil, nil, a.err
+	}
+	return f, multi, nil
+}
+
+// SwarmSize returns the number of particles given in the topology.
+func (s *Spec) SwarmSize() (int, error) {
+	a := parse("topology", s.Topology)
+	size := a.int(0)
+	return size, a.err
+}
+
+// NewTopology creates the topology for a swarm of the given size (which
+// restarts might change), deriving any randomness it needs from seed.
+func (s *Spec) NewTopology(size int, seed int64) (topology.Topology, error) {
+	a := parse("topology", s.Topology)
+	switch a.name {
+	case "ring":
+		return topology.NewRing(size), nil
+	case "star":
+		return topology.NewStar(size), nil
+	case "expander":
+		degree := a.int(1)
+		if a.err != nil {
+			return nil, a.err
+		}
+		return topology.NewRandomExpander(rng.Derive(seed, TopologyStream), size, degree)
+	}
+	return nil, a.unknown()
+}
+
+// NewMomentum creates the momentum schedule, with random sources derived
+// from seed. Schedules keep state, so each swarm needs its own.
+func (s *Spec) NewMomentum(seed int64) (pso.MomentumFunc, error) {
+	a := parse("momentum schedule", s.Momentum)
+	m0, m1, decay, n := s.Momentum0, s.Momentum1, s.MomentumDecay, s.Evals
+	var f pso.MomentumFunc
+	switch a.name {
+	case "constant":
+		f = func(u pso.Updater, iter int, particle int) float64 {
+			return m0
+		}
+	case "linear":
+		f = momentum.Linear(m0, m1, n)
+	case "nonlinear":
+		exponent := 1.2
+		if a.has(0) {
+			exponent = a.float(0)
+		}
+		f = momentum.NonlinearDecreasing(m0, m1, n, exponent)
+	case "sigmoid":
+		center, steepness := 0.25, 10.0
+		if a.has(0) {
+			center, steepness = a.float(0), a.float(1)
+		}
+		f = momentum.Sigmoid(m0, m1, n, center, steepness)
+	case "chaotic":
+		f = momentum.ChaoticLogistic(m0, m1, n, rng.Derive(seed, MomentumStream))
+	case "randexplore":
+		f = momentum.RandExplore(m0, m1, decay, rng.Derive(seed, MomentumStream))
+	case "randexplore2":
+		f = momentum.RandExplore2(m0, m1, decay, rng.Derive(seed, MomentumStream))
+	case "prandexplore":
+		// Per-particle schedules get a stream of their own for each particle.
+		f = momentum.ParticleRandExplore(m0, m1, decay, rng.Streams(rng.Derive(seed, MomentumStream).Int63()))
+	case "recencyexplore":
+		f = momentum.RecencyExplore(m0, m1, decay, rng.Derive(seed, MomentumStream))
+	case "precencyexplore":
+		f = momentum.ParticleRecencyExplore(m0, m1, decay, rng.Streams(rng.Derive(seed, MomentumStream).Int63()))
+	case "histweight":
+		f = momentum.HistoryWeight(m0, m1, decay)
+	default:
+		return nil, a.unknown()
+	}
+	return f, a.err
+}
+
+// statefulMomentum holds the names of momentum schedules that keep state of
+// their own between batches. Checkpoints do not save it.
+var statefulMomentum = map[string]bool{
+	"chaotic":         true,
+	"randexplore":     true,
+	"randexplore2":    true,
+	"prandexplore":    true,
+	"recencyexplore":  true,
+	"precencyexplore": true,
+	"histweight":      true,
+}
+
+// Resumable returns an error if a checkpoint of the run would leave out
+// state that the rest of it depends on, as it would for a momentum schedule
+// that adapts to the swarm.
+func (s *Spec) Resumable() error {
+	if name := parse("momentum schedule", s.Momentum).name; statefulMomentum[name] {
+		return fmt.Errorf("momentum schedule %s keeps state that checkpoints do not save", name)
+	}
+	return nil
+}
+
+// tugs maps the names of tug functions to the functions.
+var tugs = map[string]pso.TugFunc{
+	"none":    tug.None,
+	"dtrunc":  tug.Truncate,
+	"rtrunc":  tug.RandomTruncate,
+	"dflip":   tug.Flip,
+	"rflip":   tug.RandomFlip,
+	"rdflip":  tug.RandomDotWeight,
+	"dweight": tug.DotWeight,
+}
+
+// Config creates the configuration that the spec describes, for a function
+// with the given number of dimensions.
+func (s *Spec) Config(dims int) (*pso.Config, error) {
+	c := pso.NewBasicConfig(rng.Streams(s.Seed))
+	s.params(c)
+
+	var err error
+	if c.Momentum, err = s.NewMomentum(s.Seed); err != nil {
+		return nil, err
+	}
+	a := parse("tug function", s.Tug)
+	if c.Tug = tugs[a.name]; c.Tug == nil {
+		return nil, a.unknown()
+	}
+
+	switch a := parse("strategy", s.Strategy); a.name {
+	case "none":
+	case "apso":
+		c.Strategy = pso.NewAPSO(rng.Derive(s.Seed, StrategyStream), s.Evals)
+	default:
+		return nil, a.unknown()
+	}
+
+	switch a := parse("collision policy", s.Collisions); a.name {
+	case "reflect":
+		c.Collisions = pso.ReflectCollisions{}
+	case "elastic":
+		c.Collisions = pso.ElasticCollisions{}
+	case "repulsion":
+		c.Collisions = pso.RepulsionCollisions{Strength: a.float(0), Perception: a.float(1)}
+		if a.err != nil {
+			return nil, a.err
+		}
+	case "nooverlap":
+		c.Collisions = pso.NoOverlapCollisions{}
+	default:
+		return nil, a.unknown()
+	}
+
+	switch a := parse("boundary policy", s.Boundary); a.name {
+	case "none":
+		// Let particles roam freely.
+	case "clamp":
+		c.Boundary = pso.ClampBoundary
+	case "absorb":
+		c.Boundary = pso.AbsorbBoundary
+	case "reflect":
+		c.Boundary = pso.ReflectBoundary
+	case "wrap":
+		c.Boundary = pso.WrapBoundary
+	case "random":
+		c.Boundary = pso.RandomBoundary
+	case "infinity":
+		c.Boundary = pso.InfinityBoundary
+	default:
+		return nil, a.unknown()
+	}
+
+	switch a := parse("constraint handling", s.Constraints); a.name {
+	case "feasibility":
+		c.Constraints = pso.FeasibilityRules{}
+	case "penalty":
+		c.Constraints = pso.NewAdaptivePenalty()
+	case "epsilon":
+		// The epsilon level reaches zero halfway through the run.
+		c.Constraints = pso.NewEpsilonConstraint(s.Evals / 2)
+	default:
+		return nil, a.unknown()
+	}
+
+	switch a := parse("change response", s.ChangeResponse); a.name {
+	case "reevaluate":
+		c.ChangeResponse = pso.ReevaluateBests
+	case "rerandomize":
+		fraction := a.float(0)
+		if a.err != nil {
+			return nil, a.err
+		}
+		c.ChangeResponse = pso.Rerandomize(fraction)
+	default:
+		return nil, a.unknown()
+	}
+
+	switch a := parse("discretization", s.Discrete); a.name {
+	case "none":
+	case "round":
+		c.Discrete = pso.AllDiscrete(dims, pso.DiscreteRound)
+	case "probround":
+		c.Discrete = pso.AllDiscrete(dims, pso.DiscreteProbRound)
+	default:
+		return nil, a.unknown()
+	}
+	return c, nil
+}
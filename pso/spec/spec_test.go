@@ -0,0 +1,273 @@
+package spec
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/shiblon/entrogo/pso"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/topology"
+)
+
+func TestSaveLoad(t *testing.T) {
+	s := New()
+	s.Fitness = "rastrigin:10:0.25"
+	s.Topology = "expander:20:2"
+	s.Seed = 42
+	s.Momentum = "sigmoid:0.3:8"
+	s.Collisions = "repulsion:0.5:3"
+	s.SocLower = -0.5
+	s.BackwardAdapt = true
+	s.Batch = true
+	s.MaxTime = "1m30s"
+	s.Restart = "ipop:2"
+
+	path := filepath.Join(t.TempDir(), "run.json")
+	if err := s.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(s, loaded) {
+		t.Errorf("expected %v, loaded %v", s, loaded)
+	}
+
+	// The single line form reads back too.
+	read, err := Read(strings.NewReader(s.String()))
+	if err != nil || !reflect.DeepEqual(s, read) {
+		t.Errorf("expected %v, read %v (%v)", s, read, err)
+	}
+}
+
+func TestReadDefaults(t *testing.T) {
+	s, err := Read(strings.NewReader(`{"fitness": "ackley:5:0", "momentum0": 0.6}`))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := New()
+	want.Fitness = "ackley:5:0"
+	want.Momentum0 = 0.6
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("expected the missing fields to keep their defaults:\n%v\n%v", want, s)
+	}
+
+	if _, err := Read(strings.NewReader(`{"momentum_0": 0.6}`)); err == nil {
+		t.Errorf("expected an error for an unknown field")
+	}
+}
+
+func TestParams(t *testing.T) {
+	want := pso.NewBasicConfig(nil)
+	want.DecayAdapt = 0.5
+	want.SocLower = -1
+	want.BackwardAdapt = true
+	want.BounceMultiplier = 2
+	want.Sentinels = 3
+	want.Concurrency = 4
+	s := New()
+	s.SetParams(want)
+	got, err := s.Config(2)
+	if err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	// Functions cannot be compared, so only look at the rest.
+	got.NewRNG, got.Momentum, got.Tug, want.NewRNG, want.Momentum, want.Tug = nil, nil, nil, nil, nil, nil
+	got.Collisions, got.Constraints, got.ChangeResponse = nil, nil, nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected parameters to round trip:\n%+v\n%+v", want, got)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	s := New()
+	s.Momentum0, s.Momentum1, s.Evals = 0.9, 0.4, 100
+	s.Tug = "dflip"
+	s.Strategy = "apso"
+	s.Collisions = "repulsion:0.5:3"
+	s.Boundary = "clamp"
+	s.Constraints = "epsilon"
+	s.ChangeResponse = "rerandomize:0.25"
+	s.Discrete = "round"
+	c, err := s.Config(3)
+	if err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	if m := c.Momentum(nil, 50, 0); m != 0.65 {
+		t.Errorf("expected linear momentum of 0.65 halfway, got %v", m)
+	}
+	if tug := c.Tug(&particle.Particle{}, -0.5); tug != -1 {
+		t.Errorf("expected the flipping tug, got %v", tug)
+	}
+	if _, ok := c.Strategy.(*pso.APSO); !ok {
+		t.Errorf("expected an APSO strategy, got %T", c.Strategy)
+	}
+	if r, ok := c.Collisions.(pso.RepulsionCollisions); !ok || r.Strength != 0.5 || r.Perception != 3 {
+		t.Errorf("expected repulsion collisions, got %#v", c.Collisions)
+	}
+	if c.Boundary == nil || len(c.Discrete) != 3 || c.ChangeResponse == nil {
+		t.Errorf("expected a boundary, discretization and change response, got %+v", c)
+	}
+	if e, ok := c.Constraints.(*pso.EpsilonConstraint); !ok || e == nil {
+		t.Errorf("expected epsilon constraint handling, got %T", c.Constraints)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cases := []func(s *Spec){
+		func(s *Spec) { s.Momentum = "wobbly" },
+		func(s *Spec) { s.Momentum = "sigmoid:0.25" },
+		func(s *Spec) { s.Tug = "yank" },
+		func(s *Spec) { s.Strategy = "psychic" },
+		func(s *Spec) { s.Collisions = "repulsion:0.5" },
+		func(s *Spec) { s.Boundary = "fence" },
+		func(s *Spec) { s.Constraints = "lax" },
+		func(s *Spec) { s.ChangeResponse = "rerandomize:lots" },
+		func(s *Spec) { s.Discrete = "floor" },
+	}
+	for i, change := range cases {
+		s := New()
+		change(s)
+		if _, err := s.Config(2); err == nil {
+			t.Errorf("case %d: expected an error for %v", i, s)
+		}
+	}
+	for _, fit := range []string{"parabola:10", "parabola:ten:0", "nosuchfunction"} {
+		s := New()
+		s.Fitness = fit
+		if _, _, err := s.Function(); err == nil {
+			t.Errorf("expected an error for fitness function %q", fit)
+		}
+	}
+	s := New()
+	s.Topology = "torus:10"
+	if _, err := s.NewTopology(10, 1); err == nil {
+		t.Errorf("expected an error for an unknown topology")
+	}
+}
+
+func TestFunctionAndTopology(t *testing.T) {
+	s := New()
+	s.Fitness = "zdt1:30"
+	if f, multi, err := s.Function(); err != nil || f != nil || multi == nil || multi.Dims() != 30 {
+		t.Errorf("expected a 30-dimensional multi-objective function, got %v, %v, %v", f, multi, err)
+	}
+	s.Fitness = "knapsack:20:7"
+	if f, multi, err := s.Function(); err != nil || f == nil || multi != nil || f.Dims() != 20 {
+		t.Errorf("expected a 20-item knapsack, got %v, %v, %v", f, multi, err)
+	}
+
+	s.Topology = "expander:12:3"
+	size, err := s.SwarmSize()
+	if err != nil || size != 12 {
+		t.Fatalf("expected a swarm size of 12, got %d (%v)", size, err)
+	}
+	topo, err := s.NewTopology(size, 1)
+	if err != nil {
+		t.Fatalf("NewTopology: %v", err)
+	}
+	if _, ok := topo.(*topology.RandomExpander); !ok || topo.Size() != 12 {
+		t.Errorf("expected a random expander of 12, got %T of %d", topo, topo.Size())
+	}
+}
+
+func TestResumable(t *testing.T) {
+	for momentum, ok := range map[string]bool{
+		"linear":            true,
+		"sigmoid:0.3:8":     true,
+		"chaotic":           false,
+		"randexplore":       false,
+		"prandexplore":      false,
+		"precencyexplore":   false,
+		"histweight":        false,
+		"nonlinear:1.5":     true,
+		"recencyexplore:no": false,
+	} {
+		s := New()
+		s.Momentum = momentum
+		if err := s.Resumable(); (err == nil) != ok {
+			t.Errorf("%s: expected resumable to be %v, got error %v", momentum, ok, err)
+		}
+	}
+}
+
+// run runs the spec and returns its final swarm as text.
+func run(t *testing.T, s *Spec) string {
+	f, _, err := s.Function()
+	if err != nil {
+		t.Fatalf("Function: %v", err)
+	}
+	size, err := s.SwarmSize()
+	if err != nil {
+		t.Fatalf("SwarmSize: %v", err)
+	}
+	topo, err := s.NewTopology(size, s.Seed)
+	if err != nil {
+		t.Fatalf("NewTopology: %v", err)
+	}
+	c, err := s.Config(f.Dims())
+	if err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	u := pso.NewStandardPSO(topo, f, c)
+	pso.Run(context.Background(), u, pso.MaxEvals(s.Evals))
+	var out bytes.Buffer
+	for _, p := range u.Swarm() {
+		out.WriteString(p.String())
+	}
+	return out.String()
+}
+
+func TestReproducible(t *testing.T) {
+	s := New()
+	s.Fitness = "rastrigin:5:0.25"
+	s.Topology = "ring:20"
+	s.Evals = 4000
+	s.Seed = 9
+	s.Momentum = "prandexplore"
+	s.Tug = "rflip"
+	s.Collisions = "elastic"
+
+	loaded, err := Read(strings.NewReader(s.String()))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if a, b := run(t, s), run(t, loaded); a != b {
+		t.Errorf("expected a loaded spec to give the same run")
+	}
+}
+
+func TestReproducibleAcrossProcs(t *testing.T) {
+	// Particles move concurrently, so random tug functions and momentum
+	// schedules must not depend on which goroutine gets to them first.
+	for _, c := range []struct{ tug, momentum string }{
+		{"rtrunc", "randexplore"},
+		{"rflip", "randexplore2"},
+		{"rdflip", "recencyexplore"},
+		{"rflip", "prandexplore"},
+		{"rtrunc", "precencyexplore"},
+		{"rdflip", "chaotic"},
+	} {
+		s := New()
+		s.Fitness = "rastrigin:5:0.25"
+		s.Topology = "expander:20:3"
+		s.Evals = 3000
+		s.Seed = 4
+		s.Tug, s.Momentum = c.tug, c.momentum
+
+		procs := runtime.GOMAXPROCS(1)
+		serial := run(t, s)
+		runtime.GOMAXPROCS(8)
+		parallel := run(t, s)
+		runtime.GOMAXPROCS(procs)
+		if serial != parallel {
+			t.Errorf("%s/%s: expected the same run on 1 and 8 threads", c.tug, c.momentum)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package pso
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/topology"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// Reference parameters for SPSO 2011 (Clerc, "Standard Particle Swarm
+// Optimisation", 2012; Zambrano-Bigiarini et al., "Standard Particle Swarm
+// Optimisation 2011 at CEC-2013", 2013).
+const (
+	SPSO2011Size = 40
+	SPSO2011K    = 3
+)
+
+var (
+	SPSO2011W = 1 / (2 * math.Ln2)
+	SPSO2011C = 0.5 + math.Ln2
+)
+
+// SPSO2011Updater implements Standard PSO 2011, for use as a baseline. Its
+// velocity update is rotation invariant: each particle samples its next
+// position uniformly from a hypersphere around the centre of gravity of its
+// current position and two points a little beyond its personal and local
+// best. The local best comes from an adaptive random topology, which is
+// rewired whenever an iteration fails to improve the global best.
+//
+// Unlike the reference C implementation, particles are moved and evaluated
+// in synchronous batches, which keeps seeded runs reproducible. Constrained
+// functions are handled with FeasibilityRules.
+type SPSO2011Updater struct {
+	swarmBase
+
+	Topology *topology.AdaptiveRandom
+	W        float64 // inertia weight
+	C        float64 // acceleration coefficient
+
+	newRNG func(id int) rand.Source
+}
+
+// NewSPSO2011 creates an SPSO 2011 updater with reference parameters. Use
+// SPSO2011Size for the reference swarm size. Particle i gets its random source
+// from newRNG(i), and the topology gets one from newRNG(-1).
+func NewSPSO2011(f fitness.Function, size int, newRNG func(id int) rand.Source) (*SPSO2011Updater, error) {
+	topo, err := topology.NewAdaptiveRandom(newRNG(-1), size, SPSO2011K)
+	if err != nil {
+		return nil, fmt.Errorf("SPSO 2011 topology: %v", err)
+	}
+	return &SPSO2011Updater{
+		swarmBase: newSwarmBase(f),
+		Topology:  topo,
+		W:         SPSO2011W,
+		C:         SPSO2011C,
+		newRNG:    newRNG,
+	}, nil
+}
+
+// init creates particles uniformly in the domain, with velocities that would
+// take each one to another uniform point in the domain.
+func (u *SPSO2011Updater) init() int {
+	u.useConstraints(nil)
+	return u.initSwarm(u.Topology.Size(), func(i int) *particle.Particle {
+		p := particle.NewRandomParticle(u.newRNG(i), i, u.Fitness)
+		for d := range p.Vel {
+			lo, hi := u.minCorner[d]-p.Pos[d], u.maxCorner[d]-p.Pos[d]
+			p.Vel[d] = lo + p.Rand().Float64()*(hi-lo)
+		}
+		p.Scratch().Vel.Replace(p.Vel)
+		return p
+	})
+}
+
+// Update moves the swarm from one time slice to another. The first call moves
+// the swarm to t[0] by initializing it. After that it ticks the clock with each call.
+// Returns the number of function evaluations performed.
+func (u *SPSO2011Updater) Update() (evals int) {
+	u.beginBatch()
+	defer func() { u.endBatch(evals) }()
+	if !u.Initialized() {
+		defer u.countBatch(true)
+		return u.init()
+	}
+
+	prev := u.BestParticle()
+	prevVal, prevViol := prev.BestVal, prev.BestViolation
+	u.moveAll(u.moveOneParticle)
+	evals, improved := u.evaluateAll(nil)
+
+	u.countBatch(improved)
+	if best := u.BestParticle(); !u.LessFit(prevVal, prevViol, best.BestVal, best.BestViolation) {
+		u.Topology.Rewire()
+	}
+	return evals
+}
+
+func (u *SPSO2011Updater) topoLessFit(a, b int) bool {
+	return u.lessFitBest(u.swarm[a], u.swarm[b])
+}
+
+func (u *SPSO2011Updater) moveOneParticle(pidx int) {
+	p := u.swarm[pidx]
+	informer := u.Topology.BestNeighbor(pidx, u.topoLessFit)
+
+	// Centre of gravity of x, p' = x + c*U*(p-x) and l' = x + c*U*(l-x),
+	// where U is uniform in [0, 1] for each dimension. When the particle is its
+	// own best informer, l' is left out.
+	dims := len(p.Pos)
+	toPersonal := p.BestPos.Sub(p.Pos).MulBy(vec.NewFFilled(dims, p.Rand().Float64)).SMulBy(u.C)
+	center := p.Pos.Copy()
+	if informer == pidx {
+		center.AddBy(toPersonal.SMulBy(0.5))
+	} else {
+		toLocal := u.swarm[informer].BestPos.Sub(p.Pos).MulBy(vec.NewFFilled(dims, p.Rand().Float64)).SMulBy(u.C)
+		center.AddBy(toPersonal.AddBy(toLocal).SMulBy(1.0 / 3.0))
+	}
+
+	radius := center.Sub(p.Pos).Mag()
+	target := sampleSphere(center, radius, p.Rand())
+
+	scratch := p.Scratch()
+	scratch.Vel.Replace(p.Vel).SMulBy(u.W).AddBy(target.SubBy(p.Pos))
+	scratch.Pos.Replace(p.Pos).AddBy(scratch.Vel)
+	scratch.Bounced = false
+	scratch.WallHit = false
+
+	// Confinement: stop at the wall and send the particle back at half speed.
+	for d, x := range scratch.Pos {
+		if x < u.minCorner[d] {
+			scratch.Pos[d] = u.minCorner[d]
+		} else if x > u.maxCorner[d] {
+			scratch.Pos[d] = u.maxCorner[d]
+		} else {
+			continue
+		}
+		scratch.Vel[d] *= -0.5
+		scratch.WallHit = true
+	}
+}
+
+// sampleSphere returns a point drawn uniformly from the volume of the
+// hypersphere with the given center and radius.
+func sampleSphere(center vec.Vec, radius float64, rgen *rand.Rand) vec.Vec {
+	dir := vec.NewFFilled(len(center), rgen.NormFloat64)
+	mag := dir.Mag()
+	if mag == 0 {
+		return center.Copy()
+	}
+	r := radius * math.Pow(rgen.Float64(), 1/float64(len(center)))
+	return dir.SMulBy(r / mag).AddBy(center)
+}
@@ -0,0 +1,43 @@
+package pso
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/rng"
+)
+
+// These budgets and thresholds are loose versions of those reported for SPSO
+// 2011 on the same functions (unimodal functions are solved to near machine
+// precision, Rastrigin gets stuck in a local optimum of modest value).
+func TestSPSO2011Benchmarks(t *testing.T) {
+	tests := []struct {
+		name  string
+		f     fitness.Function
+		evals int
+		want  float64
+	}{
+		{"parabola", fitness.NewParabola(10, 0.25), 20000, 1e-6},
+		{"ackley", fitness.NewAckley(10, 0.1), 40000, 1e-8},
+		{"rastrigin", fitness.NewRastrigin(10, 0.1), 40000, 30},
+	}
+	for _, test := range tests {
+		u, err := NewSPSO2011(test.f, SPSO2011Size, rng.Streams(2011))
+		if err != nil {
+			t.Fatalf("Failed to create SPSO 2011 updater: %v", err)
+		}
+		res := Run(context.Background(), u, MaxEvals(test.evals))
+		t.Logf("%s: %v after %d evals", test.name, res.BestVal, res.Evals)
+		if res.BestVal > test.want {
+			t.Errorf("%s: expected best <= %v after %d evals, got %v", test.name, test.want, res.Evals, res.BestVal)
+		}
+		for _, p := range u.Swarm() {
+			for d, x := range p.Pos {
+				if x < u.minCorner[d] || x > u.maxCorner[d] {
+					t.Fatalf("%s: particle %d left the domain: %v", test.name, p.Id, p.Pos)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package pso
+
+import (
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/vec"
+)
+
+// swarmCore holds the swarm and the bookkeeping that all of the updaters in
+// this package share, whatever kind of function they optimize: evaluation and
+// batch counters, the evaluation pool, the observer, and the basic Updater
+// accessors.
+type swarmCore struct {
+	pool          *evalPool
+	obs           *observation
+	swarm         []*particle.Particle
+	initialized   bool
+	minCorner     vec.Vec
+	maxCorner     vec.Vec
+	totalEvals    int
+	totalBatches  int
+	totalImproved int
+}
+
+func newSwarmCore(d fitness.Domain) swarmCore {
+	c := swarmCore{
+		pool: &evalPool{},
+		obs:  &observation{},
+	}
+	c.minCorner, c.maxCorner = d.Bounds()
+	return c
+}
+
+// swarmBase adds to swarmCore what the updaters of single-objective functions
+// share: domain information, constraint handling, and evaluation of the whole
+// swarm.
+type swarmBase struct {
+	swarmCore
+
+	Fitness fitness.Function
+
+	batch          fitness.BatchFunction       // nil unless the function evaluates batches
+	constrained    fitness.ConstrainedFunction // nil unless constraints are in use
+	constraints    ConstraintHandler
+	domainDiameter float64
+	worst          float64
+}
+
+func newSwarmBase(f fitness.Function) swarmBase {
+	b := swarmBase{
+		swarmCore:      newSwarmCore(f),
+		Fitness:        f,
+		domainDiameter: f.Diameter(),
+		worst:          worstVal(f),
+	}
+	b.batch, _ = f.(fitness.BatchFunction)
+	return b
+}
+
+// Initialized returns true if the swarm has reached t0 and the initial states
+// have been evaluated for fitness.
+func (b *swarmCore) Initialized() bool {
+	return b.initialized
+}
+
+// Swarm returns a list of particles.
+func (b *swarmCore) Swarm() []*particle.Particle {
+	return b.swarm
+}
+
+// BestParticle returns the particle with the fittest BestVal (taking
+// constraints into account, if they are in use).
+func (b *swarmBase) BestParticle() *particle.Particle {
+	best := b.swarm[0]
+	for _, p := range b.swarm[1:] {
+		if b.lessFitBest(best, p) {
+			best = p
+		}
+	}
+	return best
+}
+
+// Batches returns the number of improved batches and the total batches.
+func (b *swarmCore) Batches() (improved, total int) {
+	return b.totalImproved, b.totalBatches
+}
+
+// countBatch counts a finished batch, and whether it improved the best
+// particle. Updaters call it at the very end of Update, so that Batches()
+// gives momentum schedules and observers the same answer for a whole batch.
+func (b *swarmCore) countBatch(improved bool) {
+	b.totalBatches++
+	if improved {
+		b.totalImproved++
+	}
+}
+
+// Evals returns the total number of function evaluations so far.
+func (b *swarmCore) Evals() int {
+	return b.totalEvals
+}
+
+// iterations returns the number of evaluations since the swarm was
+// initialized, which is what momentum schedules are given. Evals counts the
+// initial evaluation of the swarm, so that it agrees with Run, but momentum
+// schedules have always counted from after it.
+func (b *swarmCore) iterations() int {
+	return b.totalEvals - len(b.swarm)
+}
+
+// EvalStats returns the timing of all fitness evaluations so far.
+func (b *swarmCore) EvalStats() EvalStats {
+	return b.pool.Stats()
+}
+
+// Close stops the goroutines that evaluate the fitness function. They are
+// kept between batches, so Close should be called once the updater is no
+// longer needed. A later call to Update starts them again.
+func (b *swarmCore) Close() {
+	b.pool.close()
+}
+
+// limitConcurrency sets the maximum number of concurrent fitness evaluations,
+// where 0 means no limit.
+func (b *swarmCore) limitConcurrency(n int) {
+	b.pool.limit = n
+}
+
+// query evaluates the fitness function at pos, and times it.
+func (b *swarmBase) query(pos vec.Vec) (val float64) {
+	b.pool.timed(1, func() { val = b.Fitness.Query(pos) })
+	return val
+}
+
+// queryBatch evaluates the batch function at every position in one call, and
+// times it.
+func (b *swarmBase) queryBatch(pos []vec.Vec) (vals []float64) {
+	b.pool.timed(len(pos), func() { vals = b.batch.QueryBatch(pos) })
+	return vals
+}
+
+// queryAll evaluates the fitness function at every position, in one batch if
+// the function supports it or using the evaluation pool otherwise.
+func (b *swarmBase) queryAll(pos []vec.Vec) []float64 {
+	if len(pos) == 0 {
+		return nil
+	}
+	if b.batch != nil {
+		return b.queryBatch(pos)
+	}
+	vals := make([]float64, len(pos))
+	b.pool.run(len(pos), func(i int) {
+		vals[i] = b.query(pos[i])
+	})
+	return vals
+}
+
+// useConstraints turns on constraint handling with the given handler if the
+// fitness function has constraints. A nil handler means FeasibilityRules.
+func (b *swarmBase) useConstraints(h ConstraintHandler) {
+	cf, ok := b.Fitness.(fitness.ConstrainedFunction)
+	if !ok {
+		return
+	}
+	if h == nil {
+		h = FeasibilityRules{}
+	}
+	b.constrained = cf
+	b.constraints = h
+}
+
+// LessFit returns true if value a with violation aViol is less fit than value
+// c with violation cViol, using the updater's constraint handler. Violations
+// are ignored without constraints.
+func (b *swarmBase) LessFit(a, aViol, c, cViol float64) bool {
+	if b.constraints == nil {
+		return b.Fitness.LessFit(a, c)
+	}
+	return b.constraints.LessFit(b.Fitness, a, aViol, c, cViol)
+}
+
+// lessFitBest compares the personal bests of two particles.
+func (b *swarmBase) lessFitBest(p, q *particle.Particle) bool {
+	return b.LessFit(p.BestVal, p.BestViolation, q.BestVal, q.BestViolation)
+}
+
+// violation returns the total constraint violation at pos, or zero without
+// constraints.
+func (b *swarmBase) violation(pos vec.Vec) float64 {
+	if b.constrained == nil {
+		return 0
+	}
+	return fitness.TotalViolation(b.constrained.Violations(pos))
+}
+
+// updateConstraints lets the constraint handler, if any, adapt to the swarm
+// after a batch.
+func (b *swarmBase) updateConstraints() {
+	if b.constraints != nil {
+		b.constraints.Update(b.Fitness, b.swarm, b.totalEvals)
+	}
+}
+
+// initSwarm creates size particles using newParticle (called in index order,
+// so that random streams are assigned reproducibly), then evaluates all of
+// them in one batch if the function supports it, or using the evaluation pool
+// otherwise. Returns the number of function evaluations needed.
+func (b *swarmBase) initSwarm(size int, newParticle func(i int) *particle.Particle) int {
+	b.swarm = make([]*particle.Particle, size)
+	for i := range b.swarm {
+		b.swarm[i] = newParticle(i)
+	}
+
+	var vals []float64
+	if b.batch != nil {
+		pos := make([]vec.Vec, len(b.swarm))
+		for i, p := range b.swarm {
+			pos[i] = p.Pos
+		}
+		vals = b.queryBatch(pos)
+	}
+	b.pool.run(len(b.swarm), func(i int) {
+		p := b.swarm[i]
+		if vals != nil {
+			p.ResetVal(vals[i])
+		} else {
+			p.ResetVal(b.query(p.Pos))
+		}
+		p.ResetViolation(b.violation(p.Pos))
+	})
+	for _, p := range b.swarm {
+		b.observeEval(p, true)
+	}
+
+	b.initialized = true
+	b.totalEvals += len(b.swarm)
+	return len(b.swarm)
+}
+
+// evaluateAll evaluates every particle's scratch position, in one batch if
+// the function supports it or using the evaluation pool otherwise, then makes
+// it current and updates personal bests. If confine is not nil, it is called
+// on each particle first, and particles for which it returns false are not
+// evaluated (they get the worst possible value and never become a personal
+// best). Returns the number of evaluations and whether any best improved.
+func (b *swarmBase) evaluateAll(confine func(p *particle.Particle) bool) (evals int, improved bool) {
+	type evalResult struct {
+		evals    int
+		improved bool
+	}
+	results := make([]evalResult, len(b.swarm))
+
+	// confineOne decides whether the particle is evaluated.
+	confineOne := func(pidx int) {
+		p := b.swarm[pidx]
+		if confine == nil || confine(p) {
+			results[pidx].evals = 1
+		} else {
+			p.Scratch().Val = b.worst
+			p.Scratch().Violation = 0
+		}
+	}
+	// finishOne completes the move once the particle's value is known.
+	finishOne := func(pidx int) {
+		p := b.swarm[pidx]
+		res := &results[pidx]
+		if res.evals > 0 {
+			p.Scratch().Violation = b.violation(p.Scratch().Pos)
+		}
+		p.UpdateCur()
+		if res.evals > 0 && b.LessFit(p.BestVal, p.BestViolation, p.Val, p.Violation) {
+			p.UpdateBest()
+			res.improved = true
+		}
+	}
+
+	if b.batch != nil {
+		b.pool.run(len(b.swarm), confineOne)
+		var idx []int
+		var pos []vec.Vec
+		for pidx, res := range results {
+			if res.evals > 0 {
+				idx = append(idx, pidx)
+				pos = append(pos, b.swarm[pidx].Scratch().Pos)
+			}
+		}
+		if len(pos) > 0 {
+			for i, val := range b.queryBatch(pos) {
+				b.swarm[idx[i]].Scratch().Val = val
+			}
+		}
+		b.pool.run(len(b.swarm), finishOne)
+	} else {
+		b.pool.run(len(b.swarm), func(pidx int) {
+			confineOne(pidx)
+			if results[pidx].evals > 0 {
+				b.swarm[pidx].Scratch().Val = b.query(b.swarm[pidx].Scratch().Pos)
+			}
+			finishOne(pidx)
+		})
+	}
+
+	for pidx, res := range results {
+		if res.improved {
+			improved = true
+		}
+		if res.evals > 0 {
+			b.observeEval(b.swarm[pidx], res.improved)
+		}
+		evals += res.evals
+	}
+
+	b.totalEvals += evals
+	return evals, improved
+}
+
+// moveAll calls move for every particle index concurrently, and waits for all
+// of them to finish.
+func (b *swarmCore) moveAll(move func(pidx int)) {
+	done := make(chan bool, len(b.swarm))
+	for pidx := range b.swarm {
+		go func(pidx int) {
+			move(pidx)
+			done <- true
+		}(pidx)
+	}
+
+	// Wait for them to finish.
+	for _ = range b.swarm {
+		<-done
+	}
+}
+
+// confiner returns a function that applies the boundary policy and then the
+// per-dimension discretization to a particle's scratch state, and reports
+// whether the new position should be evaluated, suitable for evaluateAll. It
+// returns nil if there is nothing to do.
+func (b *swarmBase) confiner(policy BoundaryPolicy, modes []Discretization) func(p *particle.Particle) bool {
+	if policy == nil && len(modes) == 0 {
+		return nil
+	}
+	return func(p *particle.Particle) bool {
+		scratch := p.Scratch()
+		evaluate := true
+		if policy != nil {
+			var hits int
+			hits, evaluate = policy(scratch, b.minCorner, b.maxCorner, p.Rand())
+			scratch.WallHit = hits > 0
+		}
+		discretize(scratch.Pos, modes, p.Rand())
+		return evaluate
+	}
+}
+
+// capVelocity limits each component of the velocity to the given fraction of
+// the corresponding domain side length.
+func (b *swarmBase) capVelocity(vel vec.Vec, fraction float64) {
+	capVelocity(vel, b.Fitness.SideLengths(), fraction)
+}
+
+// capVelocity limits each component of the velocity to the given fraction of
+// the corresponding side length.
+func capVelocity(vel, sideLengths vec.Vec, fraction float64) {
+	for i, v := range vel {
+		mv := fraction * sideLengths[i]
+		if v > mv {
+			vel[i] = mv
+		} else if v < -mv {
+			vel[i] = -mv
+		}
+	}
+}
@@ -4,10 +4,17 @@
 package topology
 
 import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
 	"fmt"
 	"log"
 	"math/rand"
+	"sort"
 	"sync"
+
+	"github.com/shiblon/entrogo/pso/grid"
+	"github.com/shiblon/entrogo/vec"
 )
 
 // LessFit is a fitness comparator function that operates on particle indices.
@@ -30,6 +37,11 @@ type Topology interface {
 
 	// BestNeighbor returns the index of the best neighbor, given a suitable lessFit function.
 	BestNeighbor(i int, lessFit LessFit) int
+
+	// Neighbors returns the indices of all particles in the neighborhood of
+	// the particle at index i, as of the last tick. The returned slice belongs
+	// to the caller.
+	Neighbors(i int) []int
 }
 
 // Star graph.
@@ -92,6 +104,17 @@ func (t *Star) BestNeighbor(i int, lessFit LessFit) int {
 	return t.best
 }
 
+// Neighbors returns every particle except i.
+func (t *Star) Neighbors(i int) []int {
+	nbrs := make([]int, 0, t.num-1)
+	for n := 0; n < t.num; n++ {
+		if n != i {
+			nbrs = append(nbrs, n)
+		}
+	}
+	return nbrs
+}
+
 // Ring graph.
 type Ring struct {
 	num int
@@ -123,14 +146,28 @@ func (t *Ring) BestNeighbor(i int, lessFit LessFit) int {
 	return best
 }
 
-// RandomExpander changes the connections between particles randomly every time
-// it's asked for a best neighbor..
+// Neighbors returns the particles on either side of i.
+func (t *Ring) Neighbors(i int) []int {
+	nbrs := []int{(i + 1) % t.num}
+	if t.num >= 3 {
+		nbrs = append(nbrs, (t.num+i-1)%t.num)
+	}
+	return nbrs
+}
+
+// RandomExpander changes the connections between particles randomly every
+// tick. Each particle gets "degree" random neighbors (not including itself,
+// possibly with repeats), drawn in index order from a single random source, so
+// the graph is reproducible for a given source no matter which particles ask
+// about their neighbors first.
 type RandomExpander struct {
 	num    int
 	degree int
-	// rand contains indices in [0, num-1) (to use, add 1 if >= self)
-	// This is necessary because 'rand.X' is stateful, and therefore not parallel.
-	rand chan int
+
+	mu        sync.Mutex
+	rsrc      rand.Source
+	rgen      *rand.Rand
+	neighbors [][]int
 }
 
 // NewRandomExpander creates a new random expander graph. The degree must be less than the number of particles and greater than zero.
@@ -141,24 +178,39 @@ func NewRandomExpander(rsrc rand.Source, numParticles, degree int) (*RandomExpan
 		return nil, fmt.Errorf("RandomExpander out-bound edges <= 0: %d", degree)
 	}
 
-	// Create the random channel and start populating it.
-	randchan := make(chan int, degree)
-	go func() {
-		r := rand.New(rsrc)
-		for {
-			randchan <- r.Intn(numParticles - 1)
-		}
-	}()
+	t := &RandomExpander{
+		num:       numParticles,
+		degree:    degree,
+		rsrc:      rsrc,
+		rgen:      rand.New(rsrc),
+		neighbors: make([][]int, numParticles),
+	}
+	for i := range t.neighbors {
+		t.neighbors[i] = make([]int, degree)
+	}
+	t.connect()
+	return t, nil
+}
 
-	return &RandomExpander{
-		num:    numParticles,
-		degree: degree,
-		rand:   randchan,
-	}, nil
+// connect draws a fresh set of neighbors for every particle.
+func (t *RandomExpander) connect() {
+	for self, nbrs := range t.neighbors {
+		for i := range nbrs {
+			// Draw from [0, num-1) and skip over self.
+			v := t.rgen.Intn(t.num - 1)
+			if v >= self {
+				v++
+			}
+			nbrs[i] = v
+		}
+	}
 }
 
-// Tick does nothing, since Ring is a static topology.
+// Tick rewires the graph.
 func (t *RandomExpander) Tick() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.connect()
 }
 
 // Size returns the number of particles in the swarm.
@@ -166,22 +218,280 @@ func (t *RandomExpander) Size() int {
 	return t.num
 }
 
-func (t *RandomExpander) randIndex(self int) int {
-	v := <-t.rand
-	if v >= self {
-		v++
+// BestNeighbor returns the most fit particle in the neighborhood of the particle at index i.
+func (t *RandomExpander) BestNeighbor(p int, lessFit LessFit) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	nbrs := t.neighbors[p]
+	best := nbrs[0]
+	for _, n := range nbrs[1:] {
+		if lessFit(best, n) {
+			best = n
+		}
 	}
-	return v
+	return best
 }
 
-// BestNeighbor returns the most fit particle in the neighborhood of the particle at index i.
-func (t *RandomExpander) BestNeighbor(p int, lessFit LessFit) int {
-	best := t.randIndex(p)
-	for i := 1; i < t.degree; i++ {
-		n := t.randIndex(p)
+// Neighbors returns the current random neighbors of p. Since neighbors are
+// drawn with replacement, there can be repeats.
+func (t *RandomExpander) Neighbors(p int) []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]int(nil), t.neighbors[p]...)
+}
+
+// expanderState is the encoded form of a RandomExpander.
+type expanderState struct {
+	RNG       []byte
+	Neighbors [][]int
+}
+
+// MarshalBinary encodes the current graph and random state, provided that the
+// random source can itself be marshaled.
+func (t *RandomExpander) MarshalBinary() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m, ok := t.rsrc.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("RandomExpander random source %T cannot be marshaled", t.rsrc)
+	}
+	rs, err := m.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(expanderState{RNG: rs, Neighbors: t.neighbors}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary restores a state produced by MarshalBinary. The number of
+// particles and the degree must match.
+func (t *RandomExpander) UnmarshalBinary(b []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var state expanderState
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&state); err != nil {
+		return err
+	}
+	if len(state.Neighbors) != t.num {
+		return fmt.Errorf("RandomExpander state has %d particles, want %d", len(state.Neighbors), t.num)
+	}
+	for _, nbrs := range state.Neighbors {
+		if len(nbrs) != t.degree {
+			return fmt.Errorf("RandomExpander state has degree %d, want %d", len(nbrs), t.degree)
+		}
+	}
+	u, ok := t.rsrc.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return fmt.Errorf("RandomExpander random source %T cannot be unmarshaled", t.rsrc)
+	}
+	if err := u.UnmarshalBinary(state.RNG); err != nil {
+		return err
+	}
+	t.neighbors = state.Neighbors
+	return nil
+}
+
+// AdaptiveRandom is the adaptive random topology of SPSO 2011. Every particle
+// informs itself and k others chosen at random (with replacement), so the
+// number of informers per particle varies. The graph only changes when Rewire
+// is called, which is normally done after an iteration that did not improve
+// the global best.
+type AdaptiveRandom struct {
+	num int
+	k   int
+
+	mu        sync.Mutex
+	rgen      *rand.Rand
+	informers [][]int // informers[i] lists the particles that inform i, including i.
+}
+
+// NewAdaptiveRandom creates an adaptive random topology with k informed
+// particles per informer. The number of particles and k must be positive.
+func NewAdaptiveRandom(rsrc rand.Source, numParticles, k int) (*AdaptiveRandom, error) {
+	if numParticles <= 0 {
+		return nil, fmt.Errorf("AdaptiveRandom needs particles: %d", numParticles)
+	} else if k <= 0 {
+		return nil, fmt.Errorf("AdaptiveRandom informed particles <= 0: %d", k)
+	}
+	t := &AdaptiveRandom{
+		num:  numParticles,
+		k:    k,
+		rgen: rand.New(rsrc),
+	}
+	t.Rewire()
+	return t, nil
+}
+
+// Rewire draws a new random graph.
+func (t *AdaptiveRandom) Rewire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.informers = make([][]int, t.num)
+	for i := range t.informers {
+		t.informers[i] = append(t.informers[i], i)
+	}
+	for i := 0; i < t.num; i++ {
+		for j := 0; j < t.k; j++ {
+			n := t.rgen.Intn(t.num)
+			if n != i {
+				t.informers[n] = append(t.informers[n], i)
+			}
+		}
+	}
+}
+
+// Tick does nothing; the graph only changes on Rewire.
+func (t *AdaptiveRandom) Tick() {
+}
+
+// Size returns the number of particles in the swarm.
+func (t *AdaptiveRandom) Size() int {
+	return t.num
+}
+
+// BestNeighbor returns the most fit informer of the particle at index i. Since
+// every particle informs itself, this can be i.
+func (t *AdaptiveRandom) BestNeighbor(i int, lessFit LessFit) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	best := i
+	for _, n := range t.informers[i] {
 		if lessFit(best, n) {
 			best = n
 		}
 	}
 	return best
 }
+
+// Neighbors returns the informers of particle i, which always include i
+// itself.
+func (t *AdaptiveRandom) Neighbors(i int) []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]int(nil), t.informers[i]...)
+}
+
+// Species is the dynamic topology of species-based PSO (Li, "Adaptively
+// choosing neighbourhood bests using species in a particle swarm optimizer for
+// multimodal function optimization", 2004). After every tick, particles are
+// visited from most to least fit: each one joins the species of the first
+// seed within Radius of it, or else becomes the seed of a new species. A
+// particle's best neighbor is always its species seed, so species converge on
+// separate optima instead of all following the global best.
+//
+// Like Star, the species are computed lazily, on the first call to
+// BestNeighbor after a tick, since that is when a fitness comparison is
+// available.
+type Species struct {
+	num    int
+	Radius float64
+
+	mu      sync.Mutex
+	pos     func(i int) vec.Vec
+	ready   bool
+	seeds   []int // seeds from most to least fit
+	species []int // species[i] is the seed of particle i's species
+}
+
+// NewSpecies creates a species topology of the given radius. Particle
+// positions come from pos, which would normally return each particle's
+// personal best position.
+func NewSpecies(numParticles int, radius float64, pos func(i int) vec.Vec) *Species {
+	return &Species{
+		num:    numParticles,
+		Radius: radius,
+		pos:    pos,
+	}
+}
+
+// Size returns the number of particles in the swarm.
+func (t *Species) Size() int {
+	return t.num
+}
+
+// Tick marks the species as out of date.
+func (t *Species) Tick() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ready = false
+}
+
+// speciate assigns every particle to a species. Seeds go into a grid as they
+// are found, so each particle is only compared with the seeds near it. The
+// caller must hold the lock.
+func (t *Species) speciate(lessFit LessFit) {
+	order := make([]int, t.num)
+	pos := make([]vec.Vec, t.num)
+	for i := range order {
+		order[i] = i
+		pos[i] = t.pos(i)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return lessFit(order[b], order[a])
+	})
+	seedGrid := grid.New(pos, t.Radius)
+	rank := make([]int, t.num) // position of each seed in t.seeds
+	t.seeds = t.seeds[:0]
+	t.species = make([]int, t.num)
+	for _, i := range order {
+		t.species[i] = i
+		near := seedGrid.Near(pos[i], -1, func(s int) bool {
+			return pos[i].Dist(pos[s]) <= t.Radius
+		})
+		for _, s := range near {
+			// The fittest seed in range wins.
+			if t.species[i] == i || rank[s] < rank[t.species[i]] {
+				t.species[i] = s
+			}
+		}
+		if t.species[i] == i {
+			rank[i] = len(t.seeds)
+			t.seeds = append(t.seeds, i)
+			seedGrid.Insert(i, pos[i])
+		}
+	}
+	t.ready = true
+}
+
+// BestNeighbor returns the seed of the species of the particle at index i,
+// which can be i itself.
+func (t *Species) BestNeighbor(i int, lessFit LessFit) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.ready {
+		t.speciate(lessFit)
+	}
+	return t.species[i]
+}
+
+// Neighbors returns the other members of particle i's species, as of the last
+// time species were computed. It is empty if species have never been computed.
+func (t *Species) Neighbors(i int) []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var nbrs []int
+	if t.species == nil {
+		return nbrs
+	}
+	for j, s := range t.species {
+		if j != i && s == t.species[i] {
+			nbrs = append(nbrs, j)
+		}
+	}
+	return nbrs
+}
+
+// Seeds returns the species seeds from most to least fit, along with the
+// seed of every particle's species, computing species first if they are out
+// of date. The returned slices belong to the caller.
+func (t *Species) Seeds(lessFit LessFit) (seeds, species []int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.ready {
+		t.speciate(lessFit)
+	}
+	return append([]int(nil), t.seeds...), append([]int(nil), t.species...)
+}
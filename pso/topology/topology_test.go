@@ -0,0 +1,90 @@
+package topology
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/shiblon/entrogo/vec"
+)
+
+func ExampleRing_Neighbors() {
+	r := NewRing(5)
+	fmt.Println(r.Neighbors(0), r.Neighbors(2), r.Neighbors(4))
+
+	// Output:
+	// [1 4] [3 1] [0 3]
+}
+
+func ExampleStar_Neighbors() {
+	fmt.Println(NewStar(4).Neighbors(2))
+
+	// Output:
+	// [0 1 3]
+}
+
+func TestNeighborsAgreeWithBestNeighbor(t *testing.T) {
+	expander, err := NewRandomExpander(rand.NewSource(1), 10, 3)
+	if err != nil {
+		t.Fatalf("Failed to create expander: %v", err)
+	}
+	adaptive, err := NewAdaptiveRandom(rand.NewSource(1), 10, 3)
+	if err != nil {
+		t.Fatalf("Failed to create adaptive random topology: %v", err)
+	}
+	vals := []float64{5, 3, 8, 1, 9, 2, 7, 4, 6, 0}
+	lessFit := func(a, b int) bool { return vals[b] < vals[a] }
+	for _, topo := range []Topology{NewStar(10), NewRing(10), expander, adaptive} {
+		for i := 0; i < topo.Size(); i++ {
+			nbrs := topo.Neighbors(i)
+			sort.Slice(nbrs, func(a, b int) bool { return lessFit(nbrs[b], nbrs[a]) })
+			if best := topo.BestNeighbor(i, lessFit); vals[best] != vals[nbrs[0]] {
+				t.Errorf("%T particle %d: best neighbor %d is not the best of %v", topo, i, best, nbrs)
+			}
+		}
+		topo.Tick()
+	}
+}
+
+func TestSpeciesMatchesPairwise(t *testing.T) {
+	rgen := rand.New(rand.NewSource(2))
+	for _, dims := range []int{1, 2, 6} {
+		for _, radius := range []float64{0, 0.05, 0.3, 5} {
+			pos := make([]vec.Vec, 200)
+			vals := make([]float64, len(pos))
+			for i := range pos {
+				pos[i] = vec.NewFFilled(dims, rgen.Float64)
+				vals[i] = rgen.Float64()
+			}
+			lessFit := func(a, b int) bool { return vals[b] < vals[a] }
+			seeds, species := NewSpecies(len(pos), radius, func(i int) vec.Vec { return pos[i] }).Seeds(lessFit)
+
+			// Every particle joins the fittest seed in range, if there is one.
+			order := make([]int, len(pos))
+			for i := range order {
+				order[i] = i
+			}
+			sort.SliceStable(order, func(a, b int) bool { return lessFit(order[b], order[a]) })
+			var wantSeeds []int
+			for _, i := range order {
+				want := i
+				for _, s := range wantSeeds {
+					if pos[i].Dist(pos[s]) <= radius {
+						want = s
+						break
+					}
+				}
+				if want == i {
+					wantSeeds = append(wantSeeds, i)
+				}
+				if species[i] != want {
+					t.Fatalf("dims %d, radius %v: particle %d joined species %d, expected %d", dims, radius, i, species[i], want)
+				}
+			}
+			if fmt.Sprint(seeds) != fmt.Sprint(wantSeeds) {
+				t.Errorf("dims %d, radius %v: expected seeds %v, got %v", dims, radius, wantSeeds, seeds)
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package tug provides functions for pso.Config.Tug, which scale a particle's
+// momentum by its "tug": the dot product of its normalized velocity with its
+// normalized acceleration. A tug near 1 means that the particle is already
+// going where the evidence pulls it, and a tug near -1 means that its momentum
+// is carrying it away.
+//
+// The random functions draw from the particle's own Rand(), so a run with a
+// fixed seed gives the same results however its particles are scheduled.
+package tug
+
+import (
+	"math"
+
+	"github.com/shiblon/entrogo/pso/particle"
+)
+
+// None leaves momentum alone.
+func None(p *particle.Particle, dot float64) float64 {
+	return 1.0
+}
+
+// Truncate drops momentum when the particle is pulled backward.
+func Truncate(p *particle.Particle, dot float64) float64 {
+	if dot < 0 {
+		return 0.0
+	}
+	return 1.0
+}
+
+// RandomTruncate drops momentum when the particle is pulled backward, with a
+// probability of how strongly it is pulled (-dot).
+func RandomTruncate(p *particle.Particle, dot float64) float64 {
+	if dot < 0 && p.Rand().Float64() <= math.Abs(dot) {
+		return 0.0
+	}
+	return 1.0
+}
+
+// Flip reverses momentum when the particle is pulled backward.
+func Flip(p *particle.Particle, dot float64) float64 {
+	if dot < 0 {
+		return -1.0
+	}
+	return 1.0
+}
+
+// RandomFlip reverses momentum when the particle is pulled backward, with a
+// probability of how strongly it is pulled (-dot).
+func RandomFlip(p *particle.Particle, dot float64) float64 {
+	if dot < 0 && p.Rand().Float64() <= -dot {
+		return -1.0
+	}
+	return 1.0
+}
+
+// DotWeight multiplies momentum by the tug, so that it is kept in full only
+// when the particle is pulled straight ahead, and reversed in full when it is
+// pulled straight back.
+func DotWeight(p *particle.Particle, dot float64) float64 {
+	return dot
+}
+
+// RandomDotWeight multiplies momentum by the tug, or by its opposite with a
+// probability of 1-|dot|. A strong pull either way is usually kept, while a
+// weak, sideways one has its direction flipped as often as not.
+func RandomDotWeight(p *particle.Particle, dot float64) float64 {
+	if p.Rand().Float64() > math.Abs(dot) {
+		return -dot
+	}
+	return dot
+}
@@ -0,0 +1,80 @@
+package tug
+
+import (
+	"math"
+	"testing"
+
+	"github.com/shiblon/entrogo/fitness"
+	"github.com/shiblon/entrogo/pso"
+	"github.com/shiblon/entrogo/pso/particle"
+	"github.com/shiblon/entrogo/pso/rng"
+)
+
+func newParticle(seed int64) *particle.Particle {
+	return particle.NewRandomParticle(rng.New(seed), 0, fitness.NewParabola(2, 0))
+}
+
+func TestDeterministic(t *testing.T) {
+	p := newParticle(1)
+	cases := []struct {
+		name string
+		f    pso.TugFunc
+		want map[float64]float64 // result for each dot product
+	}{
+		{"none", None, map[float64]float64{-1: 1, -0.5: 1, 0: 1, 0.5: 1, 1: 1}},
+		{"truncate", Truncate, map[float64]float64{-1: 0, -0.5: 0, 0: 1, 0.5: 1, 1: 1}},
+		{"flip", Flip, map[float64]float64{-1: -1, -0.5: -1, 0: 1, 0.5: 1, 1: 1}},
+		{"dotweight", DotWeight, map[float64]float64{-1: -1, -0.5: -0.5, 0: 0, 0.5: 0.5, 1: 1}},
+	}
+	for _, c := range cases {
+		for dot, want := range c.want {
+			if got := c.f(p, dot); got != want {
+				t.Errorf("%s(%v): expected %v, got %v", c.name, dot, want, got)
+			}
+		}
+	}
+}
+
+func TestDistributions(t *testing.T) {
+	const samples = 20000
+	cases := []struct {
+		name string
+		f    pso.TugFunc
+		dot  float64
+		odd  float64 // the result that should come up with probability prob
+		prob float64
+	}{
+		{"rtrunc forward", RandomTruncate, 0.5, 0, 0},
+		{"rtrunc backward", RandomTruncate, -0.3, 0, 0.3},
+		{"rtrunc reversed", RandomTruncate, -1, 0, 1},
+		{"rflip forward", RandomFlip, 0.8, -1, 0},
+		{"rflip backward", RandomFlip, -0.7, -1, 0.7},
+		{"rdweight forward", RandomDotWeight, 0.8, -0.8, 0.2},
+		{"rdweight backward", RandomDotWeight, -0.4, 0.4, 0.6},
+		{"rdweight sideways", RandomDotWeight, 0, 0, 1},
+	}
+	for _, c := range cases {
+		p := newParticle(2)
+		odd := 0
+		for i := 0; i < samples; i++ {
+			if c.f(p, c.dot) == c.odd {
+				odd++
+			}
+		}
+		// Allow for four standard deviations of sampling error.
+		got := float64(odd) / samples
+		if tol := 4 * math.Sqrt(c.prob*(1-c.prob)/samples); math.Abs(got-c.prob) > tol+1e-9 {
+			t.Errorf("%s: expected %v with probability %v, got %v", c.name, c.odd, c.prob, got)
+		}
+	}
+}
+
+func TestParticleRand(t *testing.T) {
+	// Results follow each particle's own source.
+	a, b := newParticle(3), newParticle(3)
+	for i := 0; i < 100; i++ {
+		if x, y := RandomDotWeight(a, -0.5), RandomDotWeight(b, -0.5); x != y {
+			t.Fatalf("draw %d: particles with the same source got %v and %v", i, x, y)
+		}
+	}
+}
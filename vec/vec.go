@@ -249,6 +249,18 @@ func (v Vec) Mag() float64 {
 	return math.Sqrt(v.Dot(v))
 }
 
+// Dist returns the euclidean distance between v and other. It is equivalent to
+// v.Sub(other).Mag(), without allocating a new vector.
+func (v Vec) Dist(other Vec) float64 {
+	assertSameLen(v, other)
+	s := 0.0
+	for i, val := range other {
+		d := v[i] - val
+		s += d * d
+	}
+	return math.Sqrt(s)
+}
+
 // Reduce applies the given function to every item to reduce the vector to a single value.
 func (v Vec) Reduce(f func(accum, elem float64) float64, startVal float64) float64 {
 	accum := startVal
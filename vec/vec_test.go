@@ -225,3 +225,14 @@ func ExampleMag() {
 	// Output:
 	// 7.0710678118654755
 }
+
+func TestDist(t *testing.T) {
+	a := Vec{1, 2, 3}
+	b := Vec{4, 6, 3}
+	if d := a.Dist(b); d != 5 {
+		t.Errorf("Expected distance 5, got %v", d)
+	}
+	if d := b.Dist(a); d != a.Sub(b).Mag() {
+		t.Errorf("Dist %v differs from Sub().Mag() %v", d, a.Sub(b).Mag())
+	}
+}